@@ -0,0 +1,89 @@
+// Copyright Bjørn Borud 2019 Use of this source code is governed by
+// the license found in the accompanying LICENSE file.
+
+package points
+
+import (
+	"image"
+)
+
+// computeAdaptive computes dots by starting out with the boxes of
+// the grid and recursively splitting each box into four for as long
+// as the colors within it vary too much.  Busy parts of the image get
+// many small dots and flat parts a few big ones.  The dots are
+// returned in the same column order as for computeDots, with the
+// dots of each grid box in the order they were split.
+func computeAdaptive(img image.Image, g grid, opts Options) []dot {
+	cells := make([][]dot, g.cols*g.rows)
+	luma := chooseLuma(opts)
+
+	forEachRow(g.rows, opts, func(y int) {
+		for x := 0; x < g.cols; x++ {
+			box, ok := g.box(x, y)
+			if !ok {
+				continue
+			}
+			cells[x*g.rows+y] = subdivide(img, box, opts, luma, nil)
+		}
+	})
+
+	var dots []dot
+	for _, c := range cells {
+		dots = append(dots, c...)
+	}
+	return dots
+}
+
+// subdivide appends the dots for box to dots.  If the color variance
+// of the box is above opts.Variance and the quarters would be no
+// smaller than opts.MinBox the box is split into four, top left, top
+// right, bottom left and bottom right, and each is subdivided in
+// turn.  Otherwise the box becomes a single dot, sized to fit it.
+func subdivide(img image.Image, box image.Rectangle, opts Options, luma lumaFunc, dots []dot) []dot {
+	halfW := box.Dx() / 2
+	halfH := box.Dy() / 2
+
+	if halfW >= opts.MinBox && halfH >= opts.MinBox && boxVariance(img, box.Add(img.Bounds().Min)) > opts.Variance {
+		mid := image.Pt(box.Min.X+halfW, box.Min.Y+halfH)
+
+		dots = subdivide(img, image.Rect(box.Min.X, box.Min.Y, mid.X, mid.Y), opts, luma, dots)
+		dots = subdivide(img, image.Rect(mid.X, box.Min.Y, box.Max.X, mid.Y), opts, luma, dots)
+		dots = subdivide(img, image.Rect(box.Min.X, mid.Y, mid.X, box.Max.Y), opts, luma, dots)
+		return subdivide(img, image.Rect(mid.X, mid.Y, box.Max.X, box.Max.Y), opts, luma, dots)
+	}
+
+	return append(dots, makeDot(img, box, minInt(box.Dx(), box.Dy())/2, opts, luma))
+}
+
+// boxVariance returns the variance of the colors of the pixels of img
+// within box.  The variance is computed for each channel on a scale
+// from 0.0 to 1.0, and the average of the three is returned.  The box
+// is in image coordinates.
+func boxVariance(img image.Image, box image.Rectangle) float64 {
+	n := float64(box.Dx() * box.Dy())
+	if n == 0 {
+		return 0
+	}
+
+	var sum, sumSq [3]float64
+
+	at := pixelReader(img)
+	for cx := box.Min.X; cx < box.Max.X; cx++ {
+		for cy := box.Min.Y; cy < box.Max.Y; cy++ {
+			r, g, b, _ := at(cx, cy)
+			for i, v := range [3]uint32{r, g, b} {
+				f := float64(v) / 0xffff
+				sum[i] += f
+				sumSq[i] += f * f
+			}
+		}
+	}
+
+	var variance float64
+	for i := range sum {
+		mean := sum[i] / n
+		variance += sumSq[i]/n - mean*mean
+	}
+
+	return variance / 3
+}
@@ -0,0 +1,79 @@
+// Copyright Bjørn Borud 2019 Use of this source code is governed by
+// the license found in the accompanying LICENSE file.
+
+package points
+
+import (
+	"image"
+	"image/color"
+	"image/draw"
+	"math"
+	"testing"
+)
+
+func TestBoxVariance(t *testing.T) {
+	img := image.NewGray(image.Rect(0, 0, 20, 20))
+	if v := boxVariance(img, img.Bounds()); v != 0 {
+		t.Errorf("expected no variance in a flat box, got %g", v)
+	}
+
+	// Half black and half white is as varied as it gets
+	draw.Draw(img, image.Rect(10, 0, 20, 20), image.NewUniform(color.White), image.Point{}, draw.Src)
+	if v := boxVariance(img, img.Bounds()); math.Abs(v-0.25) > 1e-9 {
+		t.Errorf("expected a variance of 0.25, got %g", v)
+	}
+
+	if v := boxVariance(img, image.Rect(10, 0, 20, 20)); v != 0 {
+		t.Errorf("expected no variance in the white half, got %g", v)
+	}
+}
+
+func TestAdaptive(t *testing.T) {
+	// Black, apart from a top left corner with a checkerboard of
+	// single pixels
+	img := image.NewGray(image.Rect(0, 0, 64, 64))
+	for x := 0; x < 32; x++ {
+		for y := 0; y < 32; y++ {
+			if (x+y)%2 == 0 {
+				img.SetGray(x, y, color.Gray{0xff})
+			}
+		}
+	}
+
+	opts := DefaultOptions()
+	opts.BoxSize = 64
+	opts.Adaptive = true
+	opts.MinBox = 4
+
+	r, err := layout(img, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The detailed corner is split down to boxes of 4 pixels, 8 by 8
+	// of them, while the flat quarters stay whole
+	if len(r.dots) != 64+3 {
+		t.Fatalf("expected 67 dots, got %d", len(r.dots))
+	}
+
+	big := 0
+	for _, d := range r.dots {
+		if !d.visible {
+			continue
+		}
+
+		corner := d.cx < 32 && d.cy < 32
+		switch {
+		case corner && d.radius > 2:
+			t.Errorf("expected small dots in the detailed corner, got radius %d at %d,%d", d.radius, d.cx, d.cy)
+		case !corner && d.radius != 16:
+			t.Errorf("expected dots as big as the quarters outside the corner, got radius %d at %d,%d", d.radius, d.cx, d.cy)
+		case !corner:
+			big++
+		}
+	}
+
+	if big != 3 {
+		t.Errorf("expected 3 big dots, got %d", big)
+	}
+}
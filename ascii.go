@@ -0,0 +1,40 @@
+// Copyright Bjørn Borud 2019 Use of this source code is governed by
+// the license found in the accompanying LICENSE file.
+
+package points
+
+import (
+	"bufio"
+	"io"
+)
+
+// DefaultRamp is the characters used for ASCII output, from the
+// lightest to the darkest.
+const DefaultRamp = " .:-=+*#%@"
+
+// writeASCII writes one character per box, row by row.  The darker
+// the box the denser the character.  Boxes without a dot become the
+// first character of the ramp.
+func writeASCII(dots []dot, g grid, opts Options, w io.Writer) error {
+	ramp := []rune(opts.Ramp)
+	if len(ramp) == 0 {
+		ramp = []rune(DefaultRamp)
+	}
+
+	bw := bufio.NewWriter(w)
+
+	for y := 0; y < g.rows; y++ {
+		for x := 0; x < g.cols; x++ {
+			d := dots[x*g.rows+y]
+
+			ch := ramp[0]
+			if d.visible {
+				ch = ramp[int(d.size*float64(len(ramp)-1)+0.5)]
+			}
+			bw.WriteRune(ch)
+		}
+		bw.WriteByte('\n')
+	}
+
+	return bw.Flush()
+}
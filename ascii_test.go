@@ -0,0 +1,47 @@
+// Copyright Bjørn Borud 2019 Use of this source code is governed by
+// the license found in the accompanying LICENSE file.
+
+package points
+
+import (
+	"image"
+	"image/color"
+	"image/draw"
+	"testing"
+)
+
+func TestASCII(t *testing.T) {
+	// Black, dark gray, mid gray and white columns
+	img := image.NewGray(image.Rect(0, 0, 40, 20))
+	for i, g := range []uint8{0, 0x40, 0x80, 0xff} {
+		draw.Draw(img, image.Rect(i*10, 0, i*10+10, 20), image.NewUniform(color.Gray{g}), image.Point{}, draw.Src)
+	}
+
+	tests := []struct {
+		name   string
+		ramp   string
+		aspect float64
+		want   string
+	}{
+		{"default", "", 2, "@#= \n"},
+		{"ramp", "ab", 2, "bbaa\n"},
+		{"square", "", 1, "@#= \n@#= \n"},
+	}
+
+	for _, test := range tests {
+		opts := DefaultOptions()
+		opts.BoxSize = 10
+		opts.Format = FormatASCII
+		opts.Ramp = test.ramp
+		opts.CharAspect = test.aspect
+
+		out, err := RenderBytes(img, opts)
+		if err != nil {
+			t.Fatalf("%s: %v", test.name, err)
+		}
+
+		if string(out) != test.want {
+			t.Errorf("%s: expected %q, got %q", test.name, test.want, out)
+		}
+	}
+}
@@ -0,0 +1,149 @@
+// Copyright Bjørn Borud 2019 Use of this source code is governed by
+// the license found in the accompanying LICENSE file.
+
+package points
+
+import (
+	"image/color"
+	"math"
+)
+
+// canvas is what the dots are drawn onto.  Each output format that
+// draws shapes implements it, so they all share the same code for
+// deciding what to draw and only differ in how it is drawn.
+type canvas interface {
+	// start begins a drawing of the given size.  If background is
+	// not nil the whole drawing is filled with it first.
+	start(width int, height int, background color.Color)
+
+	// circle draws a filled circle.
+	circle(cx float64, cy float64, r float64, c color.Color)
+
+	// rect draws a filled rectangle.
+	rect(x float64, y float64, w float64, h float64, c color.Color)
+
+	// polygon draws a filled polygon whose vertices are given
+	// relative to cx, cy.
+	polygon(cx float64, cy float64, xs []float64, ys []float64, c color.Color)
+
+	// line draws a line that is as wide as the LineWidth option.
+	line(x1 float64, y1 float64, x2 float64, y2 float64, c color.Color)
+
+	// ring draws the outline of a circle with a stroke that is as
+	// wide as the LineWidth option.
+	ring(cx float64, cy float64, r float64, c color.Color)
+
+	// rotate rotates whatever is drawn until the next call to
+	// unrotate by angle radians, clockwise, around cx, cy.
+	rotate(angle float64, cx float64, cy float64)
+
+	// unrotate ends the rotation started by rotate.
+	unrotate()
+
+	// layer starts a new layer, named after a screen of Rosette, on
+	// top of what has been drawn so far.  Each layer darkens what is
+	// under it like ink on paper.  It is only called for Rosette.
+	layer(name string)
+
+	// end finishes the drawing and returns the first error that
+	// happened while drawing, if any.
+	end() error
+}
+
+// drawDots draws the visible dots onto c in the order they are
+// given.  For Rosette each screen gets a layer of its own, even if
+// none of its dots are visible.  The colors passed to c are not
+// premultiplied by their alpha, which is the opacity of the shape,
+// so canvases should get at them through rgbaOf.
+func drawDots(c canvas, dots []dot, width int, height int, opts Options) error {
+	c.start(width, height, opts.Background)
+
+	if !opts.Rosette {
+		drawLayer(c, dots, 0, opts)
+		return c.end()
+	}
+
+	for i, screen := range rosetteScreens {
+		c.layer(screen.name)
+		drawLayer(c, dots, i, opts)
+	}
+	return c.end()
+}
+
+// drawLayer draws the visible dots of the given layer.
+func drawLayer(c canvas, dots []dot, layer int, opts Options) {
+	for _, d := range dots {
+		if !d.visible || d.layer != layer {
+			continue
+		}
+
+		var col color.Color = color.RGBA{A: d.color.A}
+		if opts.Color {
+			col = d.color
+			if opts.CMYK {
+				col = cmykColor{rgba: d.color, cmyk: d.cmyk}
+			}
+		}
+
+		drawShape(c, opts, d, col)
+	}
+}
+
+// drawShape draws the dot in the shape given in the options.  The
+// radius of the dot is half the width of the shape so that a square
+// of the same radius as a circle has sides that are as long as the
+// circle's diameter.  The polygon shapes fit inside the circle.
+func drawShape(c canvas, opts Options, d dot, col color.Color) {
+	cx, cy, r := float64(d.cx), float64(d.cy), float64(d.radius)
+
+	if opts.Concentric > 0 {
+		for _, radius := range ringRadii(r, d.size, opts.Concentric) {
+			c.ring(cx, cy, radius, col)
+		}
+		return
+	}
+
+	if d.angle != 0 {
+		c.rotate(d.angle, cx, cy)
+		defer c.unrotate()
+	}
+
+	if xs, ys := shapeVertices(opts, r); xs != nil {
+		c.polygon(cx, cy, xs, ys, col)
+		return
+	}
+
+	switch opts.Shape {
+	case ShapeLine:
+		c.line(cx-r, cy, cx+r, cy, col)
+
+	case ShapeSquare:
+		c.rect(cx-r, cy-r, 2*r, 2*r, col)
+
+	default:
+		c.circle(cx, cy, r, col)
+	}
+}
+
+// ringRadii returns the radii of the concentric rings of a dot with
+// the given radius and size.  The size decides how many of the n
+// rings the dot gets, but there is always at least one, and they are
+// spaced evenly out to the radius.
+func ringRadii(radius float64, size float64, n int) []float64 {
+	rings := int(math.Ceil(size * float64(n)))
+	rings = clampInt(rings, 1, n)
+
+	radii := make([]float64, rings)
+	for i := range radii {
+		radii[i] = radius * float64(i+1) / float64(rings)
+	}
+	return radii
+}
+
+// rgbaOf returns c as a color.RGBA.  The colors given to a canvas are
+// either color.RGBA or pass one on through their RGBA method, so this
+// gives back the color as it was, without premultiplying it by its
+// alpha.
+func rgbaOf(c color.Color) color.RGBA {
+	return color.RGBAModel.Convert(c).(color.RGBA)
+}
@@ -0,0 +1,181 @@
+// Copyright Bjørn Borud 2019 Use of this source code is governed by
+// the license found in the accompanying LICENSE file.
+
+package points
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	"strings"
+	"testing"
+)
+
+// recordingCanvas records the calls made to it.
+type recordingCanvas struct {
+	calls []string
+}
+
+func (c *recordingCanvas) record(format string, args ...interface{}) {
+	c.calls = append(c.calls, fmt.Sprintf(format, args...))
+}
+
+func (c *recordingCanvas) start(width int, height int, background color.Color) {
+	c.record("start %d %d %v", width, height, background)
+}
+
+func (c *recordingCanvas) circle(cx float64, cy float64, r float64, col color.Color) {
+	c.record("circle %g %g %g %v", cx, cy, r, rgbaOf(col))
+}
+
+func (c *recordingCanvas) rect(x float64, y float64, w float64, h float64, col color.Color) {
+	c.record("rect %g %g %g %g %v", x, y, w, h, rgbaOf(col))
+}
+
+func (c *recordingCanvas) polygon(cx float64, cy float64, xs []float64, ys []float64, col color.Color) {
+	c.record("polygon %g %g %d %v", cx, cy, len(xs), rgbaOf(col))
+}
+
+func (c *recordingCanvas) line(x1 float64, y1 float64, x2 float64, y2 float64, col color.Color) {
+	c.record("line %g %g %g %g %v", x1, y1, x2, y2, rgbaOf(col))
+}
+
+func (c *recordingCanvas) ring(cx float64, cy float64, r float64, col color.Color) {
+	c.record("ring %g %g %g %v", cx, cy, r, rgbaOf(col))
+}
+
+func (c *recordingCanvas) rotate(angle float64, cx float64, cy float64) {
+	c.record("rotate %g %g %g", angle, cx, cy)
+}
+
+func (c *recordingCanvas) unrotate() {
+	c.record("unrotate")
+}
+
+func (c *recordingCanvas) layer(name string) {
+	c.record("layer %s", name)
+}
+
+func (c *recordingCanvas) end() error {
+	c.record("end")
+	return nil
+}
+
+func TestDrawDots(t *testing.T) {
+	// 2x2 boxes: black and dark gray on the left, mid gray and white
+	// on the right
+	img := image.NewRGBA(image.Rect(0, 0, 40, 40))
+	draw.Draw(img, image.Rect(0, 0, 20, 20), image.NewUniform(color.Black), image.Point{}, draw.Src)
+	draw.Draw(img, image.Rect(0, 20, 20, 40), image.NewUniform(color.Gray{0x40}), image.Point{}, draw.Src)
+	draw.Draw(img, image.Rect(20, 0, 40, 20), image.NewUniform(color.Gray{0x80}), image.Point{}, draw.Src)
+	draw.Draw(img, image.Rect(20, 20, 40, 40), image.NewUniform(color.White), image.Point{}, draw.Src)
+
+	tests := []struct {
+		name string
+		opts func(o *Options)
+		want []string
+	}{
+		{
+			"black",
+			func(o *Options) { o.Color = false },
+			[]string{
+				"start 40 40 <nil>",
+				"circle 10 10 10 {0 0 0 255}",
+				"circle 10 30 7 {0 0 0 255}",
+				"circle 30 10 4 {0 0 0 255}",
+				"end",
+			},
+		},
+		{
+			"color",
+			func(o *Options) {},
+			[]string{
+				"start 40 40 <nil>",
+				"circle 10 10 10 {0 0 0 255}",
+				"circle 10 30 7 {64 64 64 255}",
+				"circle 30 10 4 {128 128 128 255}",
+				"end",
+			},
+		},
+		{
+			"square",
+			func(o *Options) { o.Color = false; o.Shape = ShapeSquare },
+			[]string{
+				"start 40 40 <nil>",
+				"rect 0 0 20 20 {0 0 0 255}",
+				"rect 3 23 14 14 {0 0 0 255}",
+				"rect 26 6 8 8 {0 0 0 255}",
+				"end",
+			},
+		},
+	}
+
+	for _, test := range tests {
+		opts := DefaultOptions()
+		opts.BoxSize = 20
+		test.opts(&opts)
+
+		r, err := layout(img, opts)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		var c recordingCanvas
+		if err := drawDots(&c, r.dots, r.width, r.height, opts); err != nil {
+			t.Fatal(err)
+		}
+
+		if got, want := strings.Join(c.calls, "\n"), strings.Join(test.want, "\n"); got != want {
+			t.Errorf("%s: expected\n%s\ngot\n%s", test.name, want, got)
+		}
+	}
+}
+
+func TestRingRadii(t *testing.T) {
+	tests := []struct {
+		radius, size float64
+		n            int
+		want         string
+	}{
+		{8, 1.0, 4, "[2 4 6 8]"},
+		{8, 0.5, 4, "[4 8]"},
+		{8, 0.3, 4, "[4 8]"},
+		{8, 0.01, 4, "[8]"},
+		{8, 0.0, 4, "[8]"},
+		{9, 1.0, 3, "[3 6 9]"},
+	}
+
+	for _, test := range tests {
+		if got := fmt.Sprint(ringRadii(test.radius, test.size, test.n)); got != test.want {
+			t.Errorf("ringRadii(%g, %g, %d): expected %s, got %s", test.radius, test.size, test.n, test.want, got)
+		}
+	}
+}
+
+func TestConcentric(t *testing.T) {
+	// Black and mid gray boxes
+	img := image.NewGray(image.Rect(0, 0, 40, 20))
+	draw.Draw(img, image.Rect(20, 0, 40, 20), image.NewUniform(color.Gray{0x80}), image.Point{}, draw.Src)
+
+	opts := DefaultOptions()
+	opts.BoxSize = 20
+	opts.Concentric = 4
+
+	out, err := RenderBytes(img, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// All four rings for black and two for mid gray, as outlines
+	s := string(out)
+	if n := strings.Count(s, `<circle cx="10"`); n != 4 {
+		t.Errorf("expected 4 rings for the black box, got %d in %s", n, s)
+	}
+	if n := strings.Count(s, `<circle cx="30"`); n != 2 {
+		t.Errorf("expected 2 rings for the gray box, got %d in %s", n, s)
+	}
+	if !strings.Contains(s, `<g style="fill:none;stroke-width:2" >`) {
+		t.Errorf("expected the rings to be unfilled, got %s", s)
+	}
+}
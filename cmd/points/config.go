@@ -0,0 +1,72 @@
+// Copyright Bjørn Borud 2019 Use of this source code is governed by
+// the license found in the accompanying LICENSE file.
+
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"sort"
+	"strconv"
+)
+
+// loadConfig sets flags from a JSON config file.  The file holds an
+// object whose keys are the names of flags, without the dash, and
+// whose values are what would be given on the command line, as
+// strings, numbers or booleans, like
+//
+//	{"b": 30, "luma": "bt709", "palette": "web", "hex": true}
+//
+// The flags go through the same parsing as on the command line, so
+// the file can set anything a flag can.  Parse the command line again
+// afterwards to let the flags given there win.
+func loadConfig(fileName string) error {
+	data, err := ioutil.ReadFile(fileName)
+	if err != nil {
+		return err
+	}
+
+	var values map[string]interface{}
+	if err := json.Unmarshal(data, &values); err != nil {
+		return err
+	}
+
+	// Go through the keys in order so errors come out the same way
+	// every time.
+	keys := make([]string, 0, len(values))
+	for k := range values {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	for _, k := range keys {
+		if k == "config" {
+			return errors.New("a config file cannot name another")
+		}
+
+		if flag.Lookup(k) == nil {
+			return fmt.Errorf("unknown flag %q", k)
+		}
+
+		var s string
+		switch v := values[k].(type) {
+		case string:
+			s = v
+		case bool:
+			s = strconv.FormatBool(v)
+		case float64:
+			s = strconv.FormatFloat(v, 'g', -1, 64)
+		default:
+			return fmt.Errorf("invalid value for %q, must be a string, number or boolean", k)
+		}
+
+		if err := flag.Set(k, s); err != nil {
+			return fmt.Errorf("invalid value for %q: %v", k, err)
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,97 @@
+// Copyright Bjørn Borud 2019 Use of this source code is governed by
+// the license found in the accompanying LICENSE file.
+
+package main
+
+import (
+	"flag"
+	"io/ioutil"
+	"os"
+	"testing"
+
+	"github.com/borud/points"
+)
+
+func TestConfig(t *testing.T) {
+	f, err := ioutil.TempFile("", "points-config")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(f.Name())
+
+	if _, err := f.WriteString(`{"b": 30, "luma": "bt709"}`); err != nil {
+		t.Fatal(err)
+	}
+	if err := f.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name    string
+		args    []string
+		boxSize int
+	}{
+		{"file", nil, 30},
+		{"flag wins", []string{"-b", "20"}, 20},
+	}
+
+	for _, test := range tests {
+		func() {
+			// Put the flags back the way they were for the other tests
+			for _, name := range []string{"b", "luma"} {
+				defer flag.Set(name, flag.Lookup(name).Value.String())
+			}
+
+			if err := loadConfig(f.Name()); err != nil {
+				t.Fatalf("%s: %v", test.name, err)
+			}
+
+			// As run does after loading the config file
+			if err := flag.CommandLine.Parse(test.args); err != nil {
+				t.Fatalf("%s: %v", test.name, err)
+			}
+
+			opts, _, err := buildOptions()
+			if err != nil {
+				t.Fatalf("%s: %v", test.name, err)
+			}
+
+			if opts.BoxSize != test.boxSize {
+				t.Errorf("%s: expected box size %d, got %d", test.name, test.boxSize, opts.BoxSize)
+			}
+			if opts.Luma != points.LumaBT709 {
+				t.Errorf("%s: expected the luma standard from the file, got %q", test.name, opts.Luma)
+			}
+		}()
+	}
+}
+
+func TestConfigErrors(t *testing.T) {
+	tests := []struct {
+		name   string
+		config string
+	}{
+		{"json", `{"b": `},
+		{"unknown flag", `{"nosuchflag": 1}`},
+		{"nested config", `{"config": "other.json"}`},
+		{"value type", `{"b": [1, 2]}`},
+		{"value", `{"hex": "maybe"}`},
+	}
+
+	for _, test := range tests {
+		f, err := ioutil.TempFile("", "points-config")
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer os.Remove(f.Name())
+
+		if _, err := f.WriteString(test.config); err != nil {
+			t.Fatal(err)
+		}
+		f.Close()
+
+		if err := loadConfig(f.Name()); err == nil {
+			t.Errorf("%s: expected an error", test.name)
+		}
+	}
+}
@@ -0,0 +1,80 @@
+// Copyright Bjørn Borud 2019 Use of this source code is governed by
+// the license found in the accompanying LICENSE file.
+
+package main
+
+import (
+	"bytes"
+	"image"
+
+	"github.com/rwcarlsen/goexif/exif"
+)
+
+// exifOrientation returns the EXIF orientation tag of a JPEG, from 1
+// to 8.  JPEGs without EXIF data, or without the tag, give 1, which
+// means the image is already the right way up.
+func exifOrientation(data []byte) int {
+	x, err := exif.Decode(bytes.NewReader(data))
+	if err != nil {
+		return 1
+	}
+
+	tag, err := x.Get(exif.Orientation)
+	if err != nil {
+		return 1
+	}
+
+	orientation, err := tag.Int(0)
+	if err != nil || orientation < 1 || orientation > 8 {
+		return 1
+	}
+	return orientation
+}
+
+// straighten rotates and flips img so it is the right way up according
+// to the EXIF orientation.  Orientations 5 to 8 swap the width and
+// the height.
+func straighten(img image.Image, orientation int) image.Image {
+	if orientation == 1 {
+		return img
+	}
+
+	b := img.Bounds()
+	w, h := b.Dx(), b.Dy()
+
+	// src returns the pixel of the original image that should end up
+	// at x, y in the oriented one.
+	var src func(x int, y int) (int, int)
+
+	switch orientation {
+	case 2: // mirrored
+		src = func(x int, y int) (int, int) { return w - 1 - x, y }
+	case 3: // upside down
+		src = func(x int, y int) (int, int) { return w - 1 - x, h - 1 - y }
+	case 4: // upside down and mirrored
+		src = func(x int, y int) (int, int) { return x, h - 1 - y }
+	case 5: // transposed
+		src = func(x int, y int) (int, int) { return y, x }
+	case 6: // needs turning clockwise
+		src = func(x int, y int) (int, int) { return y, h - 1 - x }
+	case 7: // transversed
+		src = func(x int, y int) (int, int) { return w - 1 - y, h - 1 - x }
+	case 8: // needs turning counterclockwise
+		src = func(x int, y int) (int, int) { return w - 1 - y, x }
+	}
+
+	dw, dh := w, h
+	if orientation >= 5 {
+		dw, dh = h, w
+	}
+
+	dst := image.NewRGBA(image.Rect(0, 0, dw, dh))
+	for y := 0; y < dh; y++ {
+		for x := 0; x < dw; x++ {
+			sx, sy := src(x, y)
+			dst.Set(x, y, img.At(b.Min.X+sx, b.Min.Y+sy))
+		}
+	}
+
+	return dst
+}
@@ -0,0 +1,68 @@
+// Copyright Bjørn Borud 2019 Use of this source code is governed by
+// the license found in the accompanying LICENSE file.
+
+package main
+
+import (
+	"bytes"
+	"image"
+	"image/jpeg"
+	"testing"
+)
+
+// withOrientation inserts an EXIF segment with the given orientation
+// right after the start of a JPEG.
+func withOrientation(data []byte, orientation byte) []byte {
+	exif := []byte{
+		0xff, 0xe1, 0x00, 0x22, // APP1 and its length
+		'E', 'x', 'i', 'f', 0, 0,
+		'M', 'M', 0x00, 0x2a, 0x00, 0x00, 0x00, 0x08, // big endian TIFF header
+		0x00, 0x01, // one entry
+		0x01, 0x12, 0x00, 0x03, 0x00, 0x00, 0x00, 0x01, 0x00, orientation, 0x00, 0x00, // orientation, a short
+		0x00, 0x00, 0x00, 0x00, // no more entries
+	}
+
+	out := append([]byte{}, data[:2]...)
+	out = append(out, exif...)
+	return append(out, data[2:]...)
+}
+
+func TestEXIFOrientation(t *testing.T) {
+	// 30x20, dark on the left and light on the right
+	img := image.NewGray(image.Rect(0, 0, 30, 20))
+	for y := 0; y < 20; y++ {
+		for x := 15; x < 30; x++ {
+			img.Pix[img.PixOffset(x, y)] = 0xff
+		}
+	}
+
+	var buf bytes.Buffer
+	if err := jpeg.Encode(&buf, img, nil); err != nil {
+		t.Fatal(err)
+	}
+
+	if got := exifOrientation(buf.Bytes()); got != 1 {
+		t.Errorf("expected orientation 1 without EXIF, got %d", got)
+	}
+
+	data := withOrientation(buf.Bytes(), 6)
+	if got := exifOrientation(data); got != 6 {
+		t.Fatalf("expected orientation 6, got %d", got)
+	}
+
+	out, err := decodeImage(data)
+	if err != nil {
+		t.Fatalf("decodeImage: %v", err)
+	}
+
+	if got := out.Bounds(); got != image.Rect(0, 0, 20, 30) {
+		t.Fatalf("expected the bounds to be transposed to 20x30, got %v", got)
+	}
+
+	// Turned clockwise the left of the image ends up on top
+	top, _, _, _ := out.At(10, 2).RGBA()
+	bottom, _, _, _ := out.At(10, 27).RGBA()
+	if top > 0x2000 || bottom < 0xe000 {
+		t.Errorf("expected dark on top and light at the bottom, got %#x and %#x", top, bottom)
+	}
+}
@@ -0,0 +1,87 @@
+// Copyright Bjørn Borud 2019 Use of this source code is governed by
+// the license found in the accompanying LICENSE file.
+
+package main
+
+import (
+	"bytes"
+	"image"
+	"image/color/palette"
+	"image/gif"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/borud/points"
+)
+
+func TestAllFrames(t *testing.T) {
+	dir, err := ioutil.TempDir("", "points")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	// Two frames, the second only covering the right half
+	first := image.NewPaletted(image.Rect(0, 0, 40, 20), palette.Plan9)
+	second := image.NewPaletted(image.Rect(20, 0, 40, 20), palette.Plan9)
+	for i := range second.Pix {
+		second.Pix[i] = 0xff
+	}
+
+	var buf bytes.Buffer
+	if err := gif.EncodeAll(&buf, &gif.GIF{Image: []*image.Paletted{first, second}, Delay: []int{10, 10}}); err != nil {
+		t.Fatal(err)
+	}
+
+	in := filepath.Join(dir, "anim.gif")
+	if err := ioutil.WriteFile(in, buf.Bytes(), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	restore, err := setFlags([]string{"allframes"}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer restore()
+
+	frames, err := readFrames(in)
+	if err != nil {
+		t.Fatalf("readFrames: %v", err)
+	}
+	if len(frames) != 2 {
+		t.Fatalf("expected 2 frames, got %d", len(frames))
+	}
+
+	for _, frame := range frames {
+		if got := frame.Bounds(); got != image.Rect(0, 0, 40, 20) {
+			t.Errorf("expected every frame to be 40x20, got %v", got)
+		}
+	}
+
+	// The second frame is drawn over the first
+	if r, _, _, _ := frames[0].At(30, 10).RGBA(); r != 0 {
+		t.Errorf("expected the first frame to be black, got %#x", r)
+	}
+	if r, _, _, _ := frames[1].At(30, 10).RGBA(); r != 0xffff {
+		t.Errorf("expected the right of the second frame to be white, got %#x", r)
+	}
+	if r, _, _, _ := frames[1].At(10, 10).RGBA(); r != 0 {
+		t.Errorf("expected the left of the second frame to stay black, got %#x", r)
+	}
+
+	out := filepath.Join(dir, "out", "anim.svg")
+	if err := os.Mkdir(filepath.Dir(out), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := writeFrames(frames, points.DefaultOptions(), []int{20}, in, out); err != nil {
+		t.Fatalf("writeFrames: %v", err)
+	}
+
+	want := []string{"anim-f000.svg", "anim-f001.svg"}
+	if got := fileNames(t, filepath.Dir(out)); strings.Join(got, " ") != strings.Join(want, " ") {
+		t.Errorf("expected %v, got %v", want, got)
+	}
+}
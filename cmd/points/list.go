@@ -0,0 +1,148 @@
+// Copyright Bjørn Borud 2019 Use of this source code is governed by
+// the license found in the accompanying LICENSE file.
+
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/borud/points"
+)
+
+// listOnly are the flags that are read once for the whole list, so
+// they can't be given for a single image.
+var listOnly = map[string]bool{
+	"config":    true,
+	"list":      true,
+	"f":         true,
+	"outdir":    true,
+	"colorfrom": true,
+	"mask":      true,
+	"version":   true,
+}
+
+// processList renders the images named in a list file.  Each line is
+// the name of an image, optionally followed by flags given as
+// name=value, without the dash, that apply to that image alone, like
+//
+//	images/mona.jpg
+//	images/mona.jpg b=20 t=0.8 o=mona-20.svg
+//
+// A flag given as just its name is set to true.  Empty lines and
+// lines starting with # are skipped.  Each output is written next to
+// its image, or in outDir if it is set, unless the line names it.
+// base is used for the color image and the mask, which are the same
+// for every image.
+func processList(listFile string, outDir string, base points.Options) error {
+	data, err := readData(listFile)
+	if err != nil {
+		return err
+	}
+
+	for i, line := range strings.Split(string(data), "\n") {
+		fields := strings.Fields(line)
+		if len(fields) == 0 || strings.HasPrefix(fields[0], "#") {
+			continue
+		}
+
+		if err := processEntry(fields[0], fields[1:], outDir, base); err != nil {
+			wrapped := fmt.Errorf("%s line %d: %v", listFile, i+1, err)
+			if _, ok := err.(usageError); ok {
+				return usageError{wrapped}
+			}
+			return wrapped
+		}
+	}
+
+	return nil
+}
+
+// processEntry renders one image of a list with the flags of its line
+// set on top of those of the command line.
+func processEntry(input string, settings []string, outDir string, base points.Options) error {
+	restore, err := setFlags(settings, listOnly)
+	defer restore()
+	if err != nil {
+		return usageError{err}
+	}
+
+	opts, sizes, err := buildOptions()
+	if err != nil {
+		return err
+	}
+
+	opts.ColorImage = base.ColorImage
+	opts.Mask = base.Mask
+	if err := opts.Validate(); err != nil {
+		return usageError{fmt.Errorf("invalid options: %v", err)}
+	}
+
+	out := *outputFile
+	if out == "" {
+		out = strings.TrimSuffix(input, filepath.Ext(input)) + extension(opts.Format)
+		if outDir != "" {
+			out = filepath.Join(outDir, filepath.Base(out))
+		}
+	}
+
+	frames, err := readFrames(input)
+	if err != nil {
+		return fmt.Errorf("error reading image %s: %v", input, err)
+	}
+
+	if err := os.MkdirAll(filepath.Dir(out), 0755); err != nil {
+		return err
+	}
+
+	return writeFrames(frames, opts, sizes, input, out)
+}
+
+// setFlags sets the flags given as name=value and returns a function
+// that puts back the values they had.  The flags in fixed can't be
+// set.  The flags are set on their values directly, so they don't
+// count as given on the command line, and -t and -thigh, which are
+// the same threshold, are set together so either wins over both from
+// the command line.
+func setFlags(settings []string, fixed map[string]bool) (func(), error) {
+	var undo []func()
+	restore := func() {
+		for i := len(undo) - 1; i >= 0; i-- {
+			undo[i]()
+		}
+	}
+
+	for _, s := range settings {
+		name, value := s, "true"
+		if i := strings.Index(s, "="); i >= 0 {
+			name, value = s[:i], s[i+1:]
+		}
+
+		if fixed[name] {
+			return restore, fmt.Errorf("-%s cannot be given for a single image", name)
+		}
+
+		names := []string{name}
+		if name == "t" || name == "thigh" {
+			names = []string{"t", "thigh"}
+		}
+
+		for _, n := range names {
+			f := flag.Lookup(n)
+			if f == nil {
+				return restore, fmt.Errorf("unknown flag %q", n)
+			}
+
+			old := f.Value.String()
+			if err := f.Value.Set(value); err != nil {
+				return restore, fmt.Errorf("invalid value for %q: %v", n, err)
+			}
+			undo = append(undo, func() { f.Value.Set(old) })
+		}
+	}
+
+	return restore, nil
+}
@@ -0,0 +1,107 @@
+// Copyright Bjørn Borud 2019 Use of this source code is governed by
+// the license found in the accompanying LICENSE file.
+
+package main
+
+import (
+	"bytes"
+	"image"
+	"image/png"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/borud/points"
+)
+
+func TestProcessList(t *testing.T) {
+	dir, err := ioutil.TempDir("", "points")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	for _, name := range []string{"a.png", "b.png"} {
+		var buf bytes.Buffer
+		if err := png.Encode(&buf, image.NewGray(image.Rect(0, 0, 80, 40))); err != nil {
+			t.Fatal(err)
+		}
+		if err := ioutil.WriteFile(filepath.Join(dir, name), buf.Bytes(), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// The second image gets smaller boxes than the default of 50
+	list := filepath.Join(dir, "list.txt")
+	manifest := filepath.Join(dir, "a.png") + "\n" + filepath.Join(dir, "b.png") + " b=20\n"
+	if err := ioutil.WriteFile(list, []byte(manifest), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := processList(list, "", points.DefaultOptions()); err != nil {
+		t.Fatalf("processList: %v", err)
+	}
+
+	tests := []struct {
+		name    string
+		circles int
+	}{
+		{"a.svg", 2},
+		{"b.svg", 8},
+	}
+
+	for _, test := range tests {
+		out, err := ioutil.ReadFile(filepath.Join(dir, test.name))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if n := strings.Count(string(out), "<circle"); n != test.circles {
+			t.Errorf("%s: expected %d circles, got %d", test.name, test.circles, n)
+		}
+	}
+
+	// The flags of a line don't carry over to the next one
+	if *boxSizes != "50" {
+		t.Errorf("expected -b to be put back to 50, got %s", *boxSizes)
+	}
+}
+
+func TestProcessListErrors(t *testing.T) {
+	dir, err := ioutil.TempDir("", "points")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	tests := []struct {
+		name     string
+		manifest string
+		usage    bool
+		line     string
+	}{
+		{"list only flag", "a.png outdir=x\n", true, "line 1"},
+		{"unknown flag", "a.png nosuchflag=1\n", true, "line 1"},
+		{"missing image", "# just a comment\n\nmissing.png\n", false, "line 3"},
+	}
+
+	for _, test := range tests {
+		list := filepath.Join(dir, "list.txt")
+		if err := ioutil.WriteFile(list, []byte(test.manifest), 0644); err != nil {
+			t.Fatal(err)
+		}
+
+		err := processList(list, "", points.DefaultOptions())
+		if err == nil {
+			t.Errorf("%s: expected an error", test.name)
+			continue
+		}
+		if _, ok := err.(usageError); ok != test.usage {
+			t.Errorf("%s: expected a usage error to be %v, got %v", test.name, test.usage, err)
+		}
+		if !strings.Contains(err.Error(), test.line) {
+			t.Errorf("%s: expected the error to name %s, got %v", test.name, test.line, err)
+		}
+	}
+}
@@ -0,0 +1,953 @@
+// Copyright Bjørn Borud 2019 Use of this source code is governed by
+// the license found in the accompanying LICENSE file.
+//
+// Simple utility for turning a bitmap into colored dots whose
+// diameter is proportional to the luminescence of the region the dot
+// represents and the color is the average color of the area.
+//
+// This program is probably slow, and fairly suboptimal stemming from
+// the fact that I have absolutely no experience writing graphics
+// utilities.  But hopefully it is easy to read and understand.
+//
+// The actual work is done by the github.com/borud/points package;
+// this is just a thin wrapper that parses flags and handles files.
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"flag"
+	"fmt"
+	"image"
+	"io"
+	"io/fs"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/borud/points"
+
+	_ "image/gif"
+	_ "image/jpeg"
+	_ "image/png"
+
+	_ "golang.org/x/image/bmp"
+	_ "golang.org/x/image/tiff"
+	_ "golang.org/x/image/webp"
+)
+
+var defaults = points.DefaultOptions()
+
+var (
+	inputFile     = flag.String("f", "", "input image in either JPEG, PNG, GIF, WebP, BMP or TIFF, - for stdin, an http or https URL, or a directory of images")
+	timeout       = flag.Duration("timeout", 30*time.Second, "How long to wait for an image given as a URL")
+	outputFile    = flag.String("o", "", "Output file, - for stdout")
+	outputDir     = flag.String("outdir", "", "Directory to write the outputs to when -f is a directory or with -list. Default is next to each image")
+	serveAddr     = flag.String("serve", "", "Serve previews of -f over HTTP on this address, like :8080, taking flags as query parameters like ?b=30&t=0.8")
+	listFile      = flag.String("list", "", "File listing the images to render, one per line, each optionally followed by flags as name=value for it alone")
+	boxSizes      = flag.String("b", strconv.Itoa(defaults.BoxSize), "Box size for dots, or a comma separated list of sizes to make one output for each")
+	boxWidth      = flag.Int("bw", defaults.BoxWidth, "Box width, 0 means the same as -b")
+	boxHeight     = flag.Int("bh", defaults.BoxHeight, "Box height, 0 means the same as -b")
+	density       = flag.Int("density", defaults.Density, "Distance between the centers of the boxes, 0 means the same as the box size")
+	scale         = flag.Float64("s", defaults.Scale, "Scale with which svg fill will be scaled compared to original file")
+	canvasScale   = flag.Float64("canvasscale", defaults.CanvasScale, "Scale of the canvas and the dot positions, leaving -s to scale the dots.  0 means the same as -s")
+	lumaThreshold = flag.Float64("t", defaults.LumaThreshold, "Luma threshold - don't draw dots above this luminescence value.  Value from 0.0 to 1.0")
+	thresholdHigh = flag.Float64("thigh", defaults.LumaThreshold, "Same as -t, the top of the band of luma that gets dots")
+	thresholdLow  = flag.Float64("tlow", defaults.LumaThresholdLow, "Don't draw dots below this luminescence value, the bottom of the band of luma that gets dots.  Value from 0.0 to 1.0")
+	autoThreshold = flag.Bool("autothreshold", defaults.AutoThreshold, "Pick the luma threshold from the image rather than using -t")
+	coverage      = flag.Float64("coverage", defaults.Coverage, "Fraction of the boxes -autothreshold should give a dot, 0 means use Otsu's method")
+	color         = flag.Bool("c", defaults.Color, "Use average color for area rather than just black")
+	luma          = flag.String("luma", defaults.Luma, "Standard for luma calculations: bt601, bt709, bt2020 or smpte240")
+	bt701         = flag.Bool("l", defaults.BT709, "Deprecated, same as -luma bt709")
+	metric        = flag.String("metric", defaults.Metric, "What sizes the dots: luma, saturation or value, the brightest channel")
+	channel       = flag.String("channel", defaults.Channel, "Size the dots by a single channel rather than the luma: r, g, b or luma")
+	lumaWeights   = flag.String("weights", "", "Custom luma weights for the red, green and blue channels as r,g,b.  Overrides -luma")
+	blackPoint    = flag.Float64("blackpoint", defaults.BlackPoint, "Luma that is made black before the dots are sized.  Value from 0.0 to 1.0")
+	whitePoint    = flag.Float64("whitepoint", defaults.WhitePoint, "Luma that is made white before the dots are sized.  Value from 0.0 to 1.0")
+	equalize      = flag.Bool("equalize", defaults.Equalize, "Spread the lumas of the image evenly with histogram equalization before the dots are sized")
+	edges         = flag.String("edges", defaults.Edges, "Size the dots by the edges of the image: only, or multiply to combine with the luma")
+	dotGamma      = flag.Float64("dotgamma", defaults.DotGamma, "Raise the darkness to this power before turning it into a radius")
+	vignette      = flag.Float64("vignette", defaults.Vignette, "Shrink the dots toward the edges, down to 1 minus this in the corners.  Value from 0.0 to 1.0")
+	opacity       = flag.Float64("opacity", defaults.Opacity, "Make the dots of bright boxes transparent, using the darkness raised to this power as the opacity.  0 means opaque")
+	lumaArea      = flag.Bool("a", defaults.LumaArea, "Use the luma as the surface area instead of the radius")
+	areaScale     = flag.Float64("areascale", defaults.AreaScale, "Scale of the radius when using -a, 1.77 makes black dots fill the box")
+	shape         = flag.String("shape", defaults.Shape, "Shape of the dots: circle, square, diamond, triangle, hexagon, star or line")
+	lineWidth     = flag.Float64("linewidth", defaults.LineWidth, "Stroke width of the line shape, of -concentric rings and of -contour outlines")
+	concentric    = flag.Int("concentric", defaults.Concentric, "Draw each dot as up to this many concentric rings, more for darker boxes.  0 means filled dots")
+	css           = flag.Bool("css", defaults.CSS, "Give the dots CSS classes by brightness and a style sheet with their colors rather than inline colors (svg only)")
+	soft          = flag.Bool("soft", defaults.Soft, "Fill the dots with a radial gradient that fades to transparent at the edge (svg only)")
+	orient        = flag.Bool("orient", defaults.Orient, "Rotate dots that aren't circles to follow the local image gradient")
+	starRatio     = flag.Float64("starratio", defaults.StarRatio, "Ratio between the inner and outer radius of stars")
+	hex           = flag.Bool("hex", defaults.Hex, "Arrange dots on a hexagonal grid")
+	checker       = flag.Bool("checker", defaults.Checker, "Only draw every other box, like the black squares of a checkerboard")
+	checkerOdd    = flag.Bool("checkerodd", defaults.CheckerOdd, "With -checker, draw the other half of the boxes instead")
+	tileable      = flag.Bool("tileable", defaults.Tileable, "Wrap the image around at the edges so the output tiles without seams")
+	rotation      = flag.Int("rotate", 0, "Turn the image clockwise by 90, 180 or 270 degrees before drawing it")
+	flipAxis      = flag.String("flip", "", "Flip the image after -rotate, h for left to right or v for top to bottom")
+	cropRegion    = flag.String("crop", "", "Only process this region of the image, given as x,y,w,h")
+	maxDim        = flag.Int("maxdim", defaults.MaxDim, "Scale the image down so its longest side is at most this many pixels before processing, 0 means no limit")
+	filter        = flag.String("filter", points.FilterBilinear, "Filter for scaling the image down with -maxdim: nearest, bilinear or catmullrom")
+	stats         = flag.Bool("stats", false, "Print statistics about the output rather than writing it")
+	allFrames     = flag.Bool("allframes", false, "Render every frame of an animated GIF into its own output, numbered from 0")
+	quiet         = flag.Bool("quiet", false, "Don't print anything but errors")
+	configFile    = flag.String("config", "", "JSON file of flag names and values to use as defaults, overridden by the flags given")
+	showVersion   = flag.Bool("version", false, "Print the version of points and the Go version it was built with, then exit")
+	progress      = flag.Bool("progress", false, "Print how far along the rendering is to stderr")
+	workers       = flag.Int("j", defaults.Workers, "Number of workers computing dots, 0 means one per CPU")
+	colorMode     = flag.String("colormode", defaults.ColorMode, "How to compute the color of a box: mean, median, dominant or lumaweight")
+	mask          = flag.String("mask", "", "Only put dots where this image is white, stretched over the image from -f")
+	colorFrom     = flag.String("colorfrom", "", "Take the colors of the dots from this image, at the same relative position, rather than from -f")
+	gray          = flag.Bool("gray", defaults.Gray, "Fill the dots with the gray level of their box rather than its color")
+	hue           = flag.Float64("hue", -1, "Fill the dots with this hue, in degrees, and a lightness from the luma of their box.  Negative means the colors of the image")
+	saturation    = flag.Float64("sat", defaults.Saturation, "Saturation of the dots from 0.0 to 1.0 when using -hue")
+	circular      = flag.Bool("circularsample", defaults.CircularSample, "Weigh the pixels of each box by how close they are to its middle")
+	samples       = flag.Int("sample", defaults.Samples, "Estimate the color of each box from this many pixels, 0 means all of them")
+	alphaCutoff   = flag.Float64("alphacutoff", defaults.AlphaCutoff, "Don't draw dots for boxes whose average alpha is below this value.  Value from 0.0 to 1.0")
+	paletteSpec   = flag.String("palette", "", "Limit the dot colors to a palette: web, grayscale4 or a comma separated list of #rrggbb colors")
+	paletteFile   = flag.String("palettefile", "", "Limit the dot colors to the palette in this GIMP .gpl or Adobe .aco file")
+	paletteLab    = flag.Bool("lab", false, "Find the nearest palette color in CIELAB rather than RGB")
+	cmyk          = flag.Bool("cmyk", defaults.CMYK, "Add the CMYK color of each dot to the SVG as a device-cmyk fill for printing")
+	colorSpace    = flag.String("colorspace", defaults.ColorSpace, "Color space colors are averaged in: srgb, linear or lab")
+	linear        = flag.Bool("linear", defaults.Linear, "Deprecated, same as -colorspace linear")
+	invert        = flag.Bool("invert", defaults.Invert, "Make bright areas produce big dots and dark areas small ones")
+	minRadius     = flag.Float64("min", defaults.MinRadius, "Minimum dot radius")
+	maxRadius     = flag.Float64("max", defaults.MaxRadius, "Maximum dot radius, 0 means no limit")
+	noOverlap     = flag.Bool("nooverlap", defaults.NoOverlap, "Keep each dot within its box")
+	precision     = flag.Int("precision", defaults.Precision, "Snap dot centers and radii to multiples of this and drop dots that round to nothing")
+	mergeFlat     = flag.Float64("mergeflat", defaults.MergeFlat, "Merge neighboring dots in a row whose colors differ by no more than this, from 0.0 to 1.0, into one")
+	maxDots       = flag.Int("maxdots", defaults.MaxDots, "Fail rather than draw more dots than this, 0 means no limit")
+	supersample   = flag.Int("ss", defaults.Supersample, "Samples along each axis of a pixel of png and sixel output, for smooth edges.  0 means 4")
+	format        = flag.String("format", "", "Output format, svg, png, pdf, ascii, sixel or json. Default is to go by the output file extension")
+	ramp          = flag.String("ramp", defaults.Ramp, "Characters for ascii output from lightest to darkest")
+	charAspect    = flag.Float64("aspect", defaults.CharAspect, "Height divided by width of terminal characters for ascii output")
+	minify        = flag.Bool("minify", defaults.Minify, "Leave out the newlines and indentation between the elements of the SVG")
+	responsive    = flag.Bool("responsive", defaults.Responsive, "Give the SVG a viewBox rather than a fixed width and height")
+	dpi           = flag.Float64("dpi", defaults.DPI, "Give the width and height of the SVG in -unit for printing at this resolution, 0 means pixels")
+	unit          = flag.String("unit", points.UnitMillimeter, "Unit of the width and height of the SVG with -dpi: mm or in")
+	title         = flag.String("title", "", "Title of the SVG. Default is the name of the input file")
+	date          = flag.String("date", "", "Date to stamp the output with, as RFC 3339 like 2019-06-01T12:00:00Z. Default is the Unix epoch for PDF, so it is the same from run to run, and the current time in the SVG comment")
+	background    = flag.String("bg", "", "Background color as #rrggbb. Default is transparent")
+	stroke        = flag.String("stroke", "", "Outline the dots with a stroke of this color, as #rrggbb. Default is no stroke")
+	strokeWidth   = flag.Float64("strokewidth", defaults.StrokeWidth, "Width of the stroke given by -stroke")
+	adaptive      = flag.Bool("adaptive", defaults.Adaptive, "Split boxes whose colors vary into smaller boxes")
+	variance      = flag.Float64("variance", defaults.Variance, "Color variance above which adaptive boxes are split.  Value from 0.0 to 1.0")
+	minBox        = flag.Int("minbox", defaults.MinBox, "Smallest box size adaptive boxes are split down to")
+	jitter        = flag.Float64("jitter", defaults.Jitter, "Move each dot randomly by up to this times half a box.  Value from 0.0 to 1.0")
+	seed          = flag.Int64("seed", defaults.Seed, "Seed for the random numbers used by -jitter and -poisson")
+	poisson       = flag.Bool("poisson", defaults.Poisson, "Spread the dots out randomly with Poisson disk sampling rather than on a grid")
+	spiral        = flag.Bool("spiral", defaults.Spiral, "Lay the dots out along a spiral from the middle of the image rather than on a grid")
+	spiralSpacing = flag.Float64("spiralspacing", defaults.SpiralSpacing, "Distance in pixels between the turns of -spiral, 0 means the box width")
+	spiralStep    = flag.Float64("spiralstep", defaults.SpiralStep, "Degrees between the dots of -spiral, 0 means as far apart as the turns")
+	rosette       = flag.Bool("rosette", defaults.Rosette, "Draw a turned grid of dots for each of the cyan, magenta, yellow and black inks, like a print")
+	contour       = flag.Float64("contour", defaults.Contour, "Draw the outlines of the areas whose luma is below this instead of dots.  Value from 0.0 to 1.0")
+	stipple       = flag.Bool("stipple", defaults.Stipple, "Draw dots of the same size spread out by darkness using error diffusion")
+)
+
+// readFrames reads the source image. What formats it can understand
+// depends on what formats have been loaded.  With -allframes every
+// frame of an animated GIF is returned, otherwise there is just the
+// one.
+func readFrames(fileName string) ([]image.Image, error) {
+	data, err := readData(fileName)
+	if err != nil {
+		return nil, err
+	}
+
+	if *allFrames {
+		if _, format, err := image.DecodeConfig(bytes.NewReader(data)); err == nil && format == "gif" {
+			return decodeFrames(data)
+		}
+	}
+
+	img, err := decodeImage(data)
+	if err != nil {
+		return nil, err
+	}
+	return []image.Image{img}, nil
+}
+
+// readData reads the undecoded source image into memory.  If fileName
+// is "-" it is read from stdin, and if it is a URL it is downloaded.
+// The decoders need to peek at the start of the data to figure out
+// the format, so we buffer all of it rather than streaming.
+func readData(fileName string) ([]byte, error) {
+	if fileName == "-" {
+		return ioutil.ReadAll(os.Stdin)
+	}
+
+	if isURL(fileName) {
+		return readURL(fileName)
+	}
+
+	return ioutil.ReadFile(fileName)
+}
+
+// decodeImage decodes the image in data.  JPEGs are turned the right
+// way up according to their EXIF orientation, since phones tend to
+// store the pixels the way the sensor saw them and leave it to the
+// viewer to rotate them.
+func decodeImage(data []byte) (image.Image, error) {
+	img, format, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+
+	if format == "jpeg" {
+		img = straighten(img, exifOrientation(data))
+	}
+
+	return img, nil
+}
+
+// readImage reads and decodes a single image, like -colorfrom and
+// -mask take, from a file, a URL or stdin.
+func readImage(fileName string) (image.Image, error) {
+	data, err := readData(fileName)
+	if err != nil {
+		return nil, err
+	}
+	return decodeImage(data)
+}
+
+// turn rotates img clockwise by rotation degrees and then flips it,
+// horizontally if flipAxis is h and vertically if it is v, by
+// straightening it as if it had the EXIF orientation that needs the
+// same.
+func turn(img image.Image, rotation int, flipAxis string) image.Image {
+	switch rotation {
+	case 90:
+		img = straighten(img, 6)
+	case 180:
+		img = straighten(img, 3)
+	case 270:
+		img = straighten(img, 8)
+	}
+
+	switch flipAxis {
+	case "h":
+		img = straighten(img, 2)
+	case "v":
+		img = straighten(img, 4)
+	}
+	return img
+}
+
+// isURL returns true if the name of the input is an http or https
+// URL rather than a file name.
+func isURL(name string) bool {
+	return strings.HasPrefix(name, "http://") || strings.HasPrefix(name, "https://")
+}
+
+// readURL downloads the image into memory.
+func readURL(addr string) ([]byte, error) {
+	client := http.Client{Timeout: *timeout}
+
+	resp, err := client.Get(addr)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("unexpected status %s", resp.Status)
+	}
+
+	// Servers don't always get the content type right, so we only
+	// turn down things that are clearly not images and leave the rest
+	// to the decoder.
+	ct := resp.Header.Get("Content-Type")
+	if strings.HasPrefix(ct, "text/") || strings.HasPrefix(ct, "application/json") {
+		return nil, fmt.Errorf("expected an image, got %s", ct)
+	}
+
+	return ioutil.ReadAll(resp.Body)
+}
+
+// stdinIsPiped returns true if stdin is a pipe or a file rather than
+// a terminal.
+func stdinIsPiped() bool {
+	fi, err := os.Stdin.Stat()
+	if err != nil {
+		return false
+	}
+	return fi.Mode()&os.ModeCharDevice == 0
+}
+
+// parseCrop parses a crop region given as x,y,w,h.
+func parseCrop(s string) (image.Rectangle, error) {
+	parts := strings.Split(s, ",")
+	if len(parts) != 4 {
+		return image.Rectangle{}, fmt.Errorf("expected x,y,w,h, got %q", s)
+	}
+
+	var v [4]int
+	for i, p := range parts {
+		n, err := strconv.Atoi(strings.TrimSpace(p))
+		if err != nil {
+			return image.Rectangle{}, fmt.Errorf("expected x,y,w,h, got %q", s)
+		}
+		v[i] = n
+	}
+
+	if v[2] <= 0 || v[3] <= 0 {
+		return image.Rectangle{}, fmt.Errorf("width and height must be positive, got %q", s)
+	}
+
+	return image.Rect(v[0], v[1], v[0]+v[2], v[1]+v[3]), nil
+}
+
+// parseBoxSizes parses a comma separated list of box sizes.
+func parseBoxSizes(s string) ([]int, error) {
+	var sizes []int
+	for _, p := range strings.Split(s, ",") {
+		n, err := strconv.Atoi(strings.TrimSpace(p))
+		if err != nil {
+			return nil, fmt.Errorf("expected a list of integers, got %q", s)
+		}
+		sizes = append(sizes, n)
+	}
+	return sizes, nil
+}
+
+// framedName inserts the index of a frame into the name of an output
+// file, so test.svg becomes test-f003.svg.
+func framedName(fileName string, frame int) string {
+	ext := filepath.Ext(fileName)
+	return fmt.Sprintf("%s-f%03d%s", strings.TrimSuffix(fileName, ext), frame, ext)
+}
+
+// sizedName inserts the box size into the name of an output file, so
+// test.svg becomes test-b30.svg.
+func sizedName(fileName string, boxSize int) string {
+	ext := filepath.Ext(fileName)
+	return fmt.Sprintf("%s-b%d%s", strings.TrimSuffix(fileName, ext), boxSize, ext)
+}
+
+// parseWeights parses luma weights given as r,g,b.
+func parseWeights(s string) ([3]float64, error) {
+	var w [3]float64
+
+	parts := strings.Split(s, ",")
+	if len(parts) != 3 {
+		return w, fmt.Errorf("expected r,g,b, got %q", s)
+	}
+
+	for i, p := range parts {
+		f, err := strconv.ParseFloat(strings.TrimSpace(p), 64)
+		if err != nil {
+			return w, fmt.Errorf("expected r,g,b, got %q", s)
+		}
+		w[i] = f
+	}
+
+	if w == [3]float64{} {
+		return w, fmt.Errorf("weights cannot all be zero, got %q", s)
+	}
+
+	return w, nil
+}
+
+// extensions are the file name extensions of the output formats.
+// The first one is what outputs are named with, and formatFromName
+// knows them all, so the names we make can be read back.
+var extensions = map[string][]string{
+	points.FormatSVG:   {".svg"},
+	points.FormatPNG:   {".png"},
+	points.FormatASCII: {".txt"},
+	points.FormatPDF:   {".pdf"},
+	points.FormatSixel: {".six", ".sixel"},
+	points.FormatJSON:  {".json"},
+}
+
+// formatFromName guesses the output format from the file name
+// extension.  Anything we don't recognize is SVG.
+func formatFromName(fileName string) string {
+	ext := strings.ToLower(filepath.Ext(fileName))
+	for format, exts := range extensions {
+		for _, e := range exts {
+			if e == ext {
+				return format
+			}
+		}
+	}
+	return points.FormatSVG
+}
+
+// extension returns the extension outputs in format are named with.
+func extension(format string) string {
+	if exts, ok := extensions[format]; ok {
+		return exts[0]
+	}
+	return "." + format
+}
+
+// writeOutput renders the image into the named file.  If fileName is
+// "-" the output is written to stdout.
+func writeOutput(img image.Image, opts points.Options, fileName string) error {
+	if fileName == "-" {
+		return render(img, opts, os.Stdout)
+	}
+
+	outFile, err := os.Create(fileName)
+	if err != nil {
+		return err
+	}
+
+	err = render(img, opts, outFile)
+	if closeErr := outFile.Close(); err == nil {
+		err = closeErr
+	}
+
+	// Don't leave a half written file behind
+	if err != nil {
+		os.Remove(fileName)
+	}
+	return err
+}
+
+// outputName returns the name of the output file for the named input
+// when -o isn't given.  It is the name of the input with the extension
+// of the format, or out with it if there is no name to go by, as for
+// stdin.
+func outputName(inputName string, format string) string {
+	fn := "out" + extension(format)
+	switch {
+	case isURL(inputName):
+		// Name the output after the last part of the URL path, in
+		// the current directory.
+		if u, err := url.Parse(inputName); err == nil {
+			if base := path.Base(u.Path); base != "/" && base != "." {
+				fn = strings.TrimSuffix(base, path.Ext(base)) + extension(format)
+			}
+		}
+	case inputName != "-":
+		fn = strings.TrimSuffix(inputName, filepath.Ext(inputName)) + extension(format)
+	}
+	return fn
+}
+
+// describe fills in the title, description and comment of the SVG
+// made from the named input file.  The comment says when the SVG was
+// generated, which is the date given with -date or else now.
+func describe(opts *points.Options, inputName string) {
+	if inputName == "-" {
+		inputName = "stdin"
+	}
+
+	opts.Title = *title
+	if opts.Title == "" {
+		opts.Title = filepath.Base(inputName)
+	}
+
+	opts.Description = fmt.Sprintf("%s drawn as dots with a box size of %d", filepath.Base(inputName), opts.BoxSize)
+	generated := opts.Date
+	if generated.IsZero() {
+		generated = time.Now()
+	}
+	opts.Comment = fmt.Sprintf("source: %s, box size: %d, generated: %s", inputName, opts.BoxSize, generated.Format(time.RFC3339))
+}
+
+// writeSizes renders the image from the named input file once for
+// each box size.  If there is more than one size the size is added to
+// the name of each output.
+func writeSizes(img image.Image, opts points.Options, sizes []int, inputName string, fileName string) error {
+	for _, size := range sizes {
+		opts.BoxSize = size
+		describe(&opts, inputName)
+
+		fn := fileName
+		if len(sizes) > 1 {
+			fn = sizedName(fn, size)
+		}
+
+		if *stats {
+			if err := printStats(img, opts, fn); err != nil {
+				return err
+			}
+			continue
+		}
+
+		if err := writeOutput(img, opts, fn); err != nil {
+			return fmt.Errorf("unable to write output file %s: %v", fn, err)
+		}
+	}
+	return nil
+}
+
+// writeFrames renders each frame of the image from the named input
+// file with writeSizes.  With -allframes the index of the frame is
+// added to the name of each output, even if there is only one.
+func writeFrames(frames []image.Image, opts points.Options, sizes []int, inputName string, fileName string) error {
+	for i, frame := range frames {
+		frame = turn(frame, *rotation, *flipAxis)
+
+		fn := fileName
+		if *allFrames {
+			fn = framedName(fn, i)
+		}
+
+		if err := writeSizes(frame, opts, sizes, inputName, fn); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// printStats prints what rendering the image into fileName would
+// produce, without writing anything.
+func printStats(img image.Image, opts points.Options, fileName string) error {
+	st, err := points.Analyze(img, opts)
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("%s:\n", fileName)
+	fmt.Printf("  grid:    %d x %d, %d boxes\n", st.Cols, st.Rows, st.Boxes)
+	fmt.Printf("  dots:    %d, %d boxes skipped\n", st.Dots, st.Skipped)
+	fmt.Printf("  radius:  min %d, max %d, mean %.1f\n", st.MinRadius, st.MaxRadius, st.MeanRadius)
+	fmt.Printf("  size:    %d bytes\n", st.Size)
+	return nil
+}
+
+// processDir renders every image in dir and its subdirectories.  The
+// outputs are written next to the images, or into the same place
+// under outDir if it is given.  Files that can't be decoded are
+// skipped with a warning rather than stopping the whole batch.
+func processDir(dir string, outDir string, opts points.Options, sizes []int) error {
+	// We don't want to pick up our own output if outDir is inside
+	// dir.
+	skipDir := ""
+	if outDir != "" {
+		abs, err := filepath.Abs(outDir)
+		if err != nil {
+			return err
+		}
+		skipDir = abs
+	}
+
+	return filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+
+		if d.IsDir() {
+			if abs, err := filepath.Abs(path); err == nil && abs == skipDir && path != dir {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+
+		if !d.Type().IsRegular() {
+			return nil
+		}
+
+		out := strings.TrimSuffix(path, filepath.Ext(path)) + extension(opts.Format)
+		if outDir != "" {
+			rel, err := filepath.Rel(dir, out)
+			if err != nil {
+				return err
+			}
+			out = filepath.Join(outDir, rel)
+		}
+
+		if len(sizes) == 1 && out == path {
+			warnf("skipping %s: the output would overwrite it", path)
+			return nil
+		}
+
+		frames, err := readFrames(path)
+		if err != nil {
+			warnf("skipping %s: %v", path, err)
+			return nil
+		}
+
+		if err := os.MkdirAll(filepath.Dir(out), 0755); err != nil {
+			return err
+		}
+
+		return writeFrames(frames, opts, sizes, path, out)
+	})
+}
+
+// warnf prints a warning to stderr unless -quiet is given.
+func warnf(format string, args ...interface{}) {
+	if *quiet {
+		return
+	}
+	fmt.Fprintf(os.Stderr, "points: "+format+"\n", args...)
+}
+
+// progressPrinter returns a progress function that prints the
+// percentage done to stderr.  To avoid spamming the terminal it only
+// prints a few times per second, but it always prints when it is
+// done.
+func progressPrinter() func(done int, total int) {
+	var last time.Time
+	return func(done int, total int) {
+		if done < total && time.Since(last) < 250*time.Millisecond {
+			return
+		}
+		last = time.Now()
+
+		fmt.Fprintf(os.Stderr, "\r%3d%%", done*100/total)
+		if done == total {
+			fmt.Fprintln(os.Stderr)
+		}
+	}
+}
+
+// render streams the output to w through a buffer so we don't issue a
+// write call for every element.
+func render(img image.Image, opts points.Options, w io.Writer) error {
+	bw := bufio.NewWriter(w)
+	if err := points.Render(img, opts, bw); err != nil {
+		return err
+	}
+	return bw.Flush()
+}
+
+// usageError is an error in how the program was called, such as an
+// invalid flag, as opposed to something that went wrong while it was
+// running.
+type usageError struct {
+	error
+}
+
+// buildOptions turns the flags into rendering options and the list of
+// box sizes to render.
+func buildOptions() (points.Options, []int, error) {
+	opts := points.Options{
+		BoxWidth:         *boxWidth,
+		BoxHeight:        *boxHeight,
+		Density:          *density,
+		Scale:            *scale,
+		CanvasScale:      *canvasScale,
+		MaxDim:           *maxDim,
+		Filter:           *filter,
+		LumaThreshold:    *lumaThreshold,
+		LumaThresholdLow: *thresholdLow,
+		AutoThreshold:    *autoThreshold,
+		Coverage:         *coverage,
+		Color:            *color,
+		Luma:             *luma,
+		Channel:          *channel,
+		Metric:           *metric,
+		BlackPoint:       *blackPoint,
+		WhitePoint:       *whitePoint,
+		Equalize:         *equalize,
+		Edges:            *edges,
+		DotGamma:         *dotGamma,
+		Opacity:          *opacity,
+		Vignette:         *vignette,
+		LumaArea:         *lumaArea,
+		AreaScale:        *areaScale,
+		Shape:            *shape,
+		StarRatio:        *starRatio,
+		LineWidth:        *lineWidth,
+		StrokeWidth:      *strokeWidth,
+		Concentric:       *concentric,
+		Soft:             *soft,
+		CSS:              *css,
+		Orient:           *orient,
+		Hex:              *hex,
+		Checker:          *checker,
+		CheckerOdd:       *checkerOdd,
+		Tileable:         *tileable,
+		Workers:          *workers,
+		Format:           *format,
+		Supersample:      *supersample,
+		Ramp:             *ramp,
+		CharAspect:       *charAspect,
+		Responsive:       *responsive,
+		Minify:           *minify,
+		DPI:              *dpi,
+		Unit:             *unit,
+		ColorMode:        *colorMode,
+		Gray:             *gray,
+		HSL:              *hue >= 0,
+		Hue:              *hue,
+		Saturation:       *saturation,
+		ColorSpace:       *colorSpace,
+		Linear:           *linear,
+		PaletteLab:       *paletteLab,
+		CMYK:             *cmyk,
+		Samples:          *samples,
+		CircularSample:   *circular,
+		AlphaCutoff:      *alphaCutoff,
+		Invert:           *invert,
+		MinRadius:        *minRadius,
+		MaxRadius:        *maxRadius,
+		NoOverlap:        *noOverlap,
+		Precision:        *precision,
+		MaxDots:          *maxDots,
+		MergeFlat:        *mergeFlat,
+		Adaptive:         *adaptive,
+		Variance:         *variance,
+		MinBox:           *minBox,
+		Stipple:          *stipple,
+		Poisson:          *poisson,
+		Spiral:           *spiral,
+		SpiralSpacing:    *spiralSpacing,
+		SpiralStep:       *spiralStep,
+		Rosette:          *rosette,
+		Contour:          *contour,
+		Jitter:           *jitter,
+		Seed:             *seed,
+	}
+
+	if opts.Format == "" {
+		opts.Format = formatFromName(*outputFile)
+	}
+
+	switch *rotation {
+	case 0, 90, 180, 270:
+	default:
+		return opts, nil, usageError{fmt.Errorf("invalid rotation %d, must be 0, 90, 180 or 270", *rotation)}
+	}
+
+	switch *flipAxis {
+	case "", "h", "v":
+	default:
+		return opts, nil, usageError{fmt.Errorf("invalid flip %q, must be h or v", *flipAxis)}
+	}
+
+	if *cropRegion != "" {
+		r, err := parseCrop(*cropRegion)
+		if err != nil {
+			return opts, nil, usageError{fmt.Errorf("invalid crop: %v", err)}
+		}
+		opts.Crop = r
+	}
+
+	if *date != "" {
+		d, err := time.Parse(time.RFC3339, *date)
+		if err != nil {
+			return opts, nil, usageError{fmt.Errorf("invalid date: %v", err)}
+		}
+		opts.Date = d
+	}
+
+	if *progress && !*quiet {
+		opts.Progress = progressPrinter()
+	}
+
+	// -thigh is -t by another name, and wins if both are given
+	flag.Visit(func(f *flag.Flag) {
+		if f.Name == "thigh" {
+			opts.LumaThreshold = *thresholdHigh
+		}
+	})
+
+	if *bt701 {
+		opts.Luma = points.LumaBT709
+	}
+
+	if *lumaWeights != "" {
+		w, err := parseWeights(*lumaWeights)
+		if err != nil {
+			return opts, nil, usageError{fmt.Errorf("invalid weights: %v", err)}
+		}
+		opts.LumaWeights = w
+	}
+
+	if *paletteSpec != "" {
+		p, err := points.ParsePalette(*paletteSpec)
+		if err != nil {
+			return opts, nil, usageError{fmt.Errorf("invalid palette: %v", err)}
+		}
+		opts.Palette = p
+	}
+
+	if *paletteFile != "" {
+		if *paletteSpec != "" {
+			return opts, nil, usageError{errors.New("-palette and -palettefile cannot be combined")}
+		}
+
+		// Swatches from Photoshop are .aco, anything else is taken to
+		// be a GIMP palette.
+		data, err := readData(*paletteFile)
+		if err == nil {
+			read := points.ReadGPL
+			if strings.ToLower(filepath.Ext(*paletteFile)) == ".aco" {
+				read = points.ReadACO
+			}
+			opts.Palette, err = read(bytes.NewReader(data))
+		}
+		if err != nil {
+			return opts, nil, usageError{fmt.Errorf("invalid palette file %s: %v", *paletteFile, err)}
+		}
+	}
+
+	if *background != "" {
+		bg, err := points.ParseHexColor(*background)
+		if err != nil {
+			return opts, nil, usageError{fmt.Errorf("invalid background: %v", err)}
+		}
+		opts.Background = bg
+	}
+
+	if *stroke != "" {
+		c, err := points.ParseHexColor(*stroke)
+		if err != nil {
+			return opts, nil, usageError{fmt.Errorf("invalid stroke: %v", err)}
+		}
+		opts.Stroke = c
+	}
+
+	sizes, err := parseBoxSizes(*boxSizes)
+	if err != nil {
+		return opts, nil, usageError{fmt.Errorf("invalid box size: %v", err)}
+	}
+
+	for _, size := range sizes {
+		opts.BoxSize = size
+		if err := opts.Validate(); err != nil {
+			return opts, nil, usageError{fmt.Errorf("invalid options: %v", err)}
+		}
+	}
+
+	if len(sizes) > 1 && *outputFile == "-" {
+		return opts, nil, usageError{errors.New("cannot write more than one box size to stdout")}
+	}
+
+	return opts, sizes, nil
+}
+
+// run does the actual work of the program.  Errors are returned
+// rather than handled here so main can print them in one place.
+func run() error {
+	if *configFile != "" {
+		if err := loadConfig(*configFile); err != nil {
+			return usageError{fmt.Errorf("invalid config %s: %v", *configFile, err)}
+		}
+
+		// The flags on the command line win over the config file
+		flag.Parse()
+	}
+
+	if *showVersion {
+		fmt.Print(version())
+		return nil
+	}
+
+	if *serveAddr != "" && *listFile != "" {
+		return usageError{errors.New("-serve and -list cannot be combined")}
+	}
+
+	if *listFile != "" {
+		if *inputFile != "" {
+			return usageError{errors.New("-list and -f cannot be combined")}
+		}
+
+		if *outputFile != "" {
+			return usageError{errors.New("use -outdir, or o= on the lines of the list, rather than -o with -list")}
+		}
+	}
+
+	if *inputFile == "" && *listFile == "" && stdinIsPiped() {
+		*inputFile = "-"
+	}
+
+	if *inputFile == "" && *listFile == "" {
+		flag.Usage()
+		return nil
+	}
+
+	opts, sizes, err := buildOptions()
+	if err != nil {
+		return err
+	}
+
+	if *colorFrom != "" {
+		img, err := readImage(*colorFrom)
+		if err != nil {
+			return fmt.Errorf("error reading color image %s: %v", *colorFrom, err)
+		}
+		opts.ColorImage = img
+
+		if err := opts.Validate(); err != nil {
+			return usageError{fmt.Errorf("invalid options: %v", err)}
+		}
+	}
+
+	if *mask != "" {
+		img, err := readImage(*mask)
+		if err != nil {
+			return fmt.Errorf("error reading mask %s: %v", *mask, err)
+		}
+		opts.Mask = img
+	}
+
+	if *listFile != "" {
+		return processList(*listFile, *outputDir, opts)
+	}
+
+	if *serveAddr != "" {
+		return serve(*serveAddr, *inputFile, opts)
+	}
+
+	if *inputFile != "-" && !isURL(*inputFile) {
+		fi, err := os.Stat(*inputFile)
+		if err != nil {
+			return fmt.Errorf("error reading image %s: %v", *inputFile, err)
+		}
+
+		if fi.IsDir() {
+			if *outputFile != "" {
+				return usageError{errors.New("use -outdir rather than -o when -f is a directory")}
+			}
+
+			if err := processDir(*inputFile, *outputDir, opts, sizes); err != nil {
+				return fmt.Errorf("error processing %s: %v", *inputFile, err)
+			}
+			return nil
+		}
+	}
+
+	if *allFrames && *outputFile == "-" {
+		return usageError{errors.New("-allframes needs an output file rather than stdout")}
+	}
+
+	frames, err := readFrames(*inputFile)
+	if err != nil {
+		return fmt.Errorf("error reading image %s: %v", *inputFile, err)
+	}
+
+	if *outputFile == "" {
+		fn := outputName(*inputFile, opts.Format)
+		outputFile = &fn
+	}
+
+	// The image is only decoded once however many box sizes we
+	// render it with.
+	return writeFrames(frames, opts, sizes, *inputFile, *outputFile)
+}
+
+// exitCode returns the exit status for the error returned by run: 0
+// for no error, 2 for a usage error and 1 for anything else.
+func exitCode(err error) int {
+	switch err.(type) {
+	case nil:
+		return 0
+	case usageError:
+		return 2
+	}
+	return 1
+}
+
+func main() {
+	flag.Parse()
+
+	err := run()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "points: %v\n", err)
+	}
+	os.Exit(exitCode(err))
+}
@@ -0,0 +1,597 @@
+// Copyright Bjørn Borud 2019 Use of this source code is governed by
+// the license found in the accompanying LICENSE file.
+
+package main
+
+import (
+	"bytes"
+	"image"
+	"image/png"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/borud/points"
+	"golang.org/x/image/bmp"
+	"golang.org/x/image/tiff"
+)
+
+func TestReadStdin(t *testing.T) {
+	f, err := ioutil.TempFile("", "points-stdin")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(f.Name())
+	defer f.Close()
+
+	if err := png.Encode(f, image.NewGray(image.Rect(0, 0, 30, 20))); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := f.Seek(0, 0); err != nil {
+		t.Fatal(err)
+	}
+
+	stdin := os.Stdin
+	os.Stdin = f
+	defer func() { os.Stdin = stdin }()
+
+	img, err := readImage("-")
+	if err != nil {
+		t.Fatalf("readImage: %v", err)
+	}
+
+	if got := img.Bounds(); got != image.Rect(0, 0, 30, 20) {
+		t.Errorf("expected a 30x20 image, got %v", got)
+	}
+}
+
+func TestOutputName(t *testing.T) {
+	tests := []struct {
+		input  string
+		format string
+		want   string
+	}{
+		{"-", "svg", "out.svg"},
+		{"mona.jpg", "svg", "mona.svg"},
+		{"images/mona.jpg", "png", "images/mona.png"},
+		{"mona.jpg", "ascii", "mona.txt"},
+		{"https://example.com/images/mona.jpg", "svg", "mona.svg"},
+		{"https://example.com/", "svg", "out.svg"},
+	}
+
+	for _, test := range tests {
+		if got := outputName(test.input, test.format); got != test.want {
+			t.Errorf("outputName(%q, %q) = %q, expected %q", test.input, test.format, got, test.want)
+		}
+	}
+}
+
+func TestWriteOutputStdout(t *testing.T) {
+	f, err := ioutil.TempFile("", "points-stdout")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(f.Name())
+	defer f.Close()
+
+	stdout := os.Stdout
+	os.Stdout = f
+	err = writeOutput(image.NewGray(image.Rect(0, 0, 30, 20)), points.DefaultOptions(), "-")
+	os.Stdout = stdout
+	if err != nil {
+		t.Fatalf("writeOutput: %v", err)
+	}
+
+	data, err := ioutil.ReadFile(f.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(data), "<svg") || !strings.HasSuffix(string(data), "</svg>\n") {
+		t.Errorf("expected an svg on stdout, got %q", data)
+	}
+
+	// Nothing named - should have been created
+	if _, err := os.Stat("-"); err == nil {
+		t.Errorf("expected no file named -")
+	}
+}
+
+func TestWriteOutputFile(t *testing.T) {
+	dir, err := ioutil.TempDir("", "points")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	img := image.NewGray(image.Rect(0, 0, 30, 20))
+
+	fn := filepath.Join(dir, "out.svg")
+	if err := writeOutput(img, points.DefaultOptions(), fn); err != nil {
+		t.Fatalf("writeOutput: %v", err)
+	}
+	if data, err := ioutil.ReadFile(fn); err != nil || !strings.Contains(string(data), "<svg") {
+		t.Errorf("expected an svg in %s, got %q, %v", fn, data, err)
+	}
+
+	// A failed render leaves no file behind
+	opts := points.DefaultOptions()
+	opts.BoxSize = 0
+	fn = filepath.Join(dir, "bad.svg")
+	if err := writeOutput(img, opts, fn); err == nil {
+		t.Errorf("expected an error for an invalid box size")
+	}
+	if _, err := os.Stat(fn); err == nil {
+		t.Errorf("expected %s to be removed", fn)
+	}
+}
+
+func TestFormatFromName(t *testing.T) {
+	tests := []struct {
+		name string
+		want string
+	}{
+		{"out.svg", points.FormatSVG},
+		{"out.PNG", points.FormatPNG},
+		{"out.txt", points.FormatASCII},
+		{"out.pdf", points.FormatPDF},
+		{"out.six", points.FormatSixel},
+		{"out.sixel", points.FormatSixel},
+		{"out.json", points.FormatJSON},
+		{"out.gif", points.FormatSVG},
+		{"-", points.FormatSVG},
+	}
+
+	for _, test := range tests {
+		if got := formatFromName(test.name); got != test.want {
+			t.Errorf("formatFromName(%q) = %q, expected %q", test.name, got, test.want)
+		}
+	}
+
+	// The names we give outputs have to be read back as the same
+	// format
+	for _, format := range []string{points.FormatSVG, points.FormatPNG, points.FormatASCII, points.FormatPDF, points.FormatSixel, points.FormatJSON} {
+		if got := formatFromName(outputName("mona.jpg", format)); got != format {
+			t.Errorf("expected %s output to be read back as %s, got %s", format, format, got)
+		}
+	}
+}
+
+// tinyWebP is a lossless 3x2 WebP filled with #204080.
+var tinyWebP = []byte{
+	0x52, 0x49, 0x46, 0x46, 0x18, 0x00, 0x00, 0x00, 0x57, 0x45, 0x42, 0x50,
+	0x56, 0x50, 0x38, 0x4c, 0x0c, 0x00, 0x00, 0x00, 0x2f, 0x02, 0x40, 0x00,
+	0x00, 0x28, 0x50, 0x41, 0x0a, 0xd8, 0xff, 0x00,
+}
+
+func TestDecodeWebPAndBMP(t *testing.T) {
+	// The color package is hidden by the -c flag, so the pixel is
+	// set directly
+	src := image.NewRGBA(image.Rect(0, 0, 5, 4))
+	copy(src.Pix[src.PixOffset(4, 3):], []uint8{0x20, 0x40, 0x80, 0xff})
+
+	var bmpData bytes.Buffer
+	if err := bmp.Encode(&bmpData, src); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name string
+		data []byte
+		size image.Rectangle
+		at   image.Point
+	}{
+		{"webp", tinyWebP, image.Rect(0, 0, 3, 2), image.Pt(2, 1)},
+		{"bmp", bmpData.Bytes(), image.Rect(0, 0, 5, 4), image.Pt(4, 3)},
+	}
+
+	for _, test := range tests {
+		img, err := decodeImage(test.data)
+		if err != nil {
+			t.Errorf("%s: %v", test.name, err)
+			continue
+		}
+
+		if got := img.Bounds(); got != test.size {
+			t.Errorf("%s: expected %v, got %v", test.name, test.size, got)
+		}
+
+		r, g, b, a := img.At(test.at.X, test.at.Y).RGBA()
+		if r>>8 != 0x20 || g>>8 != 0x40 || b>>8 != 0x80 || a != 0xffff {
+			t.Errorf("%s: expected #204080 at %v, got %04x %04x %04x %04x", test.name, test.at, r, g, b, a)
+		}
+	}
+}
+
+func TestDecode16BitTIFF(t *testing.T) {
+	// 0xff00 is 255 in its high byte but only 254 when divided by
+	// 0x101, the way 8 bit values are scaled up to 16 bits
+	src := image.NewRGBA64(image.Rect(0, 0, 10, 10))
+	for i := 0; i < len(src.Pix); i += 8 {
+		copy(src.Pix[i:], []uint8{0xff, 0x00, 0, 0, 0, 0, 0xff, 0xff})
+	}
+
+	var data bytes.Buffer
+	if err := tiff.Encode(&data, src, nil); err != nil {
+		t.Fatal(err)
+	}
+
+	img, err := decodeImage(data.Bytes())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if r, _, _, _ := img.At(0, 0).RGBA(); r != 0xff00 {
+		t.Errorf("expected the 16 bits to be kept, got %04x", r)
+	}
+
+	opts := points.DefaultOptions()
+	opts.BoxSize = 10
+	opts.Format = points.FormatJSON
+
+	out, err := points.RenderBytes(img, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(out), `"color":"#ff0000"`) {
+		t.Errorf("expected the dot to be #ff0000, got %s", out)
+	}
+}
+
+// fileNames returns the sorted names of the files in dir.
+func fileNames(t *testing.T, dir string) []string {
+	infos, err := ioutil.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var names []string
+	for _, info := range infos {
+		names = append(names, info.Name())
+	}
+	return names
+}
+
+func TestWriteSizes(t *testing.T) {
+	img := image.NewGray(image.Rect(0, 0, 60, 60))
+
+	tests := []struct {
+		sizes string
+		want  []string
+	}{
+		{"20", []string{"out.svg"}},
+		{"10,20,30", []string{"out-b10.svg", "out-b20.svg", "out-b30.svg"}},
+		{"5, 15", []string{"out-b15.svg", "out-b5.svg"}},
+	}
+
+	for _, test := range tests {
+		dir, err := ioutil.TempDir("", "points")
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer os.RemoveAll(dir)
+
+		sizes, err := parseBoxSizes(test.sizes)
+		if err != nil {
+			t.Fatalf("%s: %v", test.sizes, err)
+		}
+
+		if err := writeSizes(img, points.DefaultOptions(), sizes, "in.png", filepath.Join(dir, "out.svg")); err != nil {
+			t.Fatalf("%s: writeSizes: %v", test.sizes, err)
+		}
+
+		got := fileNames(t, dir)
+		if strings.Join(got, " ") != strings.Join(test.want, " ") {
+			t.Errorf("%s: expected %v, got %v", test.sizes, test.want, got)
+		}
+	}
+
+	if _, err := parseBoxSizes("10,x"); err == nil {
+		t.Errorf("expected an error for a size that isn't a number")
+	}
+}
+
+func TestProcessDir(t *testing.T) {
+	dir, err := ioutil.TempDir("", "points")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	for _, name := range []string{"a.png", "b.png"} {
+		var buf bytes.Buffer
+		if err := png.Encode(&buf, image.NewGray(image.Rect(0, 0, 30, 20))); err != nil {
+			t.Fatal(err)
+		}
+		if err := ioutil.WriteFile(filepath.Join(dir, name), buf.Bytes(), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := ioutil.WriteFile(filepath.Join(dir, "junk.png"), []byte("not an image"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	// The junk file would otherwise print a warning
+	q := *quiet
+	*quiet = true
+	defer func() { *quiet = q }()
+
+	opts := points.DefaultOptions()
+	if err := processDir(dir, "", opts, []int{opts.BoxSize}); err != nil {
+		t.Fatalf("processDir: %v", err)
+	}
+
+	want := []string{"a.png", "a.svg", "b.png", "b.svg", "junk.png"}
+	if got := fileNames(t, dir); strings.Join(got, " ") != strings.Join(want, " ") {
+		t.Errorf("expected %v, got %v", want, got)
+	}
+
+	// With an output directory the images are left alone
+	out := filepath.Join(dir, "out")
+	if err := processDir(dir, out, opts, []int{opts.BoxSize}); err != nil {
+		t.Fatalf("processDir: %v", err)
+	}
+
+	want = []string{"a.svg", "b.svg"}
+	if got := fileNames(t, out); strings.Join(got, " ") != strings.Join(want, " ") {
+		t.Errorf("expected %v in %s, got %v", want, out, got)
+	}
+}
+
+func TestRunErrors(t *testing.T) {
+	dir, err := ioutil.TempDir("", "points")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	tests := []struct {
+		name     string
+		settings []string
+		want     int
+	}{
+		{"list and input", []string{"list=files.txt", "f=in.png"}, 2},
+		{"invalid option", []string{"f=in.png", "b=0"}, 2},
+		{"missing input", []string{"f=" + filepath.Join(dir, "missing.png")}, 1},
+	}
+
+	for _, test := range tests {
+		restore, err := setFlags(test.settings, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		err = run()
+		restore()
+
+		if got := exitCode(err); got != test.want {
+			t.Errorf("%s: expected exit code %d, got %d for %v", test.name, test.want, got, err)
+		}
+	}
+
+	if got := exitCode(nil); got != 0 {
+		t.Errorf("expected exit code 0 without an error, got %d", got)
+	}
+}
+
+func TestQuiet(t *testing.T) {
+	f, err := ioutil.TempFile("", "points-stderr")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(f.Name())
+	defer f.Close()
+
+	stderr := os.Stderr
+	os.Stderr = f
+	defer func() { os.Stderr = stderr }()
+
+	q := *quiet
+	defer func() { *quiet = q }()
+
+	*quiet = true
+	warnf("hidden")
+	*quiet = false
+	warnf("shown")
+
+	data, err := ioutil.ReadFile(f.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if got := string(data); got != "points: shown\n" {
+		t.Errorf("expected only the warning given without -quiet, got %q", got)
+	}
+}
+
+func TestBoxWidthAndHeight(t *testing.T) {
+	restore, err := setFlags([]string{"bw=20", "bh=40"}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer restore()
+
+	opts, _, err := buildOptions()
+	if err != nil {
+		t.Fatalf("buildOptions: %v", err)
+	}
+
+	if opts.BoxWidth != 20 || opts.BoxHeight != 40 {
+		t.Errorf("expected 20x40 boxes, got %dx%d", opts.BoxWidth, opts.BoxHeight)
+	}
+}
+
+func TestDescribe(t *testing.T) {
+	tests := []struct {
+		input string
+		title string
+		want  string
+	}{
+		{"photos/cat.png", "", "cat.png"},
+		{"-", "", "stdin"},
+		{"photos/cat.png", "My cat", "My cat"},
+	}
+
+	for _, test := range tests {
+		restore, err := setFlags([]string{"title=" + test.title}, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		opts := points.DefaultOptions()
+		describe(&opts, test.input)
+		restore()
+
+		if opts.Title != test.want {
+			t.Errorf("%s: expected the title %q, got %q", test.input, test.want, opts.Title)
+		}
+		if !strings.Contains(opts.Comment, "box size: 50") {
+			t.Errorf("%s: expected the box size in the comment, got %q", test.input, opts.Comment)
+		}
+	}
+}
+
+func TestReadURL(t *testing.T) {
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, image.NewGray(image.Rect(0, 0, 30, 20))); err != nil {
+		t.Fatal(err)
+	}
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/image.png":
+			w.Header().Set("Content-Type", "image/png")
+			w.Write(buf.Bytes())
+		case "/page.html":
+			w.Header().Set("Content-Type", "text/html")
+			w.Write([]byte("<html></html>"))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer srv.Close()
+
+	img, err := readImage(srv.URL + "/image.png")
+	if err != nil {
+		t.Fatalf("readImage: %v", err)
+	}
+	if got := img.Bounds(); got != image.Rect(0, 0, 30, 20) {
+		t.Errorf("expected a 30x20 image, got %v", got)
+	}
+
+	for _, name := range []string{"/page.html", "/missing.png"} {
+		if _, err := readImage(srv.URL + name); err == nil {
+			t.Errorf("%s: expected an error", name)
+		}
+	}
+}
+
+func TestTurn(t *testing.T) {
+	// 3x2 with only the top left corner white
+	img := image.NewGray(image.Rect(0, 0, 3, 2))
+	img.Pix[img.PixOffset(0, 0)] = 0xff
+
+	tests := []struct {
+		rotation int
+		flip     string
+		size     image.Point
+		corner   image.Point
+	}{
+		{0, "", image.Pt(3, 2), image.Pt(0, 0)},
+		{90, "", image.Pt(2, 3), image.Pt(1, 0)},
+		{180, "", image.Pt(3, 2), image.Pt(2, 1)},
+		{270, "", image.Pt(2, 3), image.Pt(0, 2)},
+		{0, "h", image.Pt(3, 2), image.Pt(2, 0)},
+		{0, "v", image.Pt(3, 2), image.Pt(0, 1)},
+		{90, "h", image.Pt(2, 3), image.Pt(0, 0)},
+	}
+
+	for _, test := range tests {
+		turned := turn(img, test.rotation, test.flip)
+		b := turned.Bounds()
+
+		if b.Size() != test.size {
+			t.Errorf("%d %q: expected %v, got %v", test.rotation, test.flip, test.size, b.Size())
+			continue
+		}
+
+		for y := 0; y < b.Dy(); y++ {
+			for x := 0; x < b.Dx(); x++ {
+				r, _, _, _ := turned.At(b.Min.X+x, b.Min.Y+y).RGBA()
+				if white := r == 0xffff; white != (image.Pt(x, y) == test.corner) {
+					t.Errorf("%d %q: expected the corner at %v, got white %v at %d,%d", test.rotation, test.flip, test.corner, white, x, y)
+				}
+			}
+		}
+	}
+}
+
+func TestReproduciblePDF(t *testing.T) {
+	dir, err := ioutil.TempDir("", "points")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	in := filepath.Join(dir, "in.png")
+	f, err := os.Create(in)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = png.Encode(f, image.NewGray(image.Rect(0, 0, 30, 20)))
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		date string
+		want string
+	}{
+		{"", "(D:19700101000000)"},
+		{"2019-06-01T12:00:00Z", "(D:20190601120000)"},
+	}
+
+	for _, test := range tests {
+		// Render the same PDF twice
+		var outs [2][]byte
+		for i := range outs {
+			fn := filepath.Join(dir, "out.pdf")
+			restore, err := setFlags([]string{"f=" + in, "o=" + fn, "b=10", "date=" + test.date}, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			err = run()
+			restore()
+			if err != nil {
+				t.Fatalf("%q: %v", test.date, err)
+			}
+
+			if outs[i], err = ioutil.ReadFile(fn); err != nil {
+				t.Fatal(err)
+			}
+		}
+
+		if !bytes.Equal(outs[0], outs[1]) {
+			t.Errorf("%q: expected the same PDF from both runs", test.date)
+		}
+		if !bytes.Contains(outs[0], []byte("/CreationDate "+test.want)) {
+			t.Errorf("%q: expected the creation date %s", test.date, test.want)
+		}
+	}
+
+	restore, err := setFlags([]string{"f=" + in, "date=yesterday"}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = run()
+	restore()
+	if got := exitCode(err); got != 2 {
+		t.Errorf("expected exit code 2 for an invalid date, got %d for %v", got, err)
+	}
+}
@@ -0,0 +1,151 @@
+// Copyright Bjørn Borud 2019 Use of this source code is governed by
+// the license found in the accompanying LICENSE file.
+
+package main
+
+import (
+	"errors"
+	"fmt"
+	"image"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/borud/points"
+)
+
+// serveOnly are the flags that are read once when the server starts,
+// so they can't be given in the query of a request.
+var serveOnly = map[string]bool{
+	"config":    true,
+	"serve":     true,
+	"list":      true,
+	"f":         true,
+	"o":         true,
+	"outdir":    true,
+	"colorfrom": true,
+	"mask":      true,
+	"allframes": true,
+	"stats":     true,
+	"progress":  true,
+	"quiet":     true,
+	"version":   true,
+}
+
+// contentTypes are the content types of the output formats.
+var contentTypes = map[string]string{
+	points.FormatSVG:   "image/svg+xml",
+	points.FormatPNG:   "image/png",
+	points.FormatPDF:   "application/pdf",
+	points.FormatJSON:  "application/json",
+	points.FormatASCII: "text/plain; charset=utf-8",
+	points.FormatSixel: "text/plain; charset=utf-8",
+}
+
+// previewServer renders the same image on every request, with the
+// flags given in the query, like ?b=30&t=0.8&c=false, set on top of
+// those of the command line.  A flag given without a value is set to
+// true.  The image is only decoded once, when the server starts.
+type previewServer struct {
+	img       image.Image
+	inputName string
+	base      points.Options
+
+	// The flags are global, so only one request at a time may set
+	// them and build its options from them.
+	mu sync.Mutex
+}
+
+func (s *previewServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	opts, img, err := s.options(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	// Render checks the options and lays out the dots before it
+	// writes anything, so errors up to then can still be reported.
+	w.Header().Set("Content-Type", contentTypes[opts.Format])
+	if err := render(img, opts, w); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
+
+// options builds the options of a request from its query, and returns
+// them along with the image turned the way the request asks.
+func (s *previewServer) options(r *http.Request) (points.Options, image.Image, error) {
+	query := r.URL.Query()
+
+	names := make([]string, 0, len(query))
+	for name := range query {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var settings []string
+	for _, name := range names {
+		for _, value := range query[name] {
+			if value == "" {
+				settings = append(settings, name)
+				continue
+			}
+			settings = append(settings, name+"="+value)
+		}
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	restore, err := setFlags(settings, serveOnly)
+	defer restore()
+	if err != nil {
+		return points.Options{}, nil, err
+	}
+
+	opts, sizes, err := buildOptions()
+	if err != nil {
+		return points.Options{}, nil, err
+	}
+
+	if len(sizes) > 1 {
+		return points.Options{}, nil, errors.New("only one box size can be given")
+	}
+	opts.BoxSize = sizes[0]
+
+	opts.ColorImage = s.base.ColorImage
+	opts.Mask = s.base.Mask
+	if err := opts.Validate(); err != nil {
+		return points.Options{}, nil, fmt.Errorf("invalid options: %v", err)
+	}
+
+	describe(&opts, s.inputName)
+	return opts, turn(s.img, *rotation, *flipAxis), nil
+}
+
+// serve decodes the named image and serves previews of it on addr
+// until the server fails.
+func serve(addr string, inputName string, base points.Options) error {
+	img, err := readImage(inputName)
+	if err != nil {
+		return fmt.Errorf("error reading image %s: %v", inputName, err)
+	}
+
+	warnf("serving %s on %s", inputName, addr)
+	return newHTTPServer(addr, &previewServer{img: img, inputName: inputName, base: base}).ListenAndServe()
+}
+
+// newHTTPServer returns a server for handler on addr with timeouts,
+// so slow or stalled clients can't hold connections open forever.
+// Writing gets more time than reading since a large preview can take
+// a while to render.
+func newHTTPServer(addr string, handler http.Handler) *http.Server {
+	return &http.Server{
+		Addr:              addr,
+		Handler:           handler,
+		ReadHeaderTimeout: 10 * time.Second,
+		ReadTimeout:       30 * time.Second,
+		WriteTimeout:      5 * time.Minute,
+		IdleTimeout:       2 * time.Minute,
+	}
+}
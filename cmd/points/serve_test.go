@@ -0,0 +1,103 @@
+// Copyright Bjørn Borud 2019 Use of this source code is governed by
+// the license found in the accompanying LICENSE file.
+
+package main
+
+import (
+	"bytes"
+	"encoding/xml"
+	"image"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestPreviewServer(t *testing.T) {
+	srv := httptest.NewServer(&previewServer{img: image.NewGray(image.Rect(0, 0, 200, 80)), inputName: "black.png"})
+	defer srv.Close()
+
+	tests := []struct {
+		query       string
+		status      int
+		contentType string
+		circles     int
+	}{
+		{"", http.StatusOK, "image/svg+xml", 8},
+		{"?b=40", http.StatusOK, "image/svg+xml", 10},
+		{"?b=40&format=png", http.StatusOK, "image/png", 0},
+		{"?b=10,20", http.StatusBadRequest, "", 0},
+		{"?o=out.svg", http.StatusBadRequest, "", 0},
+		{"?nosuchflag", http.StatusBadRequest, "", 0},
+	}
+
+	for _, test := range tests {
+		resp, err := http.Get(srv.URL + test.query)
+		if err != nil {
+			t.Fatal(err)
+		}
+		body, err := ioutil.ReadAll(resp.Body)
+		resp.Body.Close()
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if resp.StatusCode != test.status {
+			t.Errorf("%q: expected status %d, got %d: %s", test.query, test.status, resp.StatusCode, body)
+			continue
+		}
+		if test.status != http.StatusOK {
+			continue
+		}
+
+		if got := resp.Header.Get("Content-Type"); got != test.contentType {
+			t.Errorf("%q: expected %s, got %s", test.query, test.contentType, got)
+		}
+		if test.contentType != "image/svg+xml" {
+			continue
+		}
+
+		// The SVG has to parse, and has a dot for every box
+		circles := 0
+		dec := xml.NewDecoder(bytes.NewReader(body))
+		for {
+			tok, err := dec.Token()
+			if err == io.EOF {
+				break
+			}
+			if err != nil {
+				t.Fatalf("%q: expected valid SVG: %v", test.query, err)
+			}
+			if el, ok := tok.(xml.StartElement); ok && el.Name.Local == "circle" {
+				circles++
+			}
+		}
+		if circles != test.circles {
+			t.Errorf("%q: expected %d circles, got %d", test.query, test.circles, circles)
+		}
+	}
+
+	// The flags of a request don't carry over to the next one
+	if *boxSizes != "50" {
+		t.Errorf("expected -b to be put back to 50, got %s", *boxSizes)
+	}
+}
+
+func TestHTTPServerTimeouts(t *testing.T) {
+	h := &previewServer{}
+	srv := newHTTPServer(":8080", h)
+
+	if srv.Addr != ":8080" || srv.Handler != h {
+		t.Errorf("expected the server to serve the handler on :8080, got %q and %v", srv.Addr, srv.Handler)
+	}
+	if srv.ReadHeaderTimeout <= 0 || srv.ReadTimeout <= 0 || srv.WriteTimeout <= 0 || srv.IdleTimeout <= 0 {
+		t.Errorf("expected every timeout to be set, got %v, %v, %v and %v", srv.ReadHeaderTimeout, srv.ReadTimeout, srv.WriteTimeout, srv.IdleTimeout)
+	}
+
+	// Rendering a preview takes a while, so writing it needs more time
+	// than reading the request
+	if srv.WriteTimeout <= srv.ReadTimeout {
+		t.Errorf("expected the write timeout %v to be longer than the read timeout %v", srv.WriteTimeout, srv.ReadTimeout)
+	}
+}
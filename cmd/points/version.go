@@ -0,0 +1,52 @@
+// Copyright Bjørn Borud 2019 Use of this source code is governed by
+// the license found in the accompanying LICENSE file.
+
+package main
+
+import (
+	"fmt"
+	"runtime"
+	"runtime/debug"
+	"strings"
+)
+
+// version describes the build for -version.  The module version is
+// the one given to go install.  Builds from a checkout get a pseudo
+// version, or "(devel)" with older versions of Go, so the commit and
+// time the go tool stamps the binary with are given as well.
+func version() string {
+	var b strings.Builder
+
+	mod := "unknown"
+	var revision, when string
+	var modified bool
+
+	if info, ok := debug.ReadBuildInfo(); ok {
+		mod = info.Main.Version
+
+		for _, s := range info.Settings {
+			switch s.Key {
+			case "vcs.revision":
+				revision = s.Value
+			case "vcs.time":
+				when = s.Value
+			case "vcs.modified":
+				modified = s.Value == "true"
+			}
+		}
+	}
+
+	fmt.Fprintf(&b, "points %s\n", mod)
+	if revision != "" {
+		if modified {
+			revision += " (modified)"
+		}
+		fmt.Fprintf(&b, "  commit: %s\n", revision)
+	}
+	if when != "" {
+		fmt.Fprintf(&b, "  time:   %s\n", when)
+	}
+	fmt.Fprintf(&b, "  go:     %s %s/%s\n", runtime.Version(), runtime.GOOS, runtime.GOARCH)
+
+	return b.String()
+}
@@ -0,0 +1,23 @@
+// Copyright Bjørn Borud 2019 Use of this source code is governed by
+// the license found in the accompanying LICENSE file.
+
+package main
+
+import (
+	"runtime"
+	"strings"
+	"testing"
+)
+
+func TestVersion(t *testing.T) {
+	out := version()
+
+	if !strings.HasPrefix(out, "points ") {
+		t.Errorf("expected the output to start with the name, got %q", out)
+	}
+
+	goVersion := "  go:     " + runtime.Version() + " " + runtime.GOOS + "/" + runtime.GOARCH + "\n"
+	if !strings.HasSuffix(out, goVersion) {
+		t.Errorf("expected the output to end with %q, got %q", goVersion, out)
+	}
+}
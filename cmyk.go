@@ -0,0 +1,69 @@
+// Copyright Bjørn Borud 2019 Use of this source code is governed by
+// the license found in the accompanying LICENSE file.
+
+package points
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+)
+
+// cmykColor is the color of a dot along with its CMYK equivalent.  It
+// is what the canvases are given when Options.CMYK is set, and
+// behaves like the RGBA color to those that don't know about CMYK.
+type cmykColor struct {
+	rgba color.RGBA
+	cmyk color.CMYK
+}
+
+func (c cmykColor) RGBA() (uint32, uint32, uint32, uint32) {
+	return c.rgba.RGBA()
+}
+
+// dotCMYK returns the CMYK color of a dot whose box has the RGB color
+// c.  For CMYK images the ink values of the box are averaged, so they
+// make it to the output without going through RGB.  Otherwise, or if
+// the color was snapped to a palette or taken from the color image, c
+// is converted.  The box is in image coordinates.
+func dotCMYK(img image.Image, box image.Rectangle, c color.RGBA, opts Options) color.CMYK {
+	if src, ok := img.(*image.CMYK); ok && len(opts.Palette) == 0 && opts.ColorImage == nil {
+		return meanCMYK(src, box)
+	}
+
+	cc, mm, yy, kk := color.RGBToCMYK(c.R, c.G, c.B)
+	return color.CMYK{C: cc, M: mm, Y: yy, K: kk}
+}
+
+// meanCMYK returns the average ink values of the pixels of img within
+// box.
+func meanCMYK(img *image.CMYK, box image.Rectangle) color.CMYK {
+	box = box.Intersect(img.Bounds())
+	pixels := box.Dx() * box.Dy()
+	if pixels == 0 {
+		return color.CMYK{}
+	}
+
+	var sum [4]int
+	for y := box.Min.Y; y < box.Max.Y; y++ {
+		for x := box.Min.X; x < box.Max.X; x++ {
+			i := img.PixOffset(x, y)
+			for j := range sum {
+				sum[j] += int(img.Pix[i+j])
+			}
+		}
+	}
+
+	return color.CMYK{
+		C: uint8(sum[0] / pixels),
+		M: uint8(sum[1] / pixels),
+		Y: uint8(sum[2] / pixels),
+		K: uint8(sum[3] / pixels),
+	}
+}
+
+// deviceCMYK formats c as an SVG device-cmyk color.
+func deviceCMYK(c color.CMYK) string {
+	return fmt.Sprintf("device-cmyk(%.3g,%.3g,%.3g,%.3g)",
+		float64(c.C)/0xff, float64(c.M)/0xff, float64(c.Y)/0xff, float64(c.K)/0xff)
+}
@@ -0,0 +1,113 @@
+// Copyright Bjørn Borud 2019 Use of this source code is governed by
+// the license found in the accompanying LICENSE file.
+
+package points
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"strings"
+	"testing"
+)
+
+// cmykJPEG returns an 8x8 Adobe CMYK JPEG filled with one color.  The
+// standard library can't encode CMYK, so it is put together by hand:
+// one block per channel that only has a DC coefficient.  Adobe stores
+// the inks inverted, and each inverted ink has to be within 1 to 64
+// or 192 to 255 so all the coefficients share one Huffman code.
+func cmykJPEG(c color.CMYK) []byte {
+	var b bytes.Buffer
+	b.Write([]byte{0xff, 0xd8})
+
+	// Adobe marker without a transform, so the channels are CMYK
+	b.Write([]byte{0xff, 0xee, 0x00, 0x0e, 'A', 'd', 'o', 'b', 'e', 0x00, 0x64, 0x00, 0x00, 0x00, 0x00, 0x00})
+
+	// A quantization table of all ones
+	b.Write([]byte{0xff, 0xdb, 0x00, 0x43, 0x00})
+	b.Write(bytes.Repeat([]byte{1}, 64))
+
+	// 8x8 pixels with four channels
+	b.Write([]byte{0xff, 0xc0, 0x00, 0x14, 0x08, 0x00, 0x08, 0x00, 0x08, 0x04,
+		1, 0x11, 0, 2, 0x11, 0, 3, 0x11, 0, 4, 0x11, 0})
+
+	// DC and AC tables with a single one bit code each: a DC
+	// difference of 10 bits and the end of the block.
+	b.Write([]byte{0xff, 0xc4, 0x00, 0x14, 0x00, 1, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 10})
+	b.Write([]byte{0xff, 0xc4, 0x00, 0x14, 0x10, 1, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0x00})
+
+	b.Write([]byte{0xff, 0xda, 0x00, 0x0e, 0x04, 1, 0x00, 2, 0x00, 3, 0x00, 4, 0x00, 0x00, 0x3f, 0x00})
+
+	var bits uint64
+	n := uint(0)
+	for _, ink := range []uint8{c.C, c.M, c.Y, c.K} {
+		dc := 8 * (int(0xff-ink) - 128)
+		if dc < 0 {
+			dc += 1<<10 - 1
+		}
+
+		// The DC code, the difference and the end of the block
+		bits = bits<<12 | uint64(dc)<<1
+		n += 12
+	}
+
+	// Pad with ones, and stuff a zero after any 0xff
+	for ; n%8 != 0; n++ {
+		bits = bits<<1 | 1
+	}
+	for i := int(n) - 8; i >= 0; i -= 8 {
+		v := byte(bits >> uint(i))
+		b.WriteByte(v)
+		if v == 0xff {
+			b.WriteByte(0)
+		}
+	}
+
+	b.Write([]byte{0xff, 0xd9})
+	return b.Bytes()
+}
+
+func TestCMYKJPEG(t *testing.T) {
+	ink := color.CMYK{C: 0, M: 200, Y: 250, K: 20}
+
+	img, err := jpeg.Decode(bytes.NewReader(cmykJPEG(ink)))
+	if err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+
+	src, ok := img.(*image.CMYK)
+	if !ok {
+		t.Fatalf("expected a CMYK image, got %T", img)
+	}
+	if got := src.CMYKAt(3, 3); got != ink {
+		t.Fatalf("expected %v, got %v", ink, got)
+	}
+
+	opts := DefaultOptions()
+	opts.BoxSize = 8
+	opts.CMYK = true
+
+	r, err := layout(img, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The RGB color averages right, and the inks come through as
+	// they were
+	r8, g8, b8 := color.CMYKToRGB(ink.C, ink.M, ink.Y, ink.K)
+	if want := (color.RGBA{r8, g8, b8, 0xff}); r.dots[0].color != want {
+		t.Errorf("expected the color %v, got %v", want, r.dots[0].color)
+	}
+	if r.dots[0].cmyk != ink {
+		t.Errorf("expected the inks %v, got %v", ink, r.dots[0].cmyk)
+	}
+
+	out, err := RenderBytes(img, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "device-cmyk(0,0.784,0.98,0.0784)"; !strings.Contains(string(out), want) {
+		t.Errorf("expected %s, got %s", want, out)
+	}
+}
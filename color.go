@@ -0,0 +1,169 @@
+// Copyright Bjørn Borud 2019 Use of this source code is governed by
+// the license found in the accompanying LICENSE file.
+
+package points
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// ParseHexColor parses a color on the form #rrggbb or #rgb.  The
+// leading # is optional.
+func ParseHexColor(s string) (color.RGBA, error) {
+	hex := strings.TrimPrefix(s, "#")
+
+	// Expand the short form so #abc becomes #aabbcc
+	if len(hex) == 3 {
+		hex = string([]byte{hex[0], hex[0], hex[1], hex[1], hex[2], hex[2]})
+	}
+
+	if len(hex) != 6 {
+		return color.RGBA{}, fmt.Errorf("invalid color %q, expected #rrggbb", s)
+	}
+
+	v, err := strconv.ParseUint(hex, 16, 32)
+	if err != nil {
+		return color.RGBA{}, fmt.Errorf("invalid color %q, expected #rrggbb", s)
+	}
+
+	return color.RGBA{R: uint8(v >> 16), G: uint8(v >> 8), B: uint8(v), A: 0xff}, nil
+}
+
+// sampleColor returns the color of img at u, v, which go from 0.0 to
+// 1.0 across its width and height.  It is interpolated bilinearly
+// between the four nearest pixels, and the alpha of img is left out
+// so the color is opaque.
+func sampleColor(img image.Image, u float64, v float64) color.RGBA {
+	b := img.Bounds()
+
+	// Pixel centers are half a pixel in
+	x := u*float64(b.Dx()) - 0.5
+	y := v*float64(b.Dy()) - 0.5
+	x0, y0 := int(math.Floor(x)), int(math.Floor(y))
+	fx, fy := x-float64(x0), y-float64(y0)
+
+	var sum [3]float64
+	for _, p := range []struct {
+		x, y   int
+		weight float64
+	}{
+		{x0, y0, (1 - fx) * (1 - fy)},
+		{x0 + 1, y0, fx * (1 - fy)},
+		{x0, y0 + 1, (1 - fx) * fy},
+		{x0 + 1, y0 + 1, fx * fy},
+	} {
+		px := b.Min.X + clampInt(p.x, 0, b.Dx()-1)
+		py := b.Min.Y + clampInt(p.y, 0, b.Dy()-1)
+		c := color.NRGBAModel.Convert(img.At(px, py)).(color.NRGBA)
+		sum[0] += float64(c.R) * p.weight
+		sum[1] += float64(c.G) * p.weight
+		sum[2] += float64(c.B) * p.weight
+	}
+
+	return color.RGBA{
+		R: uint8(math.Round(sum[0])),
+		G: uint8(math.Round(sum[1])),
+		B: uint8(math.Round(sum[2])),
+		A: 0xff,
+	}
+}
+
+// lumaColor returns the color of the dot of a box of color c whose
+// luma is luma.  That is the gray of the luma with opts.Gray, the
+// color of opts.Hue and opts.Saturation with that lightness with
+// opts.HSL, and c itself otherwise.
+func lumaColor(c color.RGBA, luma float64, opts Options) color.RGBA {
+	switch {
+	case opts.Gray:
+		return grayColor(luma)
+	case opts.HSL:
+		return hslColor(opts.Hue, opts.Saturation, luma)
+	}
+	return c
+}
+
+// grayColor returns the opaque gray whose level is luma, from 0.0 to
+// 1.0.
+func grayColor(luma float64) color.RGBA {
+	v := uint8(math.Round(luma * 0xff))
+	return color.RGBA{R: v, G: v, B: v, A: 0xff}
+}
+
+// hslColor returns the opaque color of the given hue, in degrees,
+// saturation and lightness, from 0.0 to 1.0.  A lightness of 0.0 is
+// black, 1.0 is white and the color is at its most vivid at 0.5.
+func hslColor(hue float64, saturation float64, lightness float64) color.RGBA {
+	h := math.Mod(hue, 360)
+	if h < 0 {
+		h += 360
+	}
+	h /= 60
+
+	chroma := (1 - math.Abs(2*lightness-1)) * saturation
+	x := chroma * (1 - math.Abs(math.Mod(h, 2)-1))
+
+	var r, g, b float64
+	switch {
+	case h < 1:
+		r, g = chroma, x
+	case h < 2:
+		r, g = x, chroma
+	case h < 3:
+		g, b = chroma, x
+	case h < 4:
+		g, b = x, chroma
+	case h < 5:
+		r, b = x, chroma
+	default:
+		r, b = chroma, x
+	}
+
+	m := lightness - chroma/2
+	return color.RGBA{
+		R: uint8(math.Round((r + m) * 0xff)),
+		G: uint8(math.Round((g + m) * 0xff)),
+		B: uint8(math.Round((b + m) * 0xff)),
+		A: 0xff,
+	}
+}
+
+// toHSV converts 8 bit RGB values to hue, in degrees from 0 up to
+// 360, and saturation and value, from 0.0 to 1.0.  Grays have a hue
+// and saturation of zero.
+func toHSV(r uint32, g uint32, b uint32) (float64, float64, float64) {
+	rf, gf, bf := float64(r)/0xff, float64(g)/0xff, float64(b)/0xff
+	max := math.Max(rf, math.Max(gf, bf))
+	min := math.Min(rf, math.Min(gf, bf))
+	chroma := max - min
+
+	if chroma == 0 {
+		return 0, 0, max
+	}
+
+	var h float64
+	switch max {
+	case rf:
+		h = math.Mod((gf-bf)/chroma, 6)
+	case gf:
+		h = (bf-rf)/chroma + 2
+	default:
+		h = (rf-gf)/chroma + 4
+	}
+
+	h *= 60
+	if h < 0 {
+		h += 360
+	}
+	return h, chroma / max, max
+}
+
+// hexColor formats c as #rrggbb, ignoring alpha.
+func hexColor(c color.Color) string {
+	rgba := color.RGBAModel.Convert(c).(color.RGBA)
+	return fmt.Sprintf("#%02x%02x%02x", rgba.R, rgba.G, rgba.B)
+}
@@ -0,0 +1,177 @@
+// Copyright Bjørn Borud 2019 Use of this source code is governed by
+// the license found in the accompanying LICENSE file.
+
+package points
+
+import (
+	"image"
+	"image/color"
+	"image/draw"
+	"math"
+	"strings"
+	"testing"
+)
+
+func TestGray(t *testing.T) {
+	tests := []struct {
+		name string
+		c    color.RGBA
+		want string
+	}{
+		{"mid gray", color.RGBA{0x80, 0x80, 0x80, 0xff}, "fill:#808080"},
+		// 0.299*0xff + 0.587*0x66 + 0.114*0x33 is 141.9
+		{"orange", color.RGBA{0xff, 0x66, 0x33, 0xff}, "fill:#8e8e8e"},
+		{"blue", color.RGBA{0x00, 0x00, 0xff, 0xff}, "fill:#1d1d1d"},
+	}
+
+	for _, test := range tests {
+		img := image.NewRGBA(image.Rect(0, 0, 20, 20))
+		draw.Draw(img, img.Bounds(), image.NewUniform(test.c), image.Point{}, draw.Src)
+
+		opts := DefaultOptions()
+		opts.BoxSize = 20
+		opts.Gray = true
+
+		out, err := RenderBytes(img, opts)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if !strings.Contains(string(out), test.want) {
+			t.Errorf("%s: expected %s, got %s", test.name, test.want, out)
+		}
+	}
+}
+
+func TestColorImage(t *testing.T) {
+	// The sizes come from a gradient
+	img := image.NewGray(image.Rect(0, 0, 200, 100))
+	for x := 0; x < 200; x++ {
+		for y := 0; y < 100; y++ {
+			img.SetGray(x, y, color.Gray{uint8(x)})
+		}
+	}
+
+	// The colors from a solid image of a different size
+	blue := color.RGBA{0x33, 0x66, 0xcc, 0xff}
+	colors := image.NewRGBA(image.Rect(0, 0, 8, 6))
+	draw.Draw(colors, colors.Bounds(), image.NewUniform(blue), image.Point{}, draw.Src)
+
+	opts := DefaultOptions()
+	opts.BoxSize = 20
+	opts.ColorImage = colors
+
+	r, err := layout(img, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, d := range r.dots {
+		if d.visible && d.color != blue {
+			t.Errorf("expected the dot at %d,%d to be %v, got %v", d.cx, d.cy, blue, d.color)
+		}
+	}
+
+	// The sizes still follow the gradient
+	if first, last := r.dots[0], r.dots[len(r.dots)-1]; first.radius <= last.radius {
+		t.Errorf("expected the sizes to follow the image, got radius %d and %d", first.radius, last.radius)
+	}
+
+	// The colors are sampled at the same relative position
+	red := color.RGBA{0xcc, 0x33, 0x00, 0xff}
+	draw.Draw(colors, image.Rect(4, 3, 8, 6), image.NewUniform(red), image.Point{}, draw.Src)
+
+	if r, err = layout(img, opts); err != nil {
+		t.Fatal(err)
+	}
+	if first := r.dots[0]; first.color != blue {
+		t.Errorf("expected the top left dot to be %v, got %v", blue, first.color)
+	}
+	if last := r.dots[len(r.dots)-1]; last.color != red {
+		t.Errorf("expected the bottom right dot to be %v, got %v", red, last.color)
+	}
+}
+
+func TestHSLColor(t *testing.T) {
+	tests := []struct {
+		hue, saturation, lightness float64
+		want                       color.RGBA
+	}{
+		{0, 1, 0.5, color.RGBA{0xff, 0x00, 0x00, 0xff}},
+		{120, 1, 0.5, color.RGBA{0x00, 0xff, 0x00, 0xff}},
+		{240, 1, 0.25, color.RGBA{0x00, 0x00, 0x80, 0xff}},
+		{-120, 1, 0.25, color.RGBA{0x00, 0x00, 0x80, 0xff}},
+		{240, 0, 0.5, color.RGBA{0x80, 0x80, 0x80, 0xff}},
+		{60, 1, 0, color.RGBA{0x00, 0x00, 0x00, 0xff}},
+		{60, 1, 1, color.RGBA{0xff, 0xff, 0xff, 0xff}},
+	}
+
+	for _, test := range tests {
+		if got := hslColor(test.hue, test.saturation, test.lightness); got != test.want {
+			t.Errorf("%v %v %v: expected %v, got %v", test.hue, test.saturation, test.lightness, test.want, got)
+		}
+	}
+}
+
+func TestHSL(t *testing.T) {
+	// Dark boxes on the left, lighter ones on the right
+	img := image.NewGray(image.Rect(0, 0, 40, 20))
+	draw.Draw(img, image.Rect(0, 0, 20, 20), image.NewUniform(color.Gray{0x20}), image.Point{}, draw.Src)
+	draw.Draw(img, image.Rect(20, 0, 40, 20), image.NewUniform(color.Gray{0x60}), image.Point{}, draw.Src)
+
+	for _, stipple := range []bool{false, true} {
+		opts := DefaultOptions()
+		opts.BoxSize = 10
+		opts.HSL = true
+		opts.Hue = 240
+		opts.Saturation = 1
+		opts.Stipple = stipple
+
+		r, err := layout(img, opts)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		visible := 0
+		for _, d := range r.dots {
+			if !d.visible {
+				continue
+			}
+			visible++
+
+			// Blue, and as dark as the box
+			want := color.RGBA{0x00, 0x00, 0x40, 0xff}
+			if d.cx > 20 {
+				want = color.RGBA{0x00, 0x00, 0xc0, 0xff}
+			}
+			if d.color != want {
+				t.Errorf("stipple %v: expected %v for the box at %d,%d, got %v", stipple, want, d.cx, d.cy, d.color)
+			}
+		}
+		if visible == 0 {
+			t.Errorf("stipple %v: expected dots", stipple)
+		}
+	}
+}
+
+func TestToHSV(t *testing.T) {
+	tests := []struct {
+		r, g, b uint32
+		h, s, v float64
+	}{
+		{0xff, 0x00, 0x00, 0, 1, 1},
+		{0x00, 0xff, 0x00, 120, 1, 1},
+		{0x00, 0x00, 0xff, 240, 1, 1},
+		{0xff, 0x00, 0xff, 300, 1, 1},
+		{0xff, 0x80, 0x80, 0, 0x7f / 255.0, 1},
+		{0x80, 0x80, 0x80, 0, 0, 0x80 / 255.0},
+		{0x00, 0x00, 0x00, 0, 0, 0},
+	}
+
+	for _, test := range tests {
+		h, s, v := toHSV(test.r, test.g, test.b)
+		if math.Abs(h-test.h) > 1e-9 || math.Abs(s-test.s) > 1e-9 || math.Abs(v-test.v) > 1e-9 {
+			t.Errorf("%02x%02x%02x: expected %v %v %v, got %v %v %v", test.r, test.g, test.b, test.h, test.s, test.v, h, s, v)
+		}
+	}
+}
@@ -0,0 +1,231 @@
+// Copyright Bjørn Borud 2019 Use of this source code is governed by
+// the license found in the accompanying LICENSE file.
+
+package points
+
+import (
+	"image"
+	"image/color"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// contourPoint is a vertex of a contour, in the coordinates of the
+// canvas.
+type contourPoint struct {
+	x, y float64
+}
+
+// contourEdge names an edge between two neighboring samples of the
+// luma map.  The edge goes right from sample x, y if down is false
+// and down from it if it is true.
+type contourEdge struct {
+	x, y int
+	down bool
+}
+
+// contourSegment is the part of a contour that crosses one cell of
+// the luma map, from one of its edges to another.
+type contourSegment struct {
+	from, to contourEdge
+}
+
+// computeContours traces the outlines of the areas whose luma is
+// below opts.Contour with marching squares.  The luma map has a
+// sample in the center of each box, and has a border of samples that
+// are outside all around it so every contour is closed.  Each
+// contour is returned as its vertices, and the first vertex is not
+// repeated at the end.
+func computeContours(img image.Image, g grid, opts Options) [][]contourPoint {
+	// The samples of the border are those at -1 and cols or rows.
+	cols, rows := g.cols+2, g.rows+2
+	lumas := boxLumas(img, g, opts)
+	sample := func(x int, y int) float64 {
+		if x < 0 || y < 0 || x >= g.cols || y >= g.rows {
+			return 1.0
+		}
+		return lumas[x*g.rows+y]
+	}
+
+	// position returns where the contour crosses edge e, found by
+	// interpolating between the samples at either end.  The samples
+	// are in the middle of the part of their box that is inside the
+	// image, and those of the border lie on the edges of the canvas
+	// so the contours stay on it.
+	scale := canvasScale(opts)
+	center := func(x int, y int) contourPoint {
+		box, _ := g.box(clampInt(x, 0, g.cols-1), clampInt(y, 0, g.rows-1))
+		cx := float64(box.Min.X+box.Max.X) / 2
+		cy := float64(box.Min.Y+box.Max.Y) / 2
+
+		if x < 0 {
+			cx = 0
+		} else if x >= g.cols {
+			cx = float64(g.width)
+		}
+		if y < 0 {
+			cy = 0
+		} else if y >= g.rows {
+			cy = float64(g.height)
+		}
+		return contourPoint{x: cx * scale, y: cy * scale}
+	}
+	position := func(e contourEdge) contourPoint {
+		x1, y1 := e.x+1, e.y
+		if e.down {
+			x1, y1 = e.x, e.y+1
+		}
+		a, b := sample(e.x, e.y), sample(x1, y1)
+		p, q := center(e.x, e.y), center(x1, y1)
+		t := (opts.Contour - a) / (b - a)
+		return contourPoint{x: p.x + t*(q.x-p.x), y: p.y + t*(q.y-p.y)}
+	}
+
+	var segments []contourSegment
+	for y := -1; y < rows-2; y++ {
+		for x := -1; x < cols-2; x++ {
+			segments = appendCell(segments, x, y, sample, opts.Contour)
+		}
+	}
+
+	// Every crossing ends one segment and starts another, so the
+	// segments are linked up by following them from where they start.
+	next := make(map[contourEdge]contourEdge, len(segments))
+	for _, s := range segments {
+		next[s.from] = s.to
+	}
+
+	var contours [][]contourPoint
+	for _, s := range segments {
+		if _, ok := next[s.from]; !ok {
+			continue
+		}
+
+		var contour []contourPoint
+		for e := s.from; ; {
+			to, ok := next[e]
+			if !ok {
+				break
+			}
+			contour = append(contour, position(e))
+			delete(next, e)
+			e = to
+		}
+		contours = append(contours, contour)
+	}
+	return contours
+}
+
+// appendCell appends the segments of the contour that cross the cell
+// whose top left sample is x, y.  The corners are visited clockwise.
+// Where the contour goes into the cell, that is where the next corner
+// is inside and the one before is not, a segment starts and it
+// follows the inside to where the contour leaves the cell.  This
+// gives all the segments the same direction, so they link up.  When
+// the contour crosses all four edges the sample in the middle, the
+// mean of the corners, decides whether the inside corners are joined.
+func appendCell(segments []contourSegment, x int, y int, sample func(x int, y int) float64, level float64) []contourSegment {
+	corners := [4]float64{sample(x, y), sample(x+1, y), sample(x+1, y+1), sample(x, y+1)}
+	edges := [4]contourEdge{{x, y, false}, {x + 1, y, true}, {x, y + 1, false}, {x, y, true}}
+
+	var crossings []int
+	var entering []bool
+	for i := range corners {
+		in, nextIn := corners[i] < level, corners[(i+1)%4] < level
+		if in != nextIn {
+			crossings = append(crossings, i)
+			entering = append(entering, nextIn)
+		}
+	}
+
+	joined := (corners[0]+corners[1]+corners[2]+corners[3])/4 < level
+	for i, from := range crossings {
+		if !entering[i] {
+			continue
+		}
+
+		// With two crossings the next and the one before are the
+		// same.
+		to := crossings[(i+1)%len(crossings)]
+		if joined {
+			to = crossings[(i+len(crossings)-1)%len(crossings)]
+		}
+		segments = append(segments, contourSegment{from: edges[from], to: edges[to]})
+	}
+	return segments
+}
+
+// boxLumas returns the luma of each box of the grid, after the
+// levels, indexed like the dots of computeDots.  When inverted it is
+// 1.0 - luma, so the boxes that are below the threshold are those that
+// would get dots either way.  Boxes that are too transparent to get a
+// dot, or that are masked out, are given 1.0.
+func boxLumas(img image.Image, g grid, opts Options) []float64 {
+	luma := chooseLuma(opts)
+	values := make([]float64, g.cols*g.rows)
+
+	forEachRow(g.rows, opts, func(y int) {
+		for x := 0; x < g.cols; x++ {
+			values[x*g.rows+y] = 1.0
+
+			box, ok := g.box(x, y)
+			if !ok || masked(img, box, opts) {
+				continue
+			}
+
+			c, alpha := boxColor(img, box.Add(img.Bounds().Min), opts)
+			if alpha == 0 || alpha < opts.AlphaCutoff {
+				continue
+			}
+
+			v := levels(luma(uint32(c.R), uint32(c.G), uint32(c.B)), opts)
+			if opts.Invert {
+				v = 1.0 - v
+			}
+			values[x*g.rows+y] = v
+		}
+	})
+
+	return values
+}
+
+// writeContours writes the contours to w as an SVG with a closed path
+// for each of them, drawn with lines as wide as opts.LineWidth.
+func writeContours(contours [][]contourPoint, width int, height int, opts Options, w io.Writer) error {
+	s := newSVGCanvas(w, opts)
+	s.start(width, height, opts.Background)
+
+	black := color.RGBA{A: 0xff}
+	for _, contour := range contours {
+		s.svg.Path(contourPath(contour), s.style("stroke", black)...)
+	}
+
+	return s.end()
+}
+
+// contourPath returns the SVG path data of a closed contour.  The
+// vertices are rounded off like those of the dots, and vertices that
+// end up on top of the one before are left out.
+func contourPath(contour []contourPoint) string {
+	var b strings.Builder
+	lastX, lastY := 0, 0
+	for i, p := range contour {
+		x, y := round(p.x), round(p.y)
+		if i > 0 && x == lastX && y == lastY {
+			continue
+		}
+
+		if i == 0 {
+			b.WriteString("M")
+		} else {
+			b.WriteString(" L")
+		}
+		b.WriteString(strconv.Itoa(x))
+		b.WriteString(",")
+		b.WriteString(strconv.Itoa(y))
+		lastX, lastY = x, y
+	}
+	b.WriteString(" Z")
+	return b.String()
+}
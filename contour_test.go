@@ -0,0 +1,79 @@
+// Copyright Bjørn Borud 2019 Use of this source code is governed by
+// the license found in the accompanying LICENSE file.
+
+package points
+
+import (
+	"image"
+	"math"
+	"strings"
+	"testing"
+)
+
+// discImage returns a white image with a black disc in the middle.
+func discImage(size int, radius float64) *image.Gray {
+	img := image.NewGray(image.Rect(0, 0, size, size))
+	c := float64(size) / 2
+	for y := 0; y < size; y++ {
+		for x := 0; x < size; x++ {
+			if math.Hypot(float64(x)+0.5-c, float64(y)+0.5-c) > radius {
+				img.Pix[img.PixOffset(x, y)] = 0xff
+			}
+		}
+	}
+	return img
+}
+
+func TestContourDisc(t *testing.T) {
+	img := discImage(200, 60)
+
+	opts := DefaultOptions()
+	opts.BoxSize = 10
+	opts.Contour = 0.5
+
+	r, err := layout(img, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(r.contours) != 1 {
+		t.Fatalf("expected one contour, got %d", len(r.contours))
+	}
+
+	// The vertices follow the edge of the disc to within a box or so
+	contour := r.contours[0]
+	for _, p := range contour {
+		if d := math.Hypot(p.x-100, p.y-100); d < 50 || d > 70 {
+			t.Errorf("expected the vertices about 60 from the center, got %+v at %.1f", p, d)
+		}
+	}
+
+	out, err := RenderBytes(img, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if n := strings.Count(string(out), "<path"); n != 1 {
+		t.Errorf("expected one path, got %d", n)
+	}
+	if !strings.Contains(string(out), ` Z"`) {
+		t.Errorf("expected the path to be closed, got %s", out)
+	}
+}
+
+func TestContourEmpty(t *testing.T) {
+	// Nothing is darker than the level, so there is nothing to trace
+	img := discImage(100, 0)
+
+	opts := DefaultOptions()
+	opts.BoxSize = 10
+	opts.Contour = 0.5
+
+	r, err := layout(img, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(r.contours) != 0 {
+		t.Errorf("expected no contours, got %d", len(r.contours))
+	}
+}
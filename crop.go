@@ -0,0 +1,39 @@
+// Copyright Bjørn Borud 2019 Use of this source code is governed by
+// the license found in the accompanying LICENSE file.
+
+package points
+
+import (
+	"errors"
+	"image"
+)
+
+// croppedImage limits the bounds of an image that has no SubImage
+// method.
+type croppedImage struct {
+	image.Image
+	bounds image.Rectangle
+}
+
+func (c croppedImage) Bounds() image.Rectangle {
+	return c.bounds
+}
+
+// crop restricts img to the rectangle r, which is relative to the top
+// left corner of the image.  The part of r that falls outside the
+// image is ignored, and it is an error if all of it does.
+func crop(img image.Image, r image.Rectangle) (image.Image, error) {
+	b := img.Bounds()
+
+	r = r.Add(b.Min).Intersect(b)
+	if r.Empty() {
+		return nil, errors.New("crop region is outside the image")
+	}
+
+	if s, ok := img.(interface {
+		SubImage(image.Rectangle) image.Image
+	}); ok {
+		return s.SubImage(r), nil
+	}
+	return croppedImage{Image: img, bounds: r}, nil
+}
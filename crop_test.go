@@ -0,0 +1,86 @@
+// Copyright Bjørn Borud 2019 Use of this source code is governed by
+// the license found in the accompanying LICENSE file.
+
+package points
+
+import (
+	"image"
+	"image/color"
+	"image/draw"
+	"testing"
+)
+
+func TestCrop(t *testing.T) {
+	// Only the top left quarter is black
+	img := image.NewRGBA(image.Rect(0, 0, 100, 100))
+	draw.Draw(img, img.Bounds(), image.NewUniform(color.White), image.Point{}, draw.Src)
+	draw.Draw(img, image.Rect(0, 0, 50, 50), image.NewUniform(color.Black), image.Point{}, draw.Src)
+
+	tests := []struct {
+		name          string
+		crop          image.Rectangle
+		width, height int
+		dots          int
+	}{
+		{"black quarter", image.Rect(0, 0, 40, 40), 40, 40, 4},
+		{"white quarter", image.Rect(60, 60, 100, 100), 40, 40, 0},
+		{"straddling", image.Rect(30, 30, 70, 70), 40, 40, 1},
+		{"past the edge", image.Rect(80, 0, 140, 20), 20, 20, 0},
+	}
+
+	for _, test := range tests {
+		opts := DefaultOptions()
+		opts.BoxSize = 20
+		opts.Crop = test.crop
+
+		r, err := layout(img, opts)
+		if err != nil {
+			t.Fatalf("%s: %v", test.name, err)
+		}
+
+		if r.width != test.width || r.height != test.height {
+			t.Errorf("%s: expected %dx%d, got %dx%d", test.name, test.width, test.height, r.width, r.height)
+		}
+
+		n := 0
+		for _, d := range r.dots {
+			if !d.visible {
+				continue
+			}
+			n++
+
+			if d.cx < 0 || d.cy < 0 || d.cx > r.width || d.cy > r.height {
+				t.Errorf("%s: expected the dots within the crop, got one at %d,%d", test.name, d.cx, d.cy)
+			}
+		}
+
+		if n != test.dots {
+			t.Errorf("%s: expected %d dots, got %d", test.name, test.dots, n)
+		}
+	}
+}
+
+func TestCropOutside(t *testing.T) {
+	img := image.NewGray(image.Rect(0, 0, 100, 100))
+
+	opts := DefaultOptions()
+	opts.Crop = image.Rect(100, 0, 150, 50)
+
+	if err := Render(img, opts, failingWriter{}); err == nil || err.Error() != "crop region is outside the image" {
+		t.Errorf("expected the crop region to be outside the image, got %v", err)
+	}
+}
+
+func TestCropWithoutSubImage(t *testing.T) {
+	// An image that can't make sub images is cropped by its bounds
+	img := croppedImage{Image: image.NewGray(image.Rect(10, 10, 110, 110)), bounds: image.Rect(10, 10, 110, 110)}
+
+	cropped, err := crop(img, image.Rect(20, 30, 40, 60))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if got := cropped.Bounds(); got != image.Rect(30, 40, 50, 70) {
+		t.Errorf("expected the crop relative to the top left corner, got %v", got)
+	}
+}
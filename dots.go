@@ -0,0 +1,324 @@
+// Copyright Bjørn Borud 2019 Use of this source code is governed by
+// the license found in the accompanying LICENSE file.
+
+package points
+
+import (
+	"image"
+	"image/color"
+	"math"
+	"runtime"
+	"sync"
+)
+
+// dot is a single dot ready to be drawn.  If visible is false the
+// dot was suppressed and should not be drawn.  angle is the rotation
+// of the dot in radians, clockwise.  size is the value from 0.0 to
+// 1.0 the radius was computed from.  The alpha of color is the
+// opacity of the dot and, unlike for color.RGBA in general, the color
+// channels are not premultiplied by it.  cmyk is only set if
+// Options.CMYK is.  layer is the index of the screen in
+// rosetteScreens the dot belongs to when Options.Rosette is set.
+type dot struct {
+	cx      int
+	cy      int
+	radius  int
+	angle   float64
+	size    float64
+	color   color.RGBA
+	cmyk    color.CMYK
+	layer   int
+	visible bool
+}
+
+// forEachRow calls fn for every row from 0 up to rows.  The rows are
+// divided up between opts.Workers goroutines, or one per CPU if it is
+// zero, and forEachRow returns once all are done.  opts.Progress, if
+// set, is told every time a row is finished.
+func forEachRow(rows int, opts Options, fn func(y int)) {
+	if rows == 0 {
+		return
+	}
+
+	// Progress is reported under a lock so the callback sees the
+	// count go up one row at a time and doesn't have to worry about
+	// being called from several goroutines at once.
+	var mu sync.Mutex
+	done := 0
+
+	workers := opts.Workers
+	if workers < 1 {
+		workers = runtime.NumCPU()
+	}
+	workers = minInt(workers, rows)
+
+	rowsPerWorker := (rows + workers - 1) / workers
+
+	var wg sync.WaitGroup
+	for start := 0; start < rows; start += rowsPerWorker {
+		end := minInt(start+rowsPerWorker, rows)
+
+		wg.Add(1)
+		go func(start int, end int) {
+			defer wg.Done()
+
+			for y := start; y < end; y++ {
+				fn(y)
+
+				if opts.Progress != nil {
+					mu.Lock()
+					done++
+					opts.Progress(done, rows)
+					mu.Unlock()
+				}
+			}
+		}(start, end)
+	}
+	wg.Wait()
+}
+
+// computeDots computes the dots for every box in the grid.  The rows
+// are divided up between opts.Workers goroutines.  The image is only
+// read, so this is safe for all the image types in the standard
+// library.  The dots are returned in column order, that is x outer
+// and y inner.
+func computeDots(img image.Image, g grid, opts Options) []dot {
+	dots := make([]dot, g.cols*g.rows)
+	computeColumns(img, g, 0, g.cols, opts, func(x int, y int, d dot) {
+		dots[x*g.rows+y] = d
+	})
+	return dots
+}
+
+// computeColumns computes the dots for the boxes in the columns of
+// the grid from left up to right and hands each to set along with its
+// column and row.  The rows are divided up between opts.Workers
+// goroutines, so set is called from several at once, though never
+// twice for the same box.  Since the dots are written in column order
+// a band of columns can be computed and drawn without holding on to
+// the dots of the rest of the grid.
+func computeColumns(img image.Image, g grid, left int, right int, opts Options, set func(x int, y int, d dot)) {
+	luma := chooseLuma(opts)
+
+	forEachRow(g.rows, opts, func(y int) {
+		for x := left; x < right; x++ {
+			box, ok := g.box(x, y)
+			if !ok || skipChecker(x, y, opts) {
+				continue
+			}
+			set(x, y, makeDot(img, box, minInt(g.boxWidth, g.boxHeight)/2, opts, luma))
+		}
+	})
+}
+
+// skipChecker returns true if the box in column x and row y is one of
+// the half of the checkerboard that opts.Checker leaves out.
+func skipChecker(x int, y int, opts Options) bool {
+	if !opts.Checker {
+		return false
+	}
+
+	odd := (x+y)%2 != 0
+	return odd != opts.CheckerOdd
+}
+
+// defaultAreaScale is the AreaScale used when none is given.  It is a
+// little less than sqrt(pi), the factor that would make the dot of a
+// black box exactly as wide as the box, so the dots always keep a bit
+// of space between them.
+const defaultAreaScale = 1.7
+
+// makeDot calculates the color of the box and turns it into a dot
+// whose radius depends on its luma.  The box is relative to the top
+// left corner of the image.  boxHalf is the radius, before scaling,
+// of the largest dot.
+func makeDot(img image.Image, box image.Rectangle, boxHalf int, opts Options, lumaFunc lumaFunc) dot {
+	scale := opts.Scale
+	half := float64(boxHalf) * scale
+
+	// The image bounds need not start at (0,0), for instance if the
+	// image is a sub image.
+	src := box.Add(img.Bounds().Min)
+	c, alpha := boxColor(img, src, opts)
+
+	// Mostly transparent boxes have nothing to show
+	if alpha == 0 || alpha < opts.AlphaCutoff {
+		return dot{}
+	}
+
+	luma := levels(lumaFunc(uint32(c.R), uint32(c.G), uint32(c.B)), opts)
+	c = lumaColor(c, luma, opts)
+
+	// Normally dark boxes give big dots and the threshold removes
+	// the bright ones.  When inverted it is the other way around.
+	size := 1.0 - luma
+	if opts.Invert {
+		size = luma
+	}
+
+	if skipLuma(luma, opts) {
+		return dot{}
+	}
+
+	// The opacity follows the darkness whatever the size ends up as
+	darkness := size
+
+	// Make the dots follow the edges of the image rather than, or
+	// as well as, its darkness
+	switch opts.Edges {
+	case EdgesOnly:
+		size = edgeMagnitude(img, src)
+	case EdgesMultiply:
+		size *= edgeMagnitude(img, src)
+	}
+
+	// Shape the response so dark areas or midtones stand out more
+	if opts.DotGamma > 0 && opts.DotGamma != 1.0 {
+		size = math.Pow(size, opts.DotGamma)
+	}
+
+	d := placeDot(img, box, clampRadius(dotRadius(size, half, opts), half, opts), size, c, opts)
+	if opts.Opacity > 0 {
+		d.color.A = uint8(math.Round(0xff * math.Pow(darkness, opts.Opacity)))
+	}
+	return d
+}
+
+// skipLuma returns true if a box of the given luma is outside the
+// band between the luma thresholds, and so gets no dot.  When
+// inverted the band is too.
+func skipLuma(luma float64, opts Options) bool {
+	if opts.Invert {
+		luma = 1.0 - luma
+	}
+	return luma >= opts.LumaThreshold || luma < opts.LumaThresholdLow
+}
+
+// dotRadius calculates the radius of a dot of the given size either
+// by taking the size as area or as radius.  For the area a black box
+// would get a dot with an area of one, which has a radius of
+// sqrt(1/pi), so it has to be scaled up to fill the box.  half is
+// half the size of the box, after scaling.
+func dotRadius(size float64, half float64, opts Options) float64 {
+	if opts.LumaArea {
+		areaScale := opts.AreaScale
+		if areaScale == 0 {
+			areaScale = defaultAreaScale
+		}
+		return math.Sqrt(size/math.Pi) * areaScale * half
+	}
+	return size * half
+}
+
+// clampRadius clamps the radius to the limits given in the options.
+// half is half the size of the box, after scaling.
+func clampRadius(radius float64, half float64, opts Options) float64 {
+	radius = math.Max(radius, opts.MinRadius)
+	if opts.MaxRadius > 0 {
+		radius = math.Min(radius, opts.MaxRadius)
+	}
+
+	// This goes last so the dot stays within its box whatever the
+	// other limits say.
+	if opts.NoOverlap {
+		radius = math.Min(radius, half)
+	}
+
+	return radius
+}
+
+// placeDot makes a dot in the middle of box.  The color is taken from
+// the color image, if any, and snapped to the palette, and the radius
+// is shrunk by the vignette and rounded.  Unless the dot is masked
+// out or snapped away by opts.Precision it is visible.
+func placeDot(img image.Image, box image.Rectangle, radius float64, size float64, c color.RGBA, opts Options) dot {
+	if masked(img, box, opts) {
+		return dot{}
+	}
+
+	scale := canvasScale(opts)
+
+	if opts.ColorImage != nil {
+		dims := img.Bounds().Size()
+		u := (float64(box.Min.X) + float64(box.Dx())/2) / float64(dims.X)
+		v := (float64(box.Min.Y) + float64(box.Dy())/2) / float64(dims.Y)
+		c = sampleColor(opts.ColorImage, u, v)
+	}
+
+	if opts.Vignette > 0 {
+		radius *= vignette(box, img.Bounds().Size(), opts.Vignette)
+	}
+
+	if len(opts.Palette) > 0 {
+		distance := rgbDistance
+		if opts.PaletteLab {
+			distance = labDistance
+		}
+		c = nearestColor(c, opts.Palette, distance)
+	}
+
+	d := dot{
+		cx:      int(math.Round(float64(box.Min.X+box.Dx()/2) * scale)),
+		cy:      int(math.Round(float64(box.Min.Y+box.Dy()/2) * scale)),
+		radius:  int(radius),
+		size:    size,
+		color:   c,
+		visible: true,
+	}
+
+	if opts.CMYK {
+		d.cmyk = dotCMYK(img, box.Add(img.Bounds().Min), c, opts)
+	}
+
+	if opts.Precision > 0 {
+		d.cx = snap(float64(d.cx), opts.Precision)
+		d.cy = snap(float64(d.cy), opts.Precision)
+		d.radius = snap(radius, opts.Precision)
+
+		// Dots this small can't be seen anyway
+		if d.radius == 0 {
+			return dot{}
+		}
+	}
+
+	if (opts.Orient && rotatable(opts.Shape)) || opts.Shape == ShapeLine {
+		d.angle = gradientAngle(img, box.Add(img.Bounds().Min))
+	}
+
+	return d
+}
+
+// vignette returns the factor the radius of the dot of box is
+// multiplied by.  The distance from the middle of box to the center
+// of an image of the given size is normalized so it is 1.0 in the
+// corners, and the factor falls off linearly from 1.0 at the center
+// to 1.0 minus strength there.
+func vignette(box image.Rectangle, size image.Point, strength float64) float64 {
+	halfW, halfH := float64(size.X)/2, float64(size.Y)/2
+	dx := (float64(box.Min.X) + float64(box.Dx())/2 - halfW) / halfW
+	dy := (float64(box.Min.Y) + float64(box.Dy())/2 - halfH) / halfH
+
+	distance := math.Min(math.Hypot(dx, dy)/math.Sqrt2, 1)
+	return 1 - strength*distance
+}
+
+// snap rounds v to the nearest multiple of grid.
+func snap(v float64, grid int) int {
+	return int(math.Round(v/float64(grid))) * grid
+}
+
+// maxInt returns the larger of a and b.
+func maxInt(a int, b int) int {
+	if a > b {
+		return a
+	}
+	return b
+}
+
+// minInt returns the smaller of a and b.
+func minInt(a int, b int) int {
+	if a < b {
+		return a
+	}
+	return b
+}
@@ -0,0 +1,729 @@
+// Copyright Bjørn Borud 2019 Use of this source code is governed by
+// the license found in the accompanying LICENSE file.
+
+package points
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	"image/png"
+	"io/ioutil"
+	"math"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestPartialBoxes(t *testing.T) {
+	// 50x30 doesn't divide by 20, so the last column is 10 pixels
+	// wide and the last row 10 pixels high.  The last column is
+	// black and the rest white.
+	img := image.NewRGBA(image.Rect(0, 0, 50, 30))
+	draw.Draw(img, img.Bounds(), image.NewUniform(color.White), image.Point{}, draw.Src)
+	draw.Draw(img, image.Rect(40, 0, 50, 30), image.NewUniform(color.Black), image.Point{}, draw.Src)
+
+	opts := DefaultOptions()
+	opts.BoxSize = 20
+
+	r, err := layout(img, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if r.grid.cols != 3 || r.grid.rows != 2 {
+		t.Fatalf("expected 3x2 boxes, got %dx%d", r.grid.cols, r.grid.rows)
+	}
+
+	tests := []struct {
+		x, y    int
+		cx, cy  int
+		color   color.RGBA
+		visible bool
+	}{
+		{0, 0, 0, 0, color.RGBA{}, false},
+		{2, 0, 45, 10, color.RGBA{0, 0, 0, 0xff}, true},
+		{2, 1, 45, 25, color.RGBA{0, 0, 0, 0xff}, true},
+		{1, 1, 0, 0, color.RGBA{}, false},
+	}
+
+	for _, test := range tests {
+		d := r.dots[test.x*r.grid.rows+test.y]
+		if d.visible != test.visible {
+			t.Errorf("box %d,%d: expected visible %v", test.x, test.y, test.visible)
+			continue
+		}
+		if !d.visible {
+			continue
+		}
+
+		// Only the pixels that exist count, so the partial boxes
+		// are as black as the image is there.
+		if d.cx != test.cx || d.cy != test.cy || d.color != test.color {
+			t.Errorf("box %d,%d: expected %d,%d %v, got %d,%d %v", test.x, test.y, test.cx, test.cy, test.color, d.cx, d.cy, d.color)
+		}
+	}
+}
+
+func TestSubImage(t *testing.T) {
+	red := color.RGBA{0xff, 0, 0, 0xff}
+	blue := color.RGBA{0, 0, 0xff, 0xff}
+
+	img := image.NewRGBA(image.Rect(0, 0, 60, 60))
+	draw.Draw(img, img.Bounds(), image.NewUniform(blue), image.Point{}, draw.Src)
+	draw.Draw(img, image.Rect(20, 20, 40, 40), image.NewUniform(red), image.Point{}, draw.Src)
+
+	// The sub image starts at 20,20, so its first box is the red one
+	sub := img.SubImage(image.Rect(20, 20, 60, 60))
+
+	opts := DefaultOptions()
+	opts.BoxSize = 20
+
+	r, err := layout(sub, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if r.width != 40 || r.height != 40 {
+		t.Errorf("expected a 40x40 output, got %dx%d", r.width, r.height)
+	}
+
+	tests := []struct {
+		x, y   int
+		cx, cy int
+		color  color.RGBA
+	}{
+		{0, 0, 10, 10, red},
+		{1, 0, 30, 10, blue},
+		{0, 1, 10, 30, blue},
+		{1, 1, 30, 30, blue},
+	}
+
+	for _, test := range tests {
+		d := r.dots[test.x*r.grid.rows+test.y]
+		if d.cx != test.cx || d.cy != test.cy || d.color != test.color {
+			t.Errorf("box %d,%d: expected %d,%d %v, got %d,%d %v", test.x, test.y, test.cx, test.cy, test.color, d.cx, d.cy, d.color)
+		}
+	}
+}
+
+func TestInvert(t *testing.T) {
+	tests := []struct {
+		name   string
+		color  color.Color
+		invert bool
+		dots   int
+	}{
+		{"black", color.Black, false, 4},
+		{"white", color.White, false, 0},
+		{"black inverted", color.Black, true, 0},
+		{"white inverted", color.White, true, 4},
+	}
+
+	for _, test := range tests {
+		img := image.NewRGBA(image.Rect(0, 0, 40, 40))
+		draw.Draw(img, img.Bounds(), image.NewUniform(test.color), image.Point{}, draw.Src)
+
+		opts := DefaultOptions()
+		opts.BoxSize = 20
+		opts.Invert = test.invert
+
+		r, err := layout(img, opts)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		n := 0
+		for _, d := range r.dots {
+			if !d.visible {
+				continue
+			}
+			n++
+
+			if d.radius != 10 {
+				t.Errorf("%s: expected full size dots, got radius %d", test.name, d.radius)
+			}
+		}
+
+		if n != test.dots {
+			t.Errorf("%s: expected %d dots, got %d", test.name, test.dots, n)
+		}
+	}
+}
+
+func TestRadiusLimits(t *testing.T) {
+	// A gradient from black to white gives all sizes of dots
+	img := image.NewGray(image.Rect(0, 0, 256, 16))
+	for x := 0; x < 256; x++ {
+		for y := 0; y < 16; y++ {
+			img.SetGray(x, y, color.Gray{uint8(x)})
+		}
+	}
+
+	tests := []struct {
+		min, max float64
+		lo, hi   int
+	}{
+		{0, 0, 0, 7},
+		{3, 0, 3, 7},
+		{0, 5, 0, 5},
+		{2, 4, 2, 4},
+		{6, 6, 6, 6},
+	}
+
+	for _, test := range tests {
+		opts := DefaultOptions()
+		opts.BoxSize = 16
+		opts.MinRadius = test.min
+		opts.MaxRadius = test.max
+
+		r, err := layout(img, opts)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		lo, hi := -1, -1
+		for _, d := range r.dots {
+			if !d.visible {
+				continue
+			}
+			if lo < 0 || d.radius < lo {
+				lo = d.radius
+			}
+			hi = maxInt(hi, d.radius)
+		}
+
+		if lo != test.lo || hi != test.hi {
+			t.Errorf("min %g, max %g: expected radii from %d to %d, got %d to %d", test.min, test.max, test.lo, test.hi, lo, hi)
+		}
+	}
+}
+
+func TestSnap(t *testing.T) {
+	tests := []struct {
+		v    float64
+		grid int
+		want int
+	}{
+		{0, 1, 0},
+		{0.4, 1, 0},
+		{0.5, 1, 1},
+		{7, 2, 8},
+		{6.9, 5, 5},
+		{7.5, 5, 10},
+		{12, 5, 10},
+	}
+
+	for _, test := range tests {
+		if got := snap(test.v, test.grid); got != test.want {
+			t.Errorf("snap(%g, %d) = %d, expected %d", test.v, test.grid, got, test.want)
+		}
+	}
+}
+
+func TestPrecision(t *testing.T) {
+	// A gradient from black to white gives all sizes of dots,
+	// including those that round to nothing
+	img := image.NewGray(image.Rect(0, 0, 256, 16))
+	for x := 0; x < 256; x++ {
+		for y := 0; y < 16; y++ {
+			img.SetGray(x, y, color.Gray{uint8(x)})
+		}
+	}
+
+	for _, precision := range []int{1, 2, 5} {
+		opts := DefaultOptions()
+		opts.BoxSize = 8
+		opts.Precision = precision
+
+		r, err := layout(img, opts)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		for _, d := range r.dots {
+			if !d.visible {
+				continue
+			}
+			if d.radius == 0 {
+				t.Errorf("precision %d: expected no dots of radius 0", precision)
+			}
+			if d.radius%precision != 0 || d.cx%precision != 0 || d.cy%precision != 0 {
+				t.Errorf("precision %d: expected multiples of %d, got %d,%d r %d", precision, precision, d.cx, d.cy, d.radius)
+			}
+		}
+
+		out, err := RenderBytes(img, opts)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if strings.Contains(string(out), `r="0"`) {
+			t.Errorf("precision %d: expected no circles of radius 0 in the svg", precision)
+		}
+	}
+}
+
+func TestTransparency(t *testing.T) {
+	// Black, apart from a transparent top left quadrant, and sent
+	// through PNG
+	src := image.NewNRGBA(image.Rect(0, 0, 40, 40))
+	draw.Draw(src, src.Bounds(), image.NewUniform(color.Black), image.Point{}, draw.Src)
+	draw.Draw(src, image.Rect(0, 0, 20, 20), image.NewUniform(color.Transparent), image.Point{}, draw.Src)
+
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, src); err != nil {
+		t.Fatal(err)
+	}
+	img, err := png.Decode(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name    string
+		boxSize int
+		cutoff  float64
+		want    []int
+	}{
+		{"quadrants", 20, 0, []int{-1, 10, 10, 10}},
+		{"partly transparent", 40, 0, []int{20}},
+		{"below the cutoff", 40, 0.8, []int{-1}},
+		{"above the cutoff", 40, 0.7, []int{20}},
+	}
+
+	for _, test := range tests {
+		opts := DefaultOptions()
+		opts.BoxSize = test.boxSize
+		opts.AlphaCutoff = test.cutoff
+
+		r, err := layout(img, opts)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		// The transparent pixels don't make the rest any lighter, so
+		// the dots that are drawn are full size.
+		for i, d := range r.dots {
+			got := -1
+			if d.visible {
+				got = d.radius
+			}
+			if got != test.want[i] {
+				t.Errorf("%s: expected dot %d to have radius %d, got %d", test.name, i, test.want[i], got)
+			}
+		}
+	}
+}
+
+func TestProgress(t *testing.T) {
+	img := image.NewGray(image.Rect(0, 0, 100, 70))
+
+	opts := DefaultOptions()
+	opts.BoxSize = 10
+
+	want, err := RenderBytes(img, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, workers := range []int{1, 4} {
+		opts.Workers = workers
+
+		var calls []int
+		opts.Progress = func(done int, total int) {
+			if total != 7 {
+				t.Errorf("%d workers: expected a total of 7 rows, got %d", workers, total)
+			}
+			calls = append(calls, done)
+		}
+
+		got, err := RenderBytes(img, opts)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		// Once for every row, counting up to the total
+		if len(calls) != 7 {
+			t.Errorf("%d workers: expected 7 calls, got %v", workers, calls)
+		}
+		for i, done := range calls {
+			if done != i+1 {
+				t.Errorf("%d workers: expected the calls to count up, got %v", workers, calls)
+				break
+			}
+		}
+
+		if !bytes.Equal(got, want) {
+			t.Errorf("%d workers: expected progress to leave the output alone", workers)
+		}
+	}
+}
+
+func TestDotGamma(t *testing.T) {
+	// Boxes getting darker from left to right
+	img := image.NewGray(image.Rect(0, 0, 16*20, 20))
+	for i := 0; i < 16; i++ {
+		draw.Draw(img, image.Rect(i*20, 0, i*20+20, 20), image.NewUniform(color.Gray{uint8(255 - i*17)}), image.Point{}, draw.Src)
+	}
+
+	radii := func(gamma float64) []int {
+		opts := DefaultOptions()
+		opts.BoxSize = 20
+		opts.DotGamma = gamma
+
+		r, err := layout(img, opts)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		var radii []int
+		for _, d := range r.dots {
+			radii = append(radii, d.radius)
+		}
+		return radii
+	}
+
+	for _, gamma := range []float64{0.5, 1, 2, 3} {
+		got := radii(gamma)
+		for i := 1; i < len(got); i++ {
+			if got[i] < got[i-1] {
+				t.Errorf("gamma %g: expected the radius to grow with the darkness, got %v", gamma, got)
+				break
+			}
+		}
+	}
+
+	// A gamma of one leaves the radii alone
+	if want, got := fmt.Sprint(radii(0)), fmt.Sprint(radii(1)); got != want {
+		t.Errorf("gamma 1: expected %s, got %s", want, got)
+	}
+
+	// Dark areas stand out more with a higher gamma
+	if low, high := radii(0.5), radii(2); high[8] >= low[8] {
+		t.Errorf("expected a smaller midtone dot with gamma 2 than 0.5, got %d and %d", high[8], low[8])
+	}
+}
+
+func TestAreaScale(t *testing.T) {
+	img := image.NewGray(image.Rect(0, 0, 40, 40))
+
+	for _, scale := range []float64{0.5, 1, 2} {
+		opts := DefaultOptions()
+		opts.BoxSize = 40
+		opts.LumaArea = true
+		opts.Scale = scale
+
+		r, err := layout(img, opts)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		// A black box comes close to filling its box, but never more
+		half := 20 * scale
+		if got := float64(r.dots[0].radius); got > half || got < half*0.9 {
+			t.Errorf("scale %g: expected a radius just below %g, got %g", scale, half, got)
+		}
+	}
+
+	// The area scale that fills the box exactly
+	opts := DefaultOptions()
+	opts.LumaArea = true
+	opts.AreaScale = math.Sqrt(math.Pi)
+	if got := dotRadius(1.0, 20, opts); math.Abs(got-20) > 1e-9 {
+		t.Errorf("expected an area scale of sqrt(pi) to fill the box, got radius %g", got)
+	}
+}
+
+func TestNoOverlap(t *testing.T) {
+	// A gradient, so the dots come in all sizes
+	img := image.NewGray(image.Rect(0, 0, 200, 20))
+	for x := 0; x < 200; x++ {
+		for y := 0; y < 20; y++ {
+			img.SetGray(x, y, color.Gray{uint8(x * 255 / 199)})
+		}
+	}
+
+	tests := []struct {
+		name string
+		opts func(o *Options)
+	}{
+		{"linear", func(o *Options) {}},
+		{"area", func(o *Options) { o.LumaArea = true; o.AreaScale = 3 }},
+		{"min radius", func(o *Options) { o.MinRadius = 100 }},
+	}
+
+	for _, test := range tests {
+		for _, scale := range []float64{1, 1.5} {
+			opts := DefaultOptions()
+			opts.BoxSize = 20
+			opts.Scale = scale
+			opts.NoOverlap = true
+			test.opts(&opts)
+
+			r, err := layout(img, opts)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			half := 10 * scale
+			for _, d := range r.dots {
+				if float64(d.radius) > half {
+					t.Errorf("%s, scale %g: expected no radius above %g, got %d", test.name, scale, half, d.radius)
+				}
+			}
+		}
+	}
+}
+
+func TestCanvasScale(t *testing.T) {
+	img := image.NewGray(image.Rect(0, 0, 40, 20))
+
+	tests := []struct {
+		name               string
+		scale, canvasScale float64
+		width, height      int
+		cx, cy, radius     int
+	}{
+		{"unscaled", 1, 0, 40, 20, 10, 10, 10},
+		{"both scaled up", 2, 0, 80, 40, 20, 20, 20},
+		{"both scaled down", 0.5, 0, 20, 10, 5, 5, 5},
+		{"big canvas, small dots", 1, 2, 80, 40, 20, 20, 10},
+		{"small canvas, big dots", 2, 1, 40, 20, 10, 10, 20},
+		{"small canvas, small dots", 0.5, 0.5, 20, 10, 5, 5, 5},
+	}
+
+	for _, test := range tests {
+		opts := DefaultOptions()
+		opts.BoxSize = 20
+		opts.Scale = test.scale
+		opts.CanvasScale = test.canvasScale
+
+		r, err := layout(img, opts)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if r.width != test.width || r.height != test.height {
+			t.Errorf("%s: expected a %dx%d canvas, got %dx%d", test.name, test.width, test.height, r.width, r.height)
+		}
+
+		if d := r.dots[0]; d.cx != test.cx || d.cy != test.cy || d.radius != test.radius {
+			t.Errorf("%s: expected a dot at %d,%d with radius %d, got %d,%d with radius %d", test.name, test.cx, test.cy, test.radius, d.cx, d.cy, d.radius)
+		}
+	}
+}
+
+func TestVignette(t *testing.T) {
+	size := image.Pt(100, 100)
+
+	tests := []struct {
+		box  image.Rectangle
+		want float64
+	}{
+		{image.Rect(40, 40, 60, 60), 1.0},
+		{image.Rect(-10, -10, 10, 10), 0.5},
+		{image.Rect(90, 90, 110, 110), 0.5},
+		{image.Rect(90, 40, 110, 60), 1 - 0.5/math.Sqrt2},
+	}
+
+	for _, test := range tests {
+		if got := vignette(test.box, size, 0.5); math.Abs(got-test.want) > 1e-9 {
+			t.Errorf("%v: expected %g, got %g", test.box, test.want, got)
+		}
+	}
+
+	// Black all over, so only the vignette tells the dots apart
+	img := image.NewGray(image.Rect(0, 0, 100, 100))
+
+	opts := DefaultOptions()
+	opts.BoxSize = 20
+	opts.Vignette = 0.5
+
+	r, err := layout(img, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	center := r.dots[2*r.grid.rows+2]
+	for _, i := range []int{0, r.grid.rows - 1, len(r.dots) - r.grid.rows, len(r.dots) - 1} {
+		if corner := r.dots[i]; corner.radius >= center.radius {
+			t.Errorf("expected the corner dot at %d,%d to be smaller than the center dot, got %d and %d", corner.cx, corner.cy, corner.radius, center.radius)
+		}
+	}
+	if center.radius != 10 {
+		t.Errorf("expected the center dot to keep its full radius of 10, got %d", center.radius)
+	}
+}
+
+func TestThresholdBand(t *testing.T) {
+	// Ten boxes going from black to nearly white
+	img := image.NewGray(image.Rect(0, 0, 200, 20))
+	for i := 0; i < 10; i++ {
+		draw.Draw(img, image.Rect(i*20, 0, i*20+20, 20), image.NewUniform(color.Gray{uint8(i * 28)}), image.Point{}, draw.Src)
+	}
+
+	tests := []struct {
+		name      string
+		low, high float64
+		invert    bool
+		want      string
+	}{
+		{"default", 0, 1, false, "1111111111"},
+		{"midtones", 0.3, 0.7, false, "0001111000"},
+		{"shadows", 0, 0.3, false, "1110000000"},
+		{"lower midtones", 0.3, 0.5, false, "0001100000"},
+		{"inverted", 0.3, 0.5, true, "0000011000"},
+	}
+
+	for _, test := range tests {
+		opts := DefaultOptions()
+		opts.BoxSize = 20
+		opts.LumaThresholdLow = test.low
+		opts.LumaThreshold = test.high
+		opts.Invert = test.invert
+
+		r, err := layout(img, opts)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		var got strings.Builder
+		for _, d := range r.dots {
+			if d.visible {
+				got.WriteByte('1')
+			} else {
+				got.WriteByte('0')
+			}
+		}
+
+		if got.String() != test.want {
+			t.Errorf("%s: expected dots in %s, got %s", test.name, test.want, got.String())
+		}
+	}
+}
+
+func TestChecker(t *testing.T) {
+	// 5x3 boxes, all black
+	img := image.NewGray(image.Rect(0, 0, 100, 60))
+
+	covered := map[[2]int]int{}
+	for _, odd := range []bool{false, true} {
+		opts := DefaultOptions()
+		opts.BoxSize = 20
+		opts.Checker = true
+		opts.CheckerOdd = odd
+
+		r, err := layout(img, opts)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		visible := 0
+		for i, d := range r.dots {
+			if !d.visible {
+				continue
+			}
+			visible++
+
+			x, y := i/r.grid.rows, i%r.grid.rows
+			if ((x+y)%2 != 0) != odd {
+				t.Errorf("odd %v: expected no dot in box %d,%d", odd, x, y)
+			}
+			covered[[2]int{x, y}]++
+		}
+
+		// Half the boxes, and the one left over goes to the even half
+		want := 8
+		if odd {
+			want = 7
+		}
+		if visible != want {
+			t.Errorf("odd %v: expected %d dots, got %d", odd, want, visible)
+		}
+	}
+
+	// Together the two halves give every box one dot
+	if len(covered) != 15 {
+		t.Errorf("expected the two halves to cover all 15 boxes, got %d", len(covered))
+	}
+	for box, n := range covered {
+		if n != 1 {
+			t.Errorf("expected box %v to get one dot, got %d", box, n)
+		}
+	}
+}
+
+func TestDeterministic(t *testing.T) {
+	img := gradientImage(300, 200)
+
+	tests := []struct {
+		name string
+		opts func(o *Options)
+	}{
+		{"svg", func(o *Options) {}},
+		{"png", func(o *Options) { o.Format = FormatPNG }},
+		{"pdf", func(o *Options) { o.Format = FormatPDF }},
+		{"json", func(o *Options) { o.Format = FormatJSON }},
+		{"mergeflat", func(o *Options) { o.MergeFlat = 0.1 }},
+		{"adaptive", func(o *Options) { o.Adaptive = true }},
+		{"stipple", func(o *Options) { o.Stipple = true }},
+		{"poisson", func(o *Options) { o.Poisson = true }},
+		{"spiral", func(o *Options) { o.Spiral = true }},
+		{"rosette", func(o *Options) { o.Rosette = true }},
+		{"contour", func(o *Options) { o.Contour = 0.5 }},
+		{"autothreshold", func(o *Options) { o.AutoThreshold = true }},
+	}
+
+	for _, test := range tests {
+		var outputs [][]byte
+		for _, workers := range []int{1, 8} {
+			opts := DefaultOptions()
+			opts.BoxSize = 4
+			opts.Workers = workers
+			opts.Date = time.Date(2019, 1, 1, 0, 0, 0, 0, time.UTC)
+			test.opts(&opts)
+
+			out, err := RenderBytes(img, opts)
+			if err != nil {
+				t.Fatalf("%s: %v", test.name, err)
+			}
+			outputs = append(outputs, out)
+		}
+
+		if !bytes.Equal(outputs[0], outputs[1]) {
+			t.Errorf("%s: output with 8 workers differs from the output with 1", test.name)
+		}
+	}
+}
+
+func BenchmarkRender(b *testing.B) {
+	img := image.NewRGBA(image.Rect(0, 0, 1600, 1200))
+	for y := 0; y < 1200; y++ {
+		for x := 0; x < 1600; x++ {
+			img.Set(x, y, color.RGBA{uint8(x), uint8(y), uint8(x ^ y), 0xff})
+		}
+	}
+
+	// On a single CPU there is nothing to compare with
+	counts := []int{1}
+	if n := runtime.NumCPU(); n > 1 {
+		counts = append(counts, n)
+	}
+
+	for _, workers := range counts {
+		b.Run(fmt.Sprintf("workers=%d", workers), func(b *testing.B) {
+			opts := DefaultOptions()
+			opts.BoxSize = 8
+			opts.Workers = workers
+
+			for i := 0; i < b.N; i++ {
+				if err := Render(img, opts, ioutil.Discard); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,78 @@
+// Copyright Bjørn Borud 2019 Use of this source code is governed by
+// the license found in the accompanying LICENSE file.
+
+package points
+
+import (
+	"image"
+	"math"
+)
+
+// lumaHistogram counts the pixels of img at each of 256 levels of
+// luma.  Pixels that are completely transparent are left out.
+func lumaHistogram(img image.Image, luma lumaFunc) [256]int {
+	var hist [256]int
+
+	b := img.Bounds()
+	at := pixelReader(img)
+	for y := b.Min.Y; y < b.Max.Y; y++ {
+		for x := b.Min.X; x < b.Max.X; x++ {
+			r, g, bl, a := at(x, y)
+			if a == 0 {
+				continue
+			}
+			r, g, bl = unpremultiply(r, g, bl, a)
+			hist[lumaLevel(luma(r>>8, g>>8, bl>>8))]++
+		}
+	}
+	return hist
+}
+
+// equalization returns the table that maps each level of hist to its
+// equalized luma, which is the fraction of the pixels at or below it,
+// the cumulative distribution.  The levels are shifted so the darkest
+// level in use becomes 0.0, and the brightest always becomes 1.0, so
+// the lumas are spread over the whole range with about as many pixels
+// at each.  A histogram where all pixels are at one level, or that is
+// empty, maps every level to itself.
+func equalization(hist [256]int) [256]float64 {
+	var table [256]float64
+
+	var cdf [256]int
+	sum := 0
+	for i, n := range hist {
+		sum += n
+		cdf[i] = sum
+	}
+
+	// The number of pixels at the darkest level in use
+	first := 0
+	for _, n := range hist {
+		if n > 0 {
+			first = n
+			break
+		}
+	}
+
+	for i := range table {
+		if sum == first {
+			table[i] = float64(i) / 255
+			continue
+		}
+		table[i] = math.Max(0, float64(cdf[i]-first)/float64(sum-first))
+	}
+	return table
+}
+
+// equalized returns a luma function that maps the luma of luma
+// through the table made by equalization.
+func equalized(luma lumaFunc, table *[256]float64) lumaFunc {
+	return func(r uint32, g uint32, b uint32) float64 {
+		return table[lumaLevel(luma(r, g, b))]
+	}
+}
+
+// lumaLevel returns the index of the nearest of 256 levels of luma.
+func lumaLevel(luma float64) int {
+	return clampInt(int(luma*255+0.5), 0, 255)
+}
@@ -0,0 +1,78 @@
+// Copyright Bjørn Borud 2019 Use of this source code is governed by
+// the license found in the accompanying LICENSE file.
+
+package points
+
+import (
+	"image"
+	"image/color"
+	"math"
+	"testing"
+)
+
+func TestEqualization(t *testing.T) {
+	var spread, single, empty [256]int
+	for i := 100; i <= 150; i++ {
+		spread[i] = 10
+	}
+	single[80] = 50
+
+	tests := []struct {
+		name  string
+		hist  [256]int
+		level int
+		want  float64
+	}{
+		{"darkest in use", spread, 100, 0},
+		{"below", spread, 50, 0},
+		{"middle", spread, 125, 0.5},
+		{"brightest in use", spread, 150, 1},
+		{"above", spread, 200, 1},
+		{"single level", single, 80, 80.0 / 255},
+		{"empty", empty, 200, 200.0 / 255},
+	}
+
+	for _, test := range tests {
+		table := equalization(test.hist)
+		if got := table[test.level]; math.Abs(got-test.want) > 1e-9 {
+			t.Errorf("%s: expected level %d to map to %v, got %v", test.name, test.level, test.want, got)
+		}
+	}
+}
+
+func TestEqualize(t *testing.T) {
+	// A gradient over a quarter of the range of luma
+	img := image.NewGray(image.Rect(0, 0, 64, 8))
+	for x := 0; x < 64; x++ {
+		for y := 0; y < 8; y++ {
+			img.SetGray(x, y, color.Gray{uint8(0x60 + x)})
+		}
+	}
+
+	// The range of the sizes of the dots, which follow the luma
+	spread := func(equalize bool) float64 {
+		opts := DefaultOptions()
+		opts.BoxSize = 4
+		opts.Equalize = equalize
+
+		r, err := layout(img, opts)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		lo, hi := 1.0, 0.0
+		for _, d := range r.dots {
+			lo = math.Min(lo, d.size)
+			hi = math.Max(hi, d.size)
+		}
+		return hi - lo
+	}
+
+	plain, equalized := spread(false), spread(true)
+	if plain > 0.3 {
+		t.Fatalf("expected the gradient to cover less than 0.3, got %v", plain)
+	}
+	if equalized < 0.9 {
+		t.Errorf("expected equalization to spread the luma from %v over most of the range, got %v", plain, equalized)
+	}
+}
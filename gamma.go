@@ -0,0 +1,66 @@
+// Copyright Bjørn Borud 2019 Use of this source code is governed by
+// the license found in the accompanying LICENSE file.
+
+package points
+
+import (
+	"image"
+	"image/color"
+	"math"
+)
+
+// gamma approximates the sRGB transfer curve.
+const gamma = 2.2
+
+// linearTable maps 8 bit sRGB channel values to linear light.
+var linearTable [256]float64
+
+func init() {
+	for i := range linearTable {
+		linearTable[i] = math.Pow(float64(i)/255.0, gamma)
+	}
+}
+
+// toLinear converts a 16 bit channel value, as returned by
+// color.Color.RGBA, to linear light between 0.0 and 1.0.
+func toLinear(v uint32) float64 {
+	return linearTable[v>>8]
+}
+
+// fromLinear converts linear light between 0.0 and 1.0 back to an 8
+// bit sRGB channel value.
+func fromLinear(v float64) uint8 {
+	return uint8(math.Pow(v, 1/gamma)*255.0 + 0.5)
+}
+
+// linearMeanColor returns the alpha weighted average color of the
+// box, averaged in linear light rather than in sRGB.  Averaging sRGB
+// values directly makes the result too dark, so a box that is half
+// black and half white averages to about 186 rather than 128.
+func linearMeanColor(img image.Image, box image.Rectangle) (color.RGBA, float64) {
+	var rSum, gSum, bSum, aSum float64
+
+	at := pixelReader(img)
+	for cx := box.Min.X; cx < box.Max.X; cx++ {
+		for cy := box.Min.Y; cy < box.Max.Y; cy++ {
+			r, g, b, a := at(cx, cy)
+			if a == 0 {
+				continue
+			}
+
+			weight := float64(a) / 0xffff
+			r, g, b = unpremultiply(r, g, b, a)
+			rSum += toLinear(r) * weight
+			gSum += toLinear(g) * weight
+			bSum += toLinear(b) * weight
+			aSum += weight
+		}
+	}
+
+	if aSum == 0 {
+		return color.RGBA{A: 0xff}, 0
+	}
+
+	pixels := float64(box.Dx() * box.Dy())
+	return color.RGBA{fromLinear(rSum / aSum), fromLinear(gSum / aSum), fromLinear(bSum / aSum), 0xff}, aSum / pixels
+}
@@ -0,0 +1,48 @@
+// Copyright Bjørn Borud 2019 Use of this source code is governed by
+// the license found in the accompanying LICENSE file.
+
+package points
+
+import (
+	"image"
+	"image/color"
+	"image/draw"
+	"testing"
+)
+
+func TestLinearMean(t *testing.T) {
+	// Half black and half white
+	img := image.NewRGBA(image.Rect(0, 0, 20, 20))
+	draw.Draw(img, image.Rect(10, 0, 20, 20), image.NewUniform(color.White), image.Point{}, draw.Src)
+	draw.Draw(img, image.Rect(0, 0, 10, 20), image.NewUniform(color.Black), image.Point{}, draw.Src)
+
+	tests := []struct {
+		name   string
+		space  string
+		linear bool
+		lo, hi uint8
+	}{
+		{"srgb", ColorSpaceSRGB, false, 127, 128},
+		{"linear", ColorSpaceLinear, false, 184, 190},
+		{"deprecated linear", "", true, 184, 190},
+	}
+
+	for _, test := range tests {
+		opts := DefaultOptions()
+		opts.ColorSpace = test.space
+		opts.Linear = test.linear
+
+		c, _ := boxColor(img, img.Bounds(), opts)
+		if c.R < test.lo || c.R > test.hi || c.G != c.R || c.B != c.R {
+			t.Errorf("%s: expected a gray from %d to %d, got %v", test.name, test.lo, test.hi, c)
+		}
+	}
+}
+
+func TestLinearRoundTrip(t *testing.T) {
+	for v := 0; v < 256; v++ {
+		if got := fromLinear(toLinear(uint32(v) * 0x101)); got != uint8(v) {
+			t.Errorf("expected %d to survive the round trip through linear light, got %d", v, got)
+		}
+	}
+}
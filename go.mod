@@ -1,5 +1,10 @@
 module github.com/borud/points
 
-go 1.12
+go 1.18
 
-require github.com/ajstarks/svgo v0.0.0-20181006003313-6ce6a3bcf6cd
+require (
+	github.com/ajstarks/svgo v0.0.0-20181006003313-6ce6a3bcf6cd
+	github.com/jung-kurt/gofpdf v1.16.2
+	github.com/rwcarlsen/goexif v0.0.0-20190401172101-9e8deecbddbd
+	golang.org/x/image v0.12.0
+)
@@ -0,0 +1,72 @@
+// Copyright Bjørn Borud 2019 Use of this source code is governed by
+// the license found in the accompanying LICENSE file.
+
+package points
+
+import (
+	"image"
+	"image/color"
+	"image/draw"
+	"math"
+	"testing"
+)
+
+func TestGradientAngle(t *testing.T) {
+	tests := []struct {
+		name  string
+		white image.Rectangle
+		want  float64
+	}{
+		{"flat", image.Rectangle{}, 0},
+		{"brighter to the right", image.Rect(10, 0, 20, 20), 0},
+		{"brighter to the left", image.Rect(0, 0, 10, 20), math.Pi},
+		{"brighter to the bottom", image.Rect(0, 10, 20, 20), math.Pi / 2},
+		{"brighter to the top", image.Rect(0, 0, 20, 10), -math.Pi / 2},
+	}
+
+	for _, test := range tests {
+		img := image.NewGray(image.Rect(0, 0, 20, 20))
+		draw.Draw(img, test.white, image.NewUniform(color.White), image.Point{}, draw.Src)
+
+		if got := gradientAngle(img, img.Bounds()); math.Abs(got-test.want) > 1e-9 {
+			t.Errorf("%s: expected %.3f, got %.3f", test.name, test.want, got)
+		}
+	}
+}
+
+func TestEdgeMagnitude(t *testing.T) {
+	// A single vertical edge running through the middle box
+	img := image.NewGray(image.Rect(0, 0, 60, 20))
+	draw.Draw(img, image.Rect(30, 0, 60, 20), image.NewUniform(color.White), image.Point{}, draw.Src)
+
+	tests := []struct {
+		box  image.Rectangle
+		want float64
+	}{
+		{image.Rect(0, 0, 20, 20), 0},
+		{image.Rect(20, 0, 40, 20), 1},
+		{image.Rect(40, 0, 60, 20), 0},
+	}
+
+	for _, test := range tests {
+		if got := edgeMagnitude(img, test.box); math.Abs(got-test.want) > 1e-9 {
+			t.Errorf("%v: expected %g, got %g", test.box, test.want, got)
+		}
+	}
+
+	// Only the box with the edge gets a dot
+	opts := DefaultOptions()
+	opts.BoxSize = 20
+	opts.Edges = EdgesOnly
+
+	r, err := layout(img, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for x, d := range r.dots {
+		if got, want := d.visible && d.radius > 0, x == 1; got != want {
+			t.Errorf("box %d: expected a dot %v, got %+v", x, want, d)
+		}
+	}
+}
@@ -0,0 +1,121 @@
+// Copyright Bjørn Borud 2019 Use of this source code is governed by
+// the license found in the accompanying LICENSE file.
+
+package points
+
+import (
+	"image"
+	"math"
+)
+
+// grid describes how the image is divided up into boxes.  Each box
+// becomes one dot.
+type grid struct {
+	width     int
+	height    int
+	boxWidth  int
+	boxHeight int
+	hex       bool
+
+	// wrap keeps the boxes along the right and bottom edges whole
+	// rather than cutting them short, for tileable output.
+	wrap bool
+
+	// colStep and rowStep are the distances between the columns
+	// and rows, which are the size of the boxes unless they are
+	// spaced out by Density.  On a hexagonal grid the rows are
+	// closer together so the offset rows tessellate.
+	colStep int
+	rowStep float64
+
+	cols int
+	rows int
+}
+
+func newGrid(bounds image.Rectangle, opts Options) grid {
+	g := grid{
+		width:     bounds.Dx(),
+		height:    bounds.Dy(),
+		boxWidth:  opts.BoxSize,
+		boxHeight: opts.BoxSize,
+		hex:       opts.Hex,
+		wrap:      opts.Tileable,
+	}
+
+	if opts.BoxWidth > 0 {
+		g.boxWidth = opts.BoxWidth
+	}
+
+	if opts.BoxHeight > 0 {
+		g.boxHeight = opts.BoxHeight
+	}
+
+	// Terminal cells are taller than they are wide, so for ASCII
+	// output the boxes are made taller to keep the aspect ratio,
+	// unless we have been given the height.
+	if opts.Format == FormatASCII && opts.CharAspect > 0 && opts.BoxHeight == 0 {
+		g.boxHeight = maxInt(1, int(math.Round(float64(g.boxWidth)*opts.CharAspect)))
+	}
+
+	g.colStep = g.boxWidth
+	g.rowStep = float64(g.boxHeight)
+
+	// Rectangular boxes keep their shape, so they are spaced out as
+	// much down as across.
+	if opts.Density > 0 {
+		g.colStep = opts.Density
+		g.rowStep = float64(opts.Density) * float64(g.boxHeight) / float64(g.boxWidth)
+	}
+
+	if g.hex {
+		g.rowStep = g.rowStep * math.Sqrt(3) / 2
+	}
+
+	// Round up so the partial boxes along the right and bottom edges
+	// are included.
+	g.cols = (g.width + g.colStep - 1) / g.colStep
+	g.rows = int(math.Ceil(float64(g.height) / g.rowStep))
+
+	// Boxes that overlap would otherwise keep going until they start
+	// at the edge, so stop once a box reaches it, unless the grid
+	// wraps around and the boxes past the edge are needed to close it
+	// up.
+	if !g.wrap && g.colStep < g.boxWidth {
+		g.cols = maxInt(1, int(math.Ceil(float64(g.width-g.boxWidth)/float64(g.colStep)))+1)
+	}
+	if !g.wrap && g.rowStep < float64(g.boxHeight) {
+		g.rows = maxInt(1, int(math.Ceil(float64(g.height-g.boxHeight)/g.rowStep))+1)
+	}
+
+	return g
+}
+
+// box returns the box in column x and row y.  The rectangle is
+// relative to the top left corner of the image, and reaches past the
+// right and bottom edges if the grid wraps around.  The second return
+// value is false if the box falls outside the image.
+func (g grid) box(x int, y int) (image.Rectangle, bool) {
+	// Top left corner of the box
+	x0 := x * g.colStep
+	y0 := int(float64(y) * g.rowStep)
+
+	// Odd rows on a hexagonal grid are offset by half a step, which
+	// means the last box may fall outside the image.
+	if g.hex && y%2 == 1 {
+		x0 += g.colStep / 2
+		if x0 >= g.width {
+			return image.Rectangle{}, false
+		}
+	}
+
+	// Boxes along the right and bottom edges may be cut short by the
+	// edge of the image, unless it wraps around.
+	x1 := x0 + g.boxWidth
+	y1 := y0 + g.boxHeight
+	if !g.wrap {
+		x1 = minInt(x1, g.width)
+		y1 = minInt(y1, g.height)
+	}
+
+	return image.Rect(x0, y0, x1, y1), true
+}
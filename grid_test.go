@@ -0,0 +1,133 @@
+// Copyright Bjørn Borud 2019 Use of this source code is governed by
+// the license found in the accompanying LICENSE file.
+
+package points
+
+import (
+	"image"
+	"image/color"
+	"image/draw"
+	"math"
+	"testing"
+)
+
+func TestHexGrid(t *testing.T) {
+	opts := DefaultOptions()
+	opts.BoxSize = 20
+	opts.Hex = true
+
+	g := newGrid(image.Rect(0, 0, 100, 100), opts)
+
+	if want := 20 * math.Sqrt(3) / 2; math.Abs(g.rowStep-want) > 1e-9 {
+		t.Errorf("expected the rows to be %.3f apart, got %.3f", want, g.rowStep)
+	}
+
+	// 100 / 17.32 rounded up
+	if g.cols != 5 || g.rows != 6 {
+		t.Errorf("expected 5x6 boxes, got %dx%d", g.cols, g.rows)
+	}
+
+	tests := []struct {
+		x, y int
+		want image.Rectangle
+		ok   bool
+	}{
+		{0, 0, image.Rect(0, 0, 20, 20), true},
+		{1, 0, image.Rect(20, 0, 40, 20), true},
+		{0, 1, image.Rect(10, 17, 30, 37), true},
+		{4, 1, image.Rect(90, 17, 100, 37), true},
+		{0, 2, image.Rect(0, 34, 20, 54), true},
+		{0, 5, image.Rect(10, 86, 30, 100), true},
+	}
+
+	for _, test := range tests {
+		got, ok := g.box(test.x, test.y)
+		if got != test.want || ok != test.ok {
+			t.Errorf("box(%d, %d) = %v, %v, expected %v, %v", test.x, test.y, got, ok, test.want, test.ok)
+		}
+	}
+
+	// The offset can push the last box of an odd row out of the
+	// image
+	if _, ok := newGrid(image.Rect(0, 0, 95, 100), opts).box(4, 1); !ok {
+		t.Errorf("expected the last box of an odd row to be kept when it starts within the image")
+	}
+	g = newGrid(image.Rect(0, 0, 90, 100), opts)
+	if _, ok := g.box(g.cols-1, 1); ok {
+		t.Errorf("expected the last box of an odd row to fall outside a 90 pixel wide image")
+	}
+}
+
+func TestRectangularBoxes(t *testing.T) {
+	// Black on top and white below, split where the first row of
+	// boxes ends
+	img := image.NewGray(image.Rect(0, 0, 100, 80))
+	draw.Draw(img, image.Rect(0, 40, 100, 80), image.NewUniform(color.White), image.Point{}, draw.Src)
+
+	opts := DefaultOptions()
+	opts.BoxWidth = 20
+	opts.BoxHeight = 40
+
+	r, err := layout(img, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if r.grid.cols != 5 || r.grid.rows != 2 {
+		t.Fatalf("expected 5x2 boxes, got %dx%d", r.grid.cols, r.grid.rows)
+	}
+
+	for x := 0; x < r.grid.cols; x++ {
+		top := r.dots[x*r.grid.rows]
+		if !top.visible || top.cx != x*20+10 || top.cy != 20 {
+			t.Errorf("expected a dot at %d,20, got %+v", x*20+10, top)
+		}
+
+		// The radius is limited by the narrow side of the box
+		if top.radius != 10 {
+			t.Errorf("expected radius 10 in column %d, got %d", x, top.radius)
+		}
+
+		if bottom := r.dots[x*r.grid.rows+1]; bottom.visible {
+			t.Errorf("expected no dot in the white box of column %d, got %+v", x, bottom)
+		}
+	}
+}
+
+func TestDensity(t *testing.T) {
+	img := image.NewGray(image.Rect(0, 0, 200, 100))
+
+	// Counts the columns and rows of visible dots
+	count := func(density int) (int, int) {
+		opts := DefaultOptions()
+		opts.BoxSize = 20
+		opts.Density = density
+
+		r, err := layout(img, opts)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		xs, ys := map[int]bool{}, map[int]bool{}
+		for _, d := range r.dots {
+			if d.visible {
+				xs[d.cx] = true
+				ys[d.cy] = true
+			}
+		}
+		return len(xs), len(ys)
+	}
+
+	cols, rows := count(0)
+	denseCols, denseRows := count(10)
+
+	if cols != 10 || rows != 5 {
+		t.Fatalf("expected 10x5 dots without density, got %dx%d", cols, rows)
+	}
+
+	// The boxes along the edges stay whole, so it is one short of
+	// twice as many each way
+	if denseCols != 2*cols-1 || denseRows != 2*rows-1 {
+		t.Errorf("expected %dx%d dots half a box apart, got %dx%d", 2*cols-1, 2*rows-1, denseCols, denseRows)
+	}
+}
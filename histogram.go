@@ -0,0 +1,67 @@
+// Copyright Bjørn Borud 2019 Use of this source code is governed by
+// the license found in the accompanying LICENSE file.
+
+package points
+
+import (
+	"image/color"
+)
+
+// histogramBits is the number of bits per channel used to quantize
+// colors into histogram buckets.  With 4 bits per channel there are
+// 4096 buckets, which is coarse enough that slightly different shades
+// of the same color end up in the same bucket.
+const histogramBits = 4
+
+// bucket holds the number of pixels in a histogram bucket and the
+// sum of their (unquantized) channel values.
+type bucket struct {
+	count int
+	r     uint64
+	g     uint64
+	b     uint64
+}
+
+// histogram is a coarse color histogram keyed on the quantized color.
+type histogram map[uint16]*bucket
+
+// add adds a pixel to the histogram.  The channel values are 16 bit,
+// as returned by color.Color.RGBA.
+func (h histogram) add(r uint32, g uint32, b uint32) {
+	const shift = 16 - histogramBits
+
+	key := uint16((r>>shift)<<(2*histogramBits) | (g>>shift)<<histogramBits | b>>shift)
+
+	bk, ok := h[key]
+	if !ok {
+		bk = &bucket{}
+		h[key] = bk
+	}
+
+	bk.count++
+	bk.r += uint64(r)
+	bk.g += uint64(g)
+	bk.b += uint64(b)
+}
+
+// dominant returns the average color of the pixels in the fullest
+// bucket.  If several buckets are equally full the one with the
+// lowest key wins so the result doesn't depend on map ordering.
+func (h histogram) dominant() color.RGBA {
+	var best *bucket
+	var bestKey uint16
+
+	for key, bk := range h {
+		if best == nil || bk.count > best.count || (bk.count == best.count && key < bestKey) {
+			best = bk
+			bestKey = key
+		}
+	}
+
+	if best == nil {
+		return color.RGBA{A: 0xff}
+	}
+
+	n := uint64(best.count)
+	return color.RGBA{uint8(best.r / n >> 8), uint8(best.g / n >> 8), uint8(best.b / n >> 8), 0xff}
+}
@@ -0,0 +1,51 @@
+// Copyright Bjørn Borud 2019 Use of this source code is governed by
+// the license found in the accompanying LICENSE file.
+
+package points
+
+import (
+	"image/color"
+	"testing"
+)
+
+func TestHistogramDominant(t *testing.T) {
+	tests := []struct {
+		name   string
+		pixels [][3]uint32
+		want   color.RGBA
+	}{
+		{
+			"empty",
+			nil,
+			color.RGBA{A: 0xff},
+		},
+		{
+			// The reds are different shades but share a bucket and
+			// outnumber the blues
+			"fullest bucket",
+			[][3]uint32{
+				{0xf000, 0, 0}, {0xf200, 0, 0}, {0xf400, 0x0800, 0},
+				{0, 0, 0xffff}, {0, 0, 0xffff},
+			},
+			color.RGBA{0xf2, 0x02, 0, 0xff},
+		},
+		{
+			// Equally full buckets go to the lowest key, which is
+			// the one with the least red
+			"tie",
+			[][3]uint32{{0xffff, 0, 0}, {0, 0xffff, 0}},
+			color.RGBA{0, 0xff, 0, 0xff},
+		},
+	}
+
+	for _, test := range tests {
+		h := histogram{}
+		for _, p := range test.pixels {
+			h.add(p[0], p[1], p[2])
+		}
+
+		if got := h.dominant(); got != test.want {
+			t.Errorf("%s: expected %v, got %v", test.name, test.want, got)
+		}
+	}
+}
@@ -0,0 +1,45 @@
+// Copyright Bjørn Borud 2019 Use of this source code is governed by
+// the license found in the accompanying LICENSE file.
+
+package points
+
+import (
+	"math/rand"
+)
+
+// jitterDots moves the center of each dot by a random offset of up to
+// opts.Jitter times half a box of g in each direction, keeping the
+// center within the width and height of the canvas unless the output
+// is tileable.  Only the center is kept inside, so like the dots of
+// the boxes along the edges, a dot near the edge can still be cut
+// off.  The random numbers come from opts.Seed and are drawn for
+// every box, visible or not, so the same seed always moves a dot the
+// same way.
+func jitterDots(dots []dot, g grid, width int, height int, opts Options) {
+	rnd := rand.New(rand.NewSource(opts.Seed))
+	reachX := opts.Jitter * float64(g.boxWidth/2) * canvasScale(opts)
+	reachY := opts.Jitter * float64(g.boxHeight/2) * canvasScale(opts)
+
+	for i := range dots {
+		dx := int((rnd.Float64()*2 - 1) * reachX)
+		dy := int((rnd.Float64()*2 - 1) * reachY)
+
+		if opts.Precision > 0 {
+			dx = snap(float64(dx), opts.Precision)
+			dy = snap(float64(dy), opts.Precision)
+		}
+
+		if !dots[i].visible {
+			continue
+		}
+
+		dots[i].cx += dx
+		dots[i].cy += dy
+
+		// Tileable output wraps the dots around instead
+		if !opts.Tileable {
+			dots[i].cx = clampInt(dots[i].cx, 0, width)
+			dots[i].cy = clampInt(dots[i].cy, 0, height)
+		}
+	}
+}
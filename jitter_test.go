@@ -0,0 +1,56 @@
+// Copyright Bjørn Borud 2019 Use of this source code is governed by
+// the license found in the accompanying LICENSE file.
+
+package points
+
+import (
+	"bytes"
+	"image"
+	"testing"
+)
+
+func TestJitter(t *testing.T) {
+	img := image.NewGray(image.Rect(0, 0, 100, 60))
+
+	render := func(seed int64) []byte {
+		opts := DefaultOptions()
+		opts.BoxSize = 10
+		opts.Jitter = 1.0
+		opts.Seed = seed
+
+		b, err := RenderBytes(img, opts)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return b
+	}
+
+	if !bytes.Equal(render(42), render(42)) {
+		t.Errorf("expected the same seed to give identical output")
+	}
+
+	if bytes.Equal(render(42), render(43)) {
+		t.Errorf("expected different seeds to give different output")
+	}
+}
+
+func TestJitterInside(t *testing.T) {
+	img := image.NewGray(image.Rect(0, 0, 100, 60))
+
+	opts := DefaultOptions()
+	opts.BoxSize = 10
+	opts.Jitter = 1.0
+
+	r, err := layout(img, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The boxes along the edges are moved outwards as often as not,
+	// so without the clamping some centers would end up outside
+	for _, d := range r.dots {
+		if d.cx < 0 || d.cy < 0 || d.cx > 100 || d.cy > 60 {
+			t.Errorf("expected the dot at %d,%d to be inside the canvas", d.cx, d.cy)
+		}
+	}
+}
@@ -0,0 +1,62 @@
+// Copyright Bjørn Borud 2019 Use of this source code is governed by
+// the license found in the accompanying LICENSE file.
+
+package points
+
+import (
+	"encoding/json"
+	"fmt"
+	"image/color"
+	"io"
+	"math"
+)
+
+// Dot is a dot as written by FormatJSON, for programs that want to
+// draw the dots themselves.  CX, CY and R are in the same units as
+// the SVG, that is after scaling.  Color is the fill as #rrggbb, and
+// Opacity, from 0.0 to 1.0, is only given for dots that aren't
+// opaque, so nil means opaque.  Angle is the rotation of the shape in
+// degrees clockwise, and is left out for dots that aren't rotated.
+type Dot struct {
+	CX      int      `json:"cx"`
+	CY      int      `json:"cy"`
+	R       int      `json:"r"`
+	Color   string   `json:"color"`
+	Opacity *float64 `json:"opacity,omitempty"`
+	Angle   float64  `json:"angle,omitempty"`
+}
+
+// writeJSON writes the visible dots to w as a JSON array of Dot, in
+// the order they would be drawn.
+func writeJSON(dots []dot, opts Options, w io.Writer) error {
+	out := make([]Dot, 0, len(dots))
+
+	for _, d := range dots {
+		if !d.visible {
+			continue
+		}
+
+		c := color.RGBA{A: 0xff}
+		if opts.Color {
+			c = d.color
+		}
+
+		jd := Dot{
+			CX:    d.cx,
+			CY:    d.cy,
+			R:     d.radius,
+			Color: hexColor(c),
+			Angle: math.Round(d.angle*180/math.Pi*10) / 10,
+		}
+		if d.color.A < 0xff {
+			opacity := math.Round(float64(d.color.A)/0xff*1000) / 1000
+			jd.Opacity = &opacity
+		}
+		out = append(out, jd)
+	}
+
+	if err := json.NewEncoder(w).Encode(out); err != nil {
+		return fmt.Errorf("error writing json: %v", err)
+	}
+	return nil
+}
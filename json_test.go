@@ -0,0 +1,139 @@
+// Copyright Bjørn Borud 2019 Use of this source code is governed by
+// the license found in the accompanying LICENSE file.
+
+package points
+
+import (
+	"bytes"
+	"encoding/json"
+	"image"
+	"image/color"
+	"image/draw"
+	"testing"
+)
+
+func TestJSON(t *testing.T) {
+	// 2x2 boxes: black, dark red and mid gray, and a white one that
+	// gets no dot
+	img := image.NewRGBA(image.Rect(0, 0, 40, 40))
+	draw.Draw(img, img.Bounds(), image.NewUniform(color.White), image.Point{}, draw.Src)
+	draw.Draw(img, image.Rect(0, 0, 20, 20), image.NewUniform(color.Black), image.Point{}, draw.Src)
+	draw.Draw(img, image.Rect(0, 20, 20, 40), image.NewUniform(color.RGBA{0x80, 0x00, 0x00, 0xff}), image.Point{}, draw.Src)
+	draw.Draw(img, image.Rect(20, 0, 40, 20), image.NewUniform(color.Gray{0x80}), image.Point{}, draw.Src)
+
+	opts := DefaultOptions()
+	opts.BoxSize = 20
+	opts.Scale = 2
+	opts.Format = FormatJSON
+
+	out, err := RenderBytes(img, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var dots []Dot
+	if err := json.Unmarshal(out, &dots); err != nil {
+		t.Fatalf("unmarshal: %v in %s", err, out)
+	}
+
+	want := []Dot{
+		{CX: 20, CY: 20, R: 20, Color: "#000000"},
+		{CX: 20, CY: 60, R: 16, Color: "#800000"},
+		{CX: 60, CY: 20, R: 9, Color: "#808080"},
+	}
+
+	if len(dots) != len(want) {
+		t.Fatalf("expected %d dots, got %d in %s", len(want), len(dots), out)
+	}
+	for i := range want {
+		if dots[i] != want[i] {
+			t.Errorf("dot %d: expected %+v, got %+v", i, want[i], dots[i])
+		}
+	}
+}
+
+func TestJSONOpacity(t *testing.T) {
+	// An opaque dot, a half transparent one and one that is fully
+	// transparent
+	dots := []dot{
+		{cx: 1, radius: 1, color: color.RGBA{A: 0xff}, visible: true},
+		{cx: 2, radius: 1, color: color.RGBA{A: 0x80}, visible: true},
+		{cx: 3, radius: 1, color: color.RGBA{A: 0x00}, visible: true},
+	}
+
+	var buf bytes.Buffer
+	if err := writeJSON(dots, DefaultOptions(), &buf); err != nil {
+		t.Fatal(err)
+	}
+
+	var got []Dot
+	if err := json.Unmarshal(buf.Bytes(), &got); err != nil {
+		t.Fatalf("unmarshal: %v in %s", err, buf.Bytes())
+	}
+	if len(got) != 3 {
+		t.Fatalf("expected 3 dots, got %d in %s", len(got), buf.Bytes())
+	}
+
+	if got[0].Opacity != nil {
+		t.Errorf("expected no opacity for the opaque dot, got %v", *got[0].Opacity)
+	}
+	if got[1].Opacity == nil || *got[1].Opacity != 0.502 {
+		t.Errorf("expected an opacity of 0.502 for the half transparent dot in %s", buf.Bytes())
+	}
+	if got[2].Opacity == nil || *got[2].Opacity != 0 {
+		t.Errorf("expected an opacity of 0 for the transparent dot in %s", buf.Bytes())
+	}
+}
+
+func TestEdgeCoverage(t *testing.T) {
+	// None of the sizes are multiples of the box size.  box is the
+	// size of a box on the canvas.
+	tests := []struct {
+		name          string
+		size          image.Point
+		opts          func(o *Options)
+		width, height int
+		box           int
+	}{
+		{"plain", image.Pt(105, 73), func(o *Options) {}, 105, 73, 10},
+		{"tall", image.Pt(99, 101), func(o *Options) {}, 99, 101, 10},
+		{"wide", image.Pt(1001, 333), func(o *Options) {}, 1001, 333, 10},
+		{"scale", image.Pt(99, 101), func(o *Options) { o.Scale = 2 }, 198, 202, 20},
+		{"hex", image.Pt(105, 73), func(o *Options) { o.Hex = true }, 105, 73, 10},
+		{"density", image.Pt(105, 73), func(o *Options) { o.Density = 7 }, 105, 73, 10},
+		{"maxdim", image.Pt(105, 73), func(o *Options) { o.MaxDim = 50 }, 105, 73, 21},
+		{"canvasscale", image.Pt(105, 73), func(o *Options) { o.CanvasScale = 3 }, 315, 219, 30},
+	}
+
+	for _, test := range tests {
+		img := image.NewGray(image.Rectangle{Max: test.size})
+
+		opts := DefaultOptions()
+		opts.BoxSize = 10
+		opts.Format = FormatJSON
+		test.opts(&opts)
+
+		out, err := RenderBytes(img, opts)
+		if err != nil {
+			t.Fatalf("%s: %v", test.name, err)
+		}
+
+		var dots []Dot
+		if err := json.Unmarshal(out, &dots); err != nil {
+			t.Fatalf("%s: %v", test.name, err)
+		}
+
+		right, bottom := 0, 0
+		for _, d := range dots {
+			right = maxInt(right, d.CX+d.R)
+			bottom = maxInt(bottom, d.CY+d.R)
+		}
+
+		if right < test.width-test.box {
+			t.Errorf("%s: expected the dots to reach within %d of the width %d, got %d", test.name, test.box, test.width, right)
+		}
+		if bottom < test.height-test.box {
+			t.Errorf("%s: expected the dots to reach within %d of the height %d, got %d", test.name, test.box, test.height, bottom)
+		}
+	}
+}
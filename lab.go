@@ -0,0 +1,115 @@
+// Copyright Bjørn Borud 2019 Use of this source code is governed by
+// the license found in the accompanying LICENSE file.
+
+package points
+
+import (
+	"image"
+	"image/color"
+	"math"
+)
+
+// The D65 white point, which sRGB is defined relative to.
+const (
+	whiteX = 0.95047
+	whiteY = 1.0
+	whiteZ = 1.08883
+)
+
+// toLab converts an sRGB color to CIELAB.  L is from 0 to 100 and a
+// and b are roughly from -128 to 127.
+func toLab(c color.RGBA) (float64, float64, float64) {
+	r := linearTable[c.R]
+	g := linearTable[c.G]
+	b := linearTable[c.B]
+
+	// From linear sRGB to CIE XYZ
+	x := 0.4124*r + 0.3576*g + 0.1805*b
+	y := 0.2126*r + 0.7152*g + 0.0722*b
+	z := 0.0193*r + 0.1192*g + 0.9505*b
+
+	fx := labF(x / whiteX)
+	fy := labF(y / whiteY)
+	fz := labF(z / whiteZ)
+
+	return 116*fy - 16, 500 * (fx - fy), 200 * (fy - fz)
+}
+
+// fromLab converts a CIELAB color back to sRGB.  Colors outside the
+// sRGB gamut are clamped to it.
+func fromLab(l float64, a float64, b float64) color.RGBA {
+	fy := (l + 16) / 116
+	fx := fy + a/500
+	fz := fy - b/200
+
+	x := labFInverse(fx) * whiteX
+	y := labFInverse(fy) * whiteY
+	z := labFInverse(fz) * whiteZ
+
+	// From CIE XYZ to linear sRGB, the inverse of the matrix in
+	// toLab
+	r := 3.240625*x - 1.537208*y - 0.498629*z
+	g := -0.968931*x + 1.875756*y + 0.041518*z
+	bl := 0.055710*x - 0.204021*y + 1.056996*z
+
+	clamp := func(v float64) float64 {
+		return math.Max(0, math.Min(1, v))
+	}
+	return color.RGBA{fromLinear(clamp(r)), fromLinear(clamp(g)), fromLinear(clamp(bl)), 0xff}
+}
+
+// labF is the non-linear part of the XYZ to CIELAB conversion.  It is
+// a cube root with a linear segment near zero.
+func labF(t float64) float64 {
+	const delta = 6.0 / 29.0
+
+	if t > delta*delta*delta {
+		return math.Cbrt(t)
+	}
+	return t/(3*delta*delta) + 4.0/29.0
+}
+
+// labFInverse is the inverse of labF.
+func labFInverse(t float64) float64 {
+	const delta = 6.0 / 29.0
+
+	if t > delta {
+		return t * t * t
+	}
+	return 3 * delta * delta * (t - 4.0/29.0)
+}
+
+// labMeanColor returns the alpha weighted average color of the box,
+// averaged in CIELAB.  Averaging a saturated red and green in sRGB
+// gives a dark olive of #7f7f00.  In CIELAB their lightness is
+// averaged, and the a axis, along which they are opposites, cancels
+// out, which gives the light mustard yellow of #c7aa00 the two mix to
+// from a distance.
+func labMeanColor(img image.Image, box image.Rectangle) (color.RGBA, float64) {
+	var lSum, aSum, bSum, wSum float64
+
+	at := pixelReader(img)
+	for cx := box.Min.X; cx < box.Max.X; cx++ {
+		for cy := box.Min.Y; cy < box.Max.Y; cy++ {
+			r, g, b, a := at(cx, cy)
+			if a == 0 {
+				continue
+			}
+
+			weight := float64(a) / 0xffff
+			r, g, b = unpremultiply(r, g, b, a)
+			l, la, lb := toLab(color.RGBA{uint8(r >> 8), uint8(g >> 8), uint8(b >> 8), 0xff})
+			lSum += l * weight
+			aSum += la * weight
+			bSum += lb * weight
+			wSum += weight
+		}
+	}
+
+	if wSum == 0 {
+		return color.RGBA{A: 0xff}, 0
+	}
+
+	pixels := float64(box.Dx() * box.Dy())
+	return fromLab(lSum/wSum, aSum/wSum, bSum/wSum), wSum / pixels
+}
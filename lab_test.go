@@ -0,0 +1,60 @@
+// Copyright Bjørn Borud 2019 Use of this source code is governed by
+// the license found in the accompanying LICENSE file.
+
+package points
+
+import (
+	"image"
+	"image/color"
+	"image/draw"
+	"math"
+	"testing"
+)
+
+func TestToLab(t *testing.T) {
+	tests := []struct {
+		c       color.RGBA
+		l, a, b float64
+	}{
+		{color.RGBA{0xff, 0xff, 0xff, 0xff}, 100, 0, 0},
+		{color.RGBA{0, 0, 0, 0xff}, 0, 0, 0},
+		{color.RGBA{0xff, 0, 0, 0xff}, 53.24, 80.09, 67.20},
+		{color.RGBA{0, 0xff, 0, 0xff}, 87.73, -86.18, 83.18},
+		{color.RGBA{0, 0, 0xff, 0xff}, 32.30, 79.19, -107.86},
+	}
+
+	for _, test := range tests {
+		l, a, b := toLab(test.c)
+		if math.Abs(l-test.l) > 0.05 || math.Abs(a-test.a) > 0.05 || math.Abs(b-test.b) > 0.05 {
+			t.Errorf("%v: expected %.2f %.2f %.2f, got %.2f %.2f %.2f", test.c, test.l, test.a, test.b, l, a, b)
+		}
+
+		if got := fromLab(l, a, b); got != test.c {
+			t.Errorf("%v: expected the same color back, got %v", test.c, got)
+		}
+	}
+}
+
+func TestLabMeanColor(t *testing.T) {
+	// Saturated red on the left and green on the right
+	img := image.NewRGBA(image.Rect(0, 0, 20, 20))
+	draw.Draw(img, image.Rect(0, 0, 10, 20), image.NewUniform(color.RGBA{0xff, 0, 0, 0xff}), image.Point{}, draw.Src)
+	draw.Draw(img, image.Rect(10, 0, 20, 20), image.NewUniform(color.RGBA{0, 0xff, 0, 0xff}), image.Point{}, draw.Src)
+
+	tests := []struct {
+		colorSpace string
+		want       color.RGBA
+	}{
+		{ColorSpaceSRGB, color.RGBA{0x7f, 0x7f, 0x00, 0xff}},
+		{ColorSpaceLab, color.RGBA{0xc7, 0xaa, 0x00, 0xff}},
+	}
+
+	for _, test := range tests {
+		opts := DefaultOptions()
+		opts.ColorSpace = test.colorSpace
+
+		if got, _ := boxColor(img, img.Bounds(), opts); got != test.want {
+			t.Errorf("%s: expected %v, got %v", test.colorSpace, test.want, got)
+		}
+	}
+}
@@ -0,0 +1,172 @@
+// Copyright Bjørn Borud 2019 Use of this source code is governed by
+// the license found in the accompanying LICENSE file.
+
+package points
+
+import (
+	"math"
+)
+
+// The standards for calculating luma.
+const (
+	LumaBT601    = "bt601"
+	LumaBT709    = "bt709"
+	LumaBT2020   = "bt2020"
+	LumaSMPTE240 = "smpte240"
+)
+
+func validLuma(luma string) bool {
+	switch luma {
+	case "", LumaBT601, LumaBT709, LumaBT2020, LumaSMPTE240:
+		return true
+	}
+	return false
+}
+
+// The channels Options.Channel can size the dots by.
+const (
+	ChannelLuma  = "luma"
+	ChannelRed   = "r"
+	ChannelGreen = "g"
+	ChannelBlue  = "b"
+)
+
+func validChannel(channel string) bool {
+	switch channel {
+	case "", ChannelLuma, ChannelRed, ChannelGreen, ChannelBlue:
+		return true
+	}
+	return false
+}
+
+// The metrics Options.Metric can size the dots by.
+const (
+	MetricLuma       = "luma"
+	MetricSaturation = "saturation"
+	MetricValue      = "value"
+)
+
+func validMetric(metric string) bool {
+	switch metric {
+	case "", MetricLuma, MetricSaturation, MetricValue:
+		return true
+	}
+	return false
+}
+
+// lumaFunc calculates the luma from 8 bit RGB values.  The value
+// returned is between 0.0 and 1.0 so it is convenient to be used for
+// scaling other values.
+type lumaFunc func(r uint32, g uint32, b uint32) float64
+
+// lumaWith returns a luma function that weighs the red, green and
+// blue channels by wr, wg and wb.  The weights should add up to 1.0.
+// Rounding can make the weighted sum land a hair off, which would
+// give white a dot at the default threshold, so grays are taken
+// straight from their channel value and the rest is clamped to
+// [0.0, 1.0].
+func lumaWith(wr float64, wg float64, wb float64) lumaFunc {
+	return func(r uint32, g uint32, b uint32) float64 {
+		if r == g && g == b {
+			return float64(r) / 255.0
+		}
+		luma := ((wr * float64(r)) + (wg * float64(g)) + (wb * float64(b))) / 255.0
+		return math.Max(0, math.Min(1, luma))
+	}
+}
+
+// Calculate luma based on rgb values using ITU BT.709.
+var lumaBT709 = lumaWith(0.2126, 0.7152, 0.0722)
+
+// Calculate luma based on rgb values using ITU BT.601.  This gives
+// more weight to the red and blue components.
+var lumaBT601 = lumaWith(0.299, 0.587, 0.114)
+
+// Calculate luma based on rgb values using ITU BT.2020, which is used
+// for HDR and wide gamut content.
+var lumaBT2020 = lumaWith(0.2627, 0.6780, 0.0593)
+
+// Calculate luma based on rgb values using SMPTE 240M, which is used
+// for some older HDTV material.
+var lumaSMPTE240M = lumaWith(0.212, 0.701, 0.087)
+
+// lumaSaturation stands in for the luma when sizing the dots by
+// saturation.  It is one minus the HSV saturation, so the most
+// saturated colors get the biggest dots, like the darkest do when
+// sizing by luma, and grays get none.
+func lumaSaturation(r uint32, g uint32, b uint32) float64 {
+	_, s, _ := toHSV(r, g, b)
+	return 1 - s
+}
+
+// lumaValue stands in for the luma when sizing the dots by the HSV
+// value, the brightest of the channels.
+func lumaValue(r uint32, g uint32, b uint32) float64 {
+	_, _, v := toHSV(r, g, b)
+	return v
+}
+
+// levels stretches luma so opts.BlackPoint becomes 0.0 and
+// opts.WhitePoint becomes 1.0.  Anything outside is clamped.
+func levels(luma float64, opts Options) float64 {
+	white := opts.WhitePoint
+	if white == 0 {
+		white = 1.0
+	}
+
+	if opts.BlackPoint == 0 && white == 1.0 {
+		return luma
+	}
+
+	return math.Max(0, math.Min(1, (luma-opts.BlackPoint)/(white-opts.BlackPoint)))
+}
+
+// chooseLuma returns the luma function selected by the options,
+// equalized if Equalize is set.
+func chooseLuma(opts Options) lumaFunc {
+	luma := baseLuma(opts)
+	if opts.equalization != nil {
+		return equalized(luma, opts.equalization)
+	}
+	return luma
+}
+
+// baseLuma returns the luma function selected by the options before
+// any equalization.  A single channel is taken as is, and
+// LumaWeights, if given, are scaled so they add up to 1.0.
+func baseLuma(opts Options) lumaFunc {
+	switch opts.Metric {
+	case MetricSaturation:
+		return lumaSaturation
+	case MetricValue:
+		return lumaValue
+	}
+
+	switch opts.Channel {
+	case ChannelRed:
+		return lumaWith(1, 0, 0)
+	case ChannelGreen:
+		return lumaWith(0, 1, 0)
+	case ChannelBlue:
+		return lumaWith(0, 0, 1)
+	}
+
+	if w := opts.LumaWeights; w != [3]float64{} {
+		sum := w[0] + w[1] + w[2]
+		return lumaWith(w[0]/sum, w[1]/sum, w[2]/sum)
+	}
+
+	switch opts.Luma {
+	case LumaBT709:
+		return lumaBT709
+	case LumaBT2020:
+		return lumaBT2020
+	case LumaSMPTE240:
+		return lumaSMPTE240M
+	}
+
+	if opts.BT709 {
+		return lumaBT709
+	}
+	return lumaBT601
+}
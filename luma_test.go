@@ -0,0 +1,235 @@
+// Copyright Bjørn Borud 2019 Use of this source code is governed by
+// the license found in the accompanying LICENSE file.
+
+package points
+
+import (
+	"image"
+	"image/color"
+	"image/draw"
+	"math"
+	"testing"
+)
+
+func TestLumaBT601(t *testing.T) {
+	tests := []struct {
+		name    string
+		r, g, b uint32
+		want    float64
+	}{
+		{"black", 0, 0, 0, 0.0},
+		{"white", 255, 255, 255, 1.0},
+		{"red", 255, 0, 0, 0.299},
+		{"green", 0, 255, 0, 0.587},
+		{"blue", 0, 0, 255, 0.114},
+	}
+
+	for _, test := range tests {
+		got := lumaBT601(test.r, test.g, test.b)
+		if math.Abs(got-test.want) > 1e-9 {
+			t.Errorf("%s: expected %g, got %g", test.name, test.want, got)
+		}
+	}
+
+	// White has to be exactly 1.0, or it would get a dot at the
+	// default threshold of 1.0
+	if got := lumaBT601(255, 255, 255); got != 1.0 {
+		t.Errorf("expected exactly 1.0 for white, got %v", got)
+	}
+}
+
+func TestLumaWeights(t *testing.T) {
+	opts := DefaultOptions()
+	opts.LumaWeights = [3]float64{1, 0, 0}
+	luma := baseLuma(opts)
+
+	// Only the red channel should count
+	tests := []struct {
+		r, g, b uint32
+	}{
+		{0, 0, 0},
+		{64, 255, 0},
+		{128, 0, 255},
+		{255, 128, 128},
+	}
+
+	for _, test := range tests {
+		want := float64(test.r) / 255.0
+		if got := luma(test.r, test.g, test.b); math.Abs(got-want) > 1e-9 {
+			t.Errorf("%d,%d,%d: expected %g, got %g", test.r, test.g, test.b, want, got)
+		}
+	}
+
+	// The weights are scaled so they add up to 1.0
+	opts.LumaWeights = [3]float64{2, 0, 0}
+	if got := baseLuma(opts)(128, 0, 0); math.Abs(got-128.0/255.0) > 1e-9 {
+		t.Errorf("expected scaled weights, got %g", got)
+	}
+}
+
+func TestLumaWhiteAndBlack(t *testing.T) {
+	tests := []struct {
+		name string
+		luma lumaFunc
+	}{
+		{LumaBT601, lumaBT601},
+		{LumaBT709, lumaBT709},
+		{LumaBT2020, lumaBT2020},
+		{LumaSMPTE240, lumaSMPTE240M},
+		{"weights", baseLuma(Options{LumaWeights: [3]float64{0.3, 0.3, 0.3}})},
+		{ChannelRed, baseLuma(Options{Channel: ChannelRed})},
+	}
+
+	for _, test := range tests {
+		if got := test.luma(255, 255, 255); got != 1.0 {
+			t.Errorf("%s: expected exactly 1.0 for white, got %v", test.name, got)
+		}
+		if got := test.luma(0, 0, 0); got != 0.0 {
+			t.Errorf("%s: expected exactly 0.0 for black, got %v", test.name, got)
+		}
+	}
+}
+
+func TestLevels(t *testing.T) {
+	tests := []struct {
+		black, white float64
+		luma         float64
+		want         float64
+	}{
+		{0, 0, 0.4, 0.4},
+		{0, 1, 0.4, 0.4},
+		{0.2, 0.6, 0.4, 0.5},
+		{0.2, 0.6, 0.1, 0.0},
+		{0.2, 0.6, 0.9, 1.0},
+	}
+
+	for _, test := range tests {
+		opts := Options{BlackPoint: test.black, WhitePoint: test.white}
+		if got := levels(test.luma, opts); math.Abs(got-test.want) > 1e-9 {
+			t.Errorf("levels(%g) with %g-%g: expected %g, got %g", test.luma, test.black, test.white, test.want, got)
+		}
+	}
+}
+
+func TestLevelsRadius(t *testing.T) {
+	// A box a little darker than the middle gray
+	img := image.NewGray(image.Rect(0, 0, 20, 20))
+	draw.Draw(img, img.Bounds(), image.NewUniform(color.Gray{0x66}), image.Point{}, draw.Src)
+
+	radius := func(black float64, white float64) int {
+		opts := DefaultOptions()
+		opts.BoxSize = 20
+		opts.BlackPoint = black
+		opts.WhitePoint = white
+
+		r, err := layout(img, opts)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return r.dots[0].radius
+	}
+
+	wide, narrow := radius(0, 1), radius(0.3, 0.8)
+	if narrow <= wide {
+		t.Errorf("expected the dot to grow from radius %d when the levels are narrowed, got %d", wide, narrow)
+	}
+
+	opts := DefaultOptions()
+	opts.BlackPoint = 0.6
+	opts.WhitePoint = 0.4
+	if err := opts.Validate(); err == nil {
+		t.Errorf("expected an error for a white point below the black point")
+	}
+}
+
+func TestChannel(t *testing.T) {
+	red := image.NewRGBA(image.Rect(0, 0, 40, 40))
+	draw.Draw(red, red.Bounds(), image.NewUniform(color.RGBA{0xff, 0, 0, 0xff}), image.Point{}, draw.Src)
+
+	// The size of the dot of a black box
+	opts := DefaultOptions()
+	opts.BoxSize = 20
+	r, err := layout(image.NewGray(red.Bounds()), opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	largest := r.dots[0].radius
+
+	tests := []struct {
+		channel string
+		visible int
+		radius  int
+	}{
+		{ChannelRed, 0, 0},
+		{ChannelGreen, 4, largest},
+		{ChannelBlue, 4, largest},
+	}
+
+	for _, test := range tests {
+		opts := DefaultOptions()
+		opts.BoxSize = 20
+		opts.Channel = test.channel
+
+		r, err := layout(red, opts)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		visible := 0
+		for _, d := range r.dots {
+			if !d.visible {
+				continue
+			}
+			visible++
+			if d.radius != test.radius {
+				t.Errorf("%s: expected radius %d, got %d", test.channel, test.radius, d.radius)
+			}
+		}
+		if visible != test.visible {
+			t.Errorf("%s: expected %d dots, got %d", test.channel, test.visible, visible)
+		}
+	}
+}
+
+func TestMetric(t *testing.T) {
+	// Saturated red and a mid gray
+	img := image.NewRGBA(image.Rect(0, 0, 40, 20))
+	draw.Draw(img, image.Rect(0, 0, 20, 20), image.NewUniform(color.RGBA{0xff, 0, 0, 0xff}), image.Point{}, draw.Src)
+	draw.Draw(img, image.Rect(20, 0, 40, 20), image.NewUniform(color.RGBA{0x80, 0x80, 0x80, 0xff}), image.Point{}, draw.Src)
+
+	// The size of the dot of a black box
+	opts := DefaultOptions()
+	opts.BoxSize = 20
+	r, err := layout(image.NewGray(img.Bounds()), opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	largest := r.dots[0].radius
+
+	size := func(metric string) (int, int) {
+		opts := DefaultOptions()
+		opts.BoxSize = 20
+		opts.Metric = metric
+
+		r, err := layout(img, opts)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return r.dots[0].radius, r.dots[1].radius
+	}
+
+	// The red is saturated and the gray not at all
+	if red, gray := size(MetricSaturation); red != largest || gray != 0 {
+		t.Errorf("saturation: expected radius %d for the red and 0 for the gray, got %d and %d", largest, red, gray)
+	}
+
+	// The brightest channel of the red is at its brightest
+	if red, gray := size(MetricValue); red != 0 || gray == 0 {
+		t.Errorf("value: expected no dot for the red and one for the gray, got %d and %d", red, gray)
+	}
+
+	// The luma of the red is 0.3 and that of the gray 0.5
+	if red, gray := size(MetricLuma); red <= gray || gray == 0 {
+		t.Errorf("luma: expected the red dot to be larger than the gray, got %d and %d", red, gray)
+	}
+}
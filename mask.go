@@ -0,0 +1,49 @@
+// Copyright Bjørn Borud 2019 Use of this source code is governed by
+// the license found in the accompanying LICENSE file.
+
+package points
+
+import (
+	"image"
+	"image/color"
+	"math"
+)
+
+// masked returns true if opts.Mask keeps box from getting a dot.  The
+// box is relative to the top left corner of img, and the mask is
+// stretched over img to find the part of it that box covers.
+func masked(img image.Image, box image.Rectangle, opts Options) bool {
+	if opts.Mask == nil {
+		return false
+	}
+	return maskValue(opts.Mask, box, img.Bounds().Size()) < 0.5
+}
+
+// maskValue returns the mean gray, from 0.0 to 1.0, of the part of
+// mask that box covers when mask is stretched over an image of the
+// given size.  The part covers at least one pixel of the mask, and
+// since the gray is premultiplied transparent pixels count as black.
+func maskValue(mask image.Image, box image.Rectangle, size image.Point) float64 {
+	mb := mask.Bounds()
+	sx := float64(mb.Dx()) / float64(size.X)
+	sy := float64(mb.Dy()) / float64(size.Y)
+
+	r := image.Rect(
+		int(math.Floor(float64(box.Min.X)*sx)),
+		int(math.Floor(float64(box.Min.Y)*sy)),
+		maxInt(int(math.Ceil(float64(box.Max.X)*sx)), int(math.Floor(float64(box.Min.X)*sx))+1),
+		maxInt(int(math.Ceil(float64(box.Max.Y)*sy)), int(math.Floor(float64(box.Min.Y)*sy))+1),
+	).Add(mb.Min).Intersect(mb)
+
+	if r.Empty() {
+		return 0
+	}
+
+	var sum float64
+	for y := r.Min.Y; y < r.Max.Y; y++ {
+		for x := r.Min.X; x < r.Max.X; x++ {
+			sum += float64(color.Gray16Model.Convert(mask.At(x, y)).(color.Gray16).Y)
+		}
+	}
+	return sum / 0xffff / float64(r.Dx()*r.Dy())
+}
@@ -0,0 +1,69 @@
+// Copyright Bjørn Borud 2019 Use of this source code is governed by
+// the license found in the accompanying LICENSE file.
+
+package points
+
+import (
+	"image"
+	"image/color"
+	"image/draw"
+	"testing"
+)
+
+func TestMask(t *testing.T) {
+	img := image.NewRGBA(image.Rect(0, 0, 80, 40))
+	draw.Draw(img, img.Bounds(), image.NewUniform(color.Black), image.Point{}, draw.Src)
+
+	// A mask of the same size and one that has to be stretched over
+	// the image, both black on the left half and white on the right
+	for _, size := range []image.Point{{80, 40}, {20, 10}} {
+		mask := image.NewGray(image.Rectangle{Max: size})
+		draw.Draw(mask, image.Rect(size.X/2, 0, size.X, size.Y), image.NewUniform(color.White), image.Point{}, draw.Src)
+
+		opts := DefaultOptions()
+		opts.BoxSize = 10
+		opts.Mask = mask
+
+		r, err := layout(img, opts)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		visible := 0
+		for _, d := range r.dots {
+			if !d.visible {
+				continue
+			}
+			visible++
+			if d.cx < 40 {
+				t.Errorf("%v: expected no dots on the masked half, got one at %d,%d", size, d.cx, d.cy)
+			}
+		}
+
+		// The right half has 4x4 boxes
+		if visible != 16 {
+			t.Errorf("%v: expected 16 dots, got %d", size, visible)
+		}
+	}
+}
+
+func TestMaskValue(t *testing.T) {
+	mask := image.NewGray(image.Rect(0, 0, 4, 1))
+	copy(mask.Pix, []uint8{0x00, 0xff, 0xff, 0xff})
+
+	tests := []struct {
+		box  image.Rectangle
+		want float64
+	}{
+		{image.Rect(0, 0, 2, 1), 0.5},
+		{image.Rect(2, 0, 4, 1), 1},
+		{image.Rect(0, 0, 4, 1), 0.75},
+		{image.Rect(0, 0, 1, 1), 0},
+	}
+
+	for _, test := range tests {
+		if got := maskValue(mask, test.box, image.Pt(4, 1)); got != test.want {
+			t.Errorf("%v: expected %v, got %v", test.box, test.want, got)
+		}
+	}
+}
@@ -0,0 +1,103 @@
+// Copyright Bjørn Borud 2019 Use of this source code is governed by
+// the license found in the accompanying LICENSE file.
+
+package points
+
+import (
+	"image/color"
+	"math"
+)
+
+// mergeFlat merges each run of horizontally adjacent dots in a row
+// of the grid whose colors are all within opts.MergeFlat of the first
+// one into a single dot.  The merged dot is at the combined center of
+// the run, has its average color and covers as much area as the whole
+// run did, so flat areas keep their tone with far fewer dots.  It
+// takes the place of the first dot of the run and the rest are made
+// invisible, so the dots stay in the column order of computeDots.
+func mergeFlat(dots []dot, g grid, opts Options) {
+	limit := opts.MergeFlat * 0xff
+
+	for y := 0; y < g.rows; y++ {
+		for x := 0; x < g.cols; {
+			first := x*g.rows + y
+
+			run := 1
+			for x+run < g.cols && flatWith(dots[first], dots[(x+run)*g.rows+y], limit) {
+				run++
+			}
+
+			if run > 1 {
+				merged := make([]dot, run)
+				for i := range merged {
+					merged[i] = dots[(x+i)*g.rows+y]
+					dots[(x+i)*g.rows+y] = dot{}
+				}
+				dots[first] = mergeDots(merged, opts)
+			}
+			x += run
+		}
+	}
+}
+
+// flatWith returns true if b is close enough to a to be merged with
+// it, that is if both are visible and no channel, including alpha,
+// differs by more than limit.
+func flatWith(a dot, b dot, limit float64) bool {
+	if !a.visible || !b.visible {
+		return false
+	}
+
+	for _, d := range [4]float64{
+		float64(a.color.R) - float64(b.color.R),
+		float64(a.color.G) - float64(b.color.G),
+		float64(a.color.B) - float64(b.color.B),
+		float64(a.color.A) - float64(b.color.A),
+	} {
+		if math.Abs(d) > limit {
+			return false
+		}
+	}
+	return true
+}
+
+// mergeDots returns the dot that replaces the run of dots.  Its
+// radius is that of a dot with the area of all of them together.
+func mergeDots(run []dot, opts Options) dot {
+	var cx, cy, size, area float64
+	var sum [4]float64
+	var ink [4]float64
+
+	for _, d := range run {
+		cx += float64(d.cx)
+		cy += float64(d.cy)
+		size += d.size
+		area += float64(d.radius) * float64(d.radius)
+		for i, v := range [4]uint8{d.color.R, d.color.G, d.color.B, d.color.A} {
+			sum[i] += float64(v)
+		}
+		for i, v := range [4]uint8{d.cmyk.C, d.cmyk.M, d.cmyk.Y, d.cmyk.K} {
+			ink[i] += float64(v)
+		}
+	}
+
+	n := float64(len(run))
+	mean := func(v float64) uint8 {
+		return uint8(math.Round(v / n))
+	}
+
+	d := run[0]
+	d.cx = int(math.Round(cx / n))
+	d.cy = int(math.Round(cy / n))
+	d.size = size / n
+	d.radius = int(math.Sqrt(area))
+	d.color = color.RGBA{mean(sum[0]), mean(sum[1]), mean(sum[2]), mean(sum[3])}
+	d.cmyk = color.CMYK{C: mean(ink[0]), M: mean(ink[1]), Y: mean(ink[2]), K: mean(ink[3])}
+
+	if opts.Precision > 0 {
+		d.cx = snap(float64(d.cx), opts.Precision)
+		d.cy = snap(float64(d.cy), opts.Precision)
+		d.radius = snap(math.Sqrt(area), opts.Precision)
+	}
+	return d
+}
@@ -0,0 +1,89 @@
+// Copyright Bjørn Borud 2019 Use of this source code is governed by
+// the license found in the accompanying LICENSE file.
+
+package points
+
+import (
+	"image"
+	"image/color"
+	"image/draw"
+	"math"
+	"strings"
+	"testing"
+)
+
+func TestMergeFlat(t *testing.T) {
+	// A flat area three boxes wide, then two boxes that differ from
+	// it and from each other
+	img := image.NewGray(image.Rect(0, 0, 100, 40))
+	draw.Draw(img, image.Rect(0, 0, 60, 40), image.NewUniform(color.Gray{0x40}), image.Point{}, draw.Src)
+	draw.Draw(img, image.Rect(60, 0, 80, 40), image.NewUniform(color.Gray{0xa0}), image.Point{}, draw.Src)
+	draw.Draw(img, image.Rect(80, 0, 100, 40), image.NewUniform(color.Gray{0x10}), image.Point{}, draw.Src)
+
+	opts := DefaultOptions()
+	opts.BoxSize = 20
+
+	full, err := layout(img, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	opts.MergeFlat = 0.05
+	merged, err := layout(img, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var dots []dot
+	for _, d := range merged.dots {
+		if d.visible {
+			dots = append(dots, d)
+		}
+	}
+
+	// Each row keeps one dot for the flat area and one for each of
+	// the other two boxes
+	if len(dots) != 6 {
+		t.Fatalf("expected 6 dots where the full grid has %d, got %d", len(full.dots), len(dots))
+	}
+
+	// The flat area of the first row is replaced by one dot in its
+	// middle with the area of all three
+	d, box := dots[0], full.dots[0]
+	if d.cx != 30 || d.cy != 10 || d.color != box.color {
+		t.Errorf("expected a dot of %v at 30,10, got %+v", box.color, d)
+	}
+	if want := int(math.Sqrt(3 * float64(box.radius*box.radius))); d.radius != want {
+		t.Errorf("expected radius %d, got %d", want, d.radius)
+	}
+
+	out, err := RenderBytes(img, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n := strings.Count(string(out), "<circle"); n != 6 {
+		t.Errorf("expected 6 circles, got %d", n)
+	}
+}
+
+func TestFlatWith(t *testing.T) {
+	a := dot{visible: true, color: color.RGBA{0x40, 0x40, 0x40, 0xff}}
+
+	tests := []struct {
+		name string
+		b    dot
+		want bool
+	}{
+		{"same", a, true},
+		{"within", dot{visible: true, color: color.RGBA{0x4a, 0x36, 0x40, 0xff}}, true},
+		{"too far", dot{visible: true, color: color.RGBA{0x40, 0x40, 0x50, 0xff}}, false},
+		{"alpha", dot{visible: true, color: color.RGBA{0x40, 0x40, 0x40, 0xe0}}, false},
+		{"invisible", dot{color: a.color}, false},
+	}
+
+	for _, test := range tests {
+		if got := flatWith(a, test.b, 0.05*0xff); got != test.want {
+			t.Errorf("%s: expected %v, got %v", test.name, test.want, got)
+		}
+	}
+}
@@ -0,0 +1,80 @@
+// Copyright Bjørn Borud 2019 Use of this source code is governed by
+// the license found in the accompanying LICENSE file.
+
+package points
+
+import (
+	"image/color"
+	"strings"
+)
+
+// namedPalettes are the palettes ParsePalette knows by name.
+var namedPalettes = map[string][]color.RGBA{
+	"web":        webPalette(),
+	"grayscale4": {{0x00, 0x00, 0x00, 0xff}, {0x55, 0x55, 0x55, 0xff}, {0xaa, 0xaa, 0xaa, 0xff}, {0xff, 0xff, 0xff, 0xff}},
+}
+
+// webPalette returns the 216 web safe colors, where each channel is
+// one of 0x00, 0x33, 0x66, 0x99, 0xcc and 0xff.
+func webPalette() []color.RGBA {
+	var p []color.RGBA
+	for r := 0; r <= 0xff; r += 0x33 {
+		for g := 0; g <= 0xff; g += 0x33 {
+			for b := 0; b <= 0xff; b += 0x33 {
+				p = append(p, color.RGBA{uint8(r), uint8(g), uint8(b), 0xff})
+			}
+		}
+	}
+	return p
+}
+
+// ParsePalette parses a palette given either by name, web or
+// grayscale4, or as a comma separated list of colors on the form
+// #rrggbb.
+func ParsePalette(s string) ([]color.RGBA, error) {
+	if p, ok := namedPalettes[s]; ok {
+		return p, nil
+	}
+
+	var p []color.RGBA
+	for _, hex := range strings.Split(s, ",") {
+		c, err := ParseHexColor(strings.TrimSpace(hex))
+		if err != nil {
+			return nil, err
+		}
+		p = append(p, c)
+	}
+	return p, nil
+}
+
+// nearestColor returns the color in the palette closest to c, as
+// measured by the distance function.
+func nearestColor(c color.RGBA, palette []color.RGBA, distance func(a color.RGBA, b color.RGBA) float64) color.RGBA {
+	best := palette[0]
+	bestDist := distance(c, best)
+
+	for _, p := range palette[1:] {
+		if d := distance(c, p); d < bestDist {
+			best = p
+			bestDist = d
+		}
+	}
+	return best
+}
+
+// rgbDistance returns the squared Euclidean distance between two
+// colors in RGB.
+func rgbDistance(a color.RGBA, b color.RGBA) float64 {
+	dr := float64(a.R) - float64(b.R)
+	dg := float64(a.G) - float64(b.G)
+	db := float64(a.B) - float64(b.B)
+	return dr*dr + dg*dg + db*db
+}
+
+// labDistance returns the squared Euclidean distance between two
+// colors in CIELAB, which is closer to how different the colors look.
+func labDistance(a color.RGBA, b color.RGBA) float64 {
+	l1, a1, b1 := toLab(a)
+	l2, a2, b2 := toLab(b)
+	return (l1-l2)*(l1-l2) + (a1-a2)*(a1-a2) + (b1-b2)*(b1-b2)
+}
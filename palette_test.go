@@ -0,0 +1,64 @@
+// Copyright Bjørn Borud 2019 Use of this source code is governed by
+// the license found in the accompanying LICENSE file.
+
+package points
+
+import (
+	"image"
+	"image/color"
+	"image/draw"
+	"testing"
+)
+
+func TestNearestColor(t *testing.T) {
+	orange := color.RGBA{0xff, 0x80, 0x00, 0xff}
+	web, err := ParsePalette("web")
+	if err != nil {
+		t.Fatal(err)
+	}
+	custom, err := ParsePalette("#ff0000, #ffff00, #0000ff, #ffa500")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name     string
+		palette  []color.RGBA
+		distance func(a color.RGBA, b color.RGBA) float64
+		want     color.RGBA
+	}{
+		{"web", web, rgbDistance, color.RGBA{0xff, 0x99, 0x00, 0xff}},
+		{"custom", custom, rgbDistance, color.RGBA{0xff, 0xa5, 0x00, 0xff}},
+		{"custom lab", custom, labDistance, color.RGBA{0xff, 0xa5, 0x00, 0xff}},
+		{"grayscale4", namedPalettes["grayscale4"], rgbDistance, color.RGBA{0xaa, 0xaa, 0xaa, 0xff}},
+	}
+
+	for _, test := range tests {
+		if got := nearestColor(orange, test.palette, test.distance); got != test.want {
+			t.Errorf("%s: expected %v, got %v", test.name, test.want, got)
+		}
+	}
+
+	if _, err := ParsePalette("#ff0000,orange"); err == nil {
+		t.Errorf("expected an error for a color that isn't hex")
+	}
+}
+
+func TestPaletteDots(t *testing.T) {
+	img := image.NewRGBA(image.Rect(0, 0, 20, 20))
+	draw.Draw(img, img.Bounds(), image.NewUniform(color.RGBA{0xff, 0x80, 0x00, 0xff}), image.Point{}, draw.Src)
+
+	opts := DefaultOptions()
+	opts.BoxSize = 20
+	opts.Color = true
+	opts.Palette = []color.RGBA{{0xff, 0x00, 0x00, 0xff}, {0xff, 0xa5, 0x00, 0xff}}
+
+	r, err := layout(img, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if want := (color.RGBA{0xff, 0xa5, 0x00, 0xff}); r.dots[0].color != want {
+		t.Errorf("expected the orange box to snap to %v, got %v", want, r.dots[0].color)
+	}
+}
@@ -0,0 +1,128 @@
+// Copyright Bjørn Borud 2019 Use of this source code is governed by
+// the license found in the accompanying LICENSE file.
+
+package points
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"image/color"
+	"io"
+	"io/ioutil"
+	"strconv"
+	"strings"
+)
+
+// ReadGPL reads a palette in the GIMP palette format, which starts
+// with a line saying GIMP Palette and has a color on each line after
+// that, given as its red, green and blue values from 0 to 255,
+// optionally followed by its name:
+//
+//	GIMP Palette
+//	Name: Brand
+//	# The logo colors
+//	230  57  70	Red
+//	241 250 238	Off white
+//
+// The header lines, comments and empty lines are skipped.
+func ReadGPL(r io.Reader) ([]color.RGBA, error) {
+	scanner := bufio.NewScanner(r)
+
+	var p []color.RGBA
+	header := false
+	for n := 1; scanner.Scan(); n++ {
+		line := strings.TrimSpace(scanner.Text())
+
+		if !header {
+			if line != "GIMP Palette" {
+				return nil, errors.New("not a GIMP palette, expected it to start with GIMP Palette")
+			}
+			header = true
+			continue
+		}
+
+		if line == "" || strings.HasPrefix(line, "#") || strings.HasPrefix(line, "Name:") || strings.HasPrefix(line, "Columns:") {
+			continue
+		}
+
+		fields := strings.Fields(line)
+		if len(fields) < 3 {
+			return nil, fmt.Errorf("line %d: expected red, green and blue, got %q", n, line)
+		}
+
+		var rgb [3]uint8
+		for i := range rgb {
+			v, err := strconv.ParseUint(fields[i], 10, 8)
+			if err != nil {
+				return nil, fmt.Errorf("line %d: invalid value %q, expected 0 to 255", n, fields[i])
+			}
+			rgb[i] = uint8(v)
+		}
+		p = append(p, color.RGBA{rgb[0], rgb[1], rgb[2], 0xff})
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	if len(p) == 0 {
+		return nil, errors.New("palette has no colors")
+	}
+	return p, nil
+}
+
+// acoRGB is the color space of the colors ReadACO understands.
+const acoRGB = 0
+
+// ReadACO reads a palette in the Adobe color swatch format.  Only
+// swatches in RGB are supported.  Version 2 files also name their
+// colors, and the names are skipped.
+func ReadACO(r io.Reader) ([]color.RGBA, error) {
+	var header struct {
+		Version uint16
+		Count   uint16
+	}
+	if err := binary.Read(r, binary.BigEndian, &header); err != nil {
+		return nil, fmt.Errorf("error reading swatches: %v", err)
+	}
+
+	if header.Version != 1 && header.Version != 2 {
+		return nil, fmt.Errorf("unknown swatch file version %d", header.Version)
+	}
+
+	p := make([]color.RGBA, 0, header.Count)
+	for i := 0; i < int(header.Count); i++ {
+		var swatch struct {
+			Space  uint16
+			Values [4]uint16
+		}
+		if err := binary.Read(r, binary.BigEndian, &swatch); err != nil {
+			return nil, fmt.Errorf("error reading swatch %d: %v", i, err)
+		}
+
+		if swatch.Space != acoRGB {
+			return nil, fmt.Errorf("swatch %d is in color space %d, only RGB is supported", i, swatch.Space)
+		}
+
+		// The names are UTF-16 with their length in front
+		if header.Version == 2 {
+			var length uint32
+			if err := binary.Read(r, binary.BigEndian, &length); err != nil {
+				return nil, fmt.Errorf("error reading swatch %d: %v", i, err)
+			}
+			if _, err := io.CopyN(ioutil.Discard, r, int64(length)*2); err != nil {
+				return nil, fmt.Errorf("error reading swatch %d: %v", i, err)
+			}
+		}
+
+		v := swatch.Values
+		p = append(p, color.RGBA{uint8(v[0] >> 8), uint8(v[1] >> 8), uint8(v[2] >> 8), 0xff})
+	}
+
+	if len(p) == 0 {
+		return nil, errors.New("palette has no colors")
+	}
+	return p, nil
+}
@@ -0,0 +1,85 @@
+// Copyright Bjørn Borud 2019 Use of this source code is governed by
+// the license found in the accompanying LICENSE file.
+
+package points
+
+import (
+	"bytes"
+	"encoding/binary"
+	"image/color"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestReadGPL(t *testing.T) {
+	gpl := "GIMP Palette\n" +
+		"Name: Brand\n" +
+		"Columns: 3\n" +
+		"# The logo colors\n" +
+		"230  57  70\tRed\n" +
+		"\n" +
+		"241 250 238\tOff white\n" +
+		"  0   0   0\n"
+
+	p, err := ReadGPL(strings.NewReader(gpl))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := []color.RGBA{{230, 57, 70, 0xff}, {241, 250, 238, 0xff}, {0, 0, 0, 0xff}}
+	if !reflect.DeepEqual(p, want) {
+		t.Errorf("expected %v, got %v", want, p)
+	}
+}
+
+func TestReadGPLErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		gpl  string
+	}{
+		{"header", "230 57 70\n"},
+		{"no colors", "GIMP Palette\nName: Empty\n"},
+		{"too few values", "GIMP Palette\n230 57\n"},
+		{"out of range", "GIMP Palette\n230 57 256\n"},
+		{"not a number", "GIMP Palette\nred 57 70\n"},
+	}
+
+	for _, test := range tests {
+		if _, err := ReadGPL(strings.NewReader(test.gpl)); err == nil {
+			t.Errorf("%s: expected an error", test.name)
+		}
+	}
+}
+
+func TestReadACO(t *testing.T) {
+	want := []color.RGBA{{230, 57, 70, 0xff}, {241, 250, 238, 0xff}}
+
+	for _, version := range []uint16{1, 2} {
+		var buf bytes.Buffer
+		binary.Write(&buf, binary.BigEndian, [2]uint16{version, uint16(len(want))})
+		for _, c := range want {
+			binary.Write(&buf, binary.BigEndian, [5]uint16{acoRGB, uint16(c.R) * 0x101, uint16(c.G) * 0x101, uint16(c.B) * 0x101, 0})
+			if version == 2 {
+				// The name "ab" with its terminating zero
+				binary.Write(&buf, binary.BigEndian, uint32(3))
+				binary.Write(&buf, binary.BigEndian, [3]uint16{'a', 'b', 0})
+			}
+		}
+
+		p, err := ReadACO(&buf)
+		if err != nil {
+			t.Fatalf("version %d: %v", version, err)
+		}
+		if !reflect.DeepEqual(p, want) {
+			t.Errorf("version %d: expected %v, got %v", version, want, p)
+		}
+	}
+
+	// Only RGB swatches are supported
+	var buf bytes.Buffer
+	binary.Write(&buf, binary.BigEndian, [7]uint16{1, 1, 2, 0, 0, 0, 0})
+	if _, err := ReadACO(&buf); err == nil {
+		t.Errorf("expected an error for a CMYK swatch")
+	}
+}
@@ -0,0 +1,151 @@
+// Copyright Bjørn Borud 2019 Use of this source code is governed by
+// the license found in the accompanying LICENSE file.
+
+package points
+
+import (
+	"image/color"
+	"io"
+	"math"
+	"time"
+
+	"github.com/jung-kurt/gofpdf"
+)
+
+// writePDF draws the dots onto a single page PDF that is written to
+// w.  The page is measured in points, so it is as many points wide
+// and high as the SVG would be pixels.
+func writePDF(dots []dot, width int, height int, opts Options, w io.Writer) error {
+	return drawDots(&pdfCanvas{w: w, opts: opts}, dots, width, height, opts)
+}
+
+// pdfCanvas draws onto a PDF using gofpdf.
+type pdfCanvas struct {
+	pdf   *gofpdf.Fpdf
+	w     io.Writer
+	opts  Options
+	alpha uint8
+	mode  string
+}
+
+func (p *pdfCanvas) start(width int, height int, background color.Color) {
+	p.pdf = gofpdf.NewCustom(&gofpdf.InitType{
+		UnitStr: "pt",
+		Size:    gofpdf.SizeType{Wd: float64(width), Ht: float64(height)},
+	})
+	// gofpdf dates the PDF with the current time unless it is given
+	// one, which would make the output differ from run to run.
+	date := p.opts.Date
+	if date.IsZero() {
+		date = time.Unix(0, 0).UTC()
+	}
+	p.pdf.SetCreationDate(date)
+	p.pdf.SetModificationDate(date)
+
+	p.pdf.SetMargins(0, 0, 0)
+	p.pdf.SetAutoPageBreak(false, 0)
+	p.pdf.AddPage()
+	p.mode = "Normal"
+
+	if background != nil {
+		p.setFill(background)
+		p.pdf.Rect(0, 0, float64(width), float64(height), "F")
+	}
+
+	p.pdf.SetLineWidth(p.opts.LineWidth)
+	p.alpha = 0xff
+
+	// Lines and rings can't have a stroke of their own, so the
+	// stroke can be set once for the whole page.
+	if p.opts.Stroke != nil {
+		p.pdf.SetLineWidth(p.opts.StrokeWidth)
+		p.setStroke(p.opts.Stroke)
+	}
+}
+
+func (p *pdfCanvas) circle(cx float64, cy float64, r float64, c color.Color) {
+	p.setFill(c)
+	p.pdf.Circle(cx, cy, r, p.fillStyle())
+}
+
+func (p *pdfCanvas) rect(x float64, y float64, w float64, h float64, c color.Color) {
+	p.setFill(c)
+	p.pdf.Rect(x, y, w, h, p.fillStyle())
+}
+
+func (p *pdfCanvas) polygon(cx float64, cy float64, xs []float64, ys []float64, c color.Color) {
+	points := make([]gofpdf.PointType, len(xs))
+	for i := range xs {
+		points[i] = gofpdf.PointType{X: cx + xs[i], Y: cy + ys[i]}
+	}
+
+	p.setFill(c)
+	p.pdf.Polygon(points, p.fillStyle())
+}
+
+func (p *pdfCanvas) line(x1 float64, y1 float64, x2 float64, y2 float64, c color.Color) {
+	p.setStroke(c)
+	p.pdf.Line(x1, y1, x2, y2)
+}
+
+func (p *pdfCanvas) ring(cx float64, cy float64, r float64, c color.Color) {
+	p.setStroke(c)
+	p.pdf.Circle(cx, cy, r, "D")
+}
+
+func (p *pdfCanvas) rotate(angle float64, cx float64, cy float64) {
+	// The PDF rotates counterclockwise while our angles go clockwise
+	p.pdf.TransformBegin()
+	p.pdf.TransformRotate(-angle*180/math.Pi, cx, cy)
+}
+
+func (p *pdfCanvas) unrotate() {
+	p.pdf.TransformEnd()
+}
+
+func (p *pdfCanvas) layer(name string) {
+	// The layers are multiplied with what is under them, so the
+	// inks darken each other.
+	if p.mode != "Multiply" {
+		p.mode = "Multiply"
+		p.pdf.SetAlpha(float64(p.alpha)/0xff, p.mode)
+	}
+}
+
+func (p *pdfCanvas) end() error {
+	return p.pdf.Output(p.w)
+}
+
+// fillStyle returns the gofpdf style of filled shapes, which are
+// outlined as well if the options give a stroke.
+func (p *pdfCanvas) fillStyle() string {
+	if p.opts.Stroke != nil {
+		return "FD"
+	}
+	return "F"
+}
+
+// setFill sets the fill color of the PDF.
+func (p *pdfCanvas) setFill(c color.Color) {
+	rgba := rgbaOf(c)
+	p.pdf.SetFillColor(int(rgba.R), int(rgba.G), int(rgba.B))
+	p.setAlpha(rgba.A)
+}
+
+// setStroke sets the stroke color of the PDF.
+func (p *pdfCanvas) setStroke(c color.Color) {
+	rgba := rgbaOf(c)
+	p.pdf.SetDrawColor(int(rgba.R), int(rgba.G), int(rgba.B))
+	p.setAlpha(rgba.A)
+}
+
+// setAlpha sets the opacity of whatever is drawn next, keeping the
+// blend mode.  It is only passed on to the PDF when it changes since
+// each change adds to the size of the file.
+func (p *pdfCanvas) setAlpha(a uint8) {
+	if a == p.alpha {
+		return
+	}
+	p.pdf.SetAlpha(float64(a)/0xff, p.mode)
+	p.alpha = a
+}
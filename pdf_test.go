@@ -0,0 +1,47 @@
+// Copyright Bjørn Borud 2019 Use of this source code is governed by
+// the license found in the accompanying LICENSE file.
+
+package points
+
+import (
+	"bytes"
+	"image"
+	"testing"
+)
+
+func TestPDF(t *testing.T) {
+	img := image.NewGray(image.Rect(0, 0, 200, 100))
+
+	tests := []struct {
+		scale float64
+		want  string
+	}{
+		{1, "/MediaBox [0 0 200.00 100.00]"},
+		{2, "/MediaBox [0 0 400.00 200.00]"},
+	}
+
+	for _, test := range tests {
+		opts := DefaultOptions()
+		opts.BoxSize = 20
+		opts.Scale = test.scale
+		opts.Format = FormatPDF
+
+		out, err := RenderBytes(img, opts)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if !bytes.HasPrefix(out, []byte("%PDF-")) {
+			t.Errorf("scale %g: expected a PDF, got %q", test.scale, out)
+			continue
+		}
+
+		// A single page as many points wide and high as the canvas
+		if n := bytes.Count(out, []byte("/MediaBox")); n != 1 {
+			t.Errorf("scale %g: expected one page, got %d", test.scale, n)
+		}
+		if !bytes.Contains(out, []byte(test.want)) {
+			t.Errorf("scale %g: expected %s", test.scale, test.want)
+		}
+	}
+}
@@ -0,0 +1,68 @@
+// Copyright Bjørn Borud 2019 Use of this source code is governed by
+// the license found in the accompanying LICENSE file.
+
+package points
+
+import (
+	"image"
+	"image/color"
+)
+
+// pixelFunc returns the alpha premultiplied 16 bit channels of the
+// pixel at x, y, just like calling RGBA on the color returned by At.
+type pixelFunc func(x int, y int) (uint32, uint32, uint32, uint32)
+
+// pixelReader returns a pixelFunc for img.  Going through At costs an
+// interface call and an allocation for every pixel, which is most of
+// the time spent computing the dots, so the pixels of the image types
+// the decoders and resize return are read straight from their backing
+// slices.  Other images, and pixels outside the bounds, go through At.
+func pixelReader(img image.Image) pixelFunc {
+	switch img := img.(type) {
+	case *image.RGBA:
+		return func(x int, y int) (uint32, uint32, uint32, uint32) {
+			if !(image.Point{x, y}.In(img.Rect)) {
+				return img.At(x, y).RGBA()
+			}
+			i := img.PixOffset(x, y)
+			s := img.Pix[i : i+4 : i+4]
+			r, g, b, a := uint32(s[0]), uint32(s[1]), uint32(s[2]), uint32(s[3])
+			return r | r<<8, g | g<<8, b | b<<8, a | a<<8
+		}
+
+	case *image.NRGBA:
+		return func(x int, y int) (uint32, uint32, uint32, uint32) {
+			if !(image.Point{x, y}.In(img.Rect)) {
+				return img.At(x, y).RGBA()
+			}
+			i := img.PixOffset(x, y)
+			s := img.Pix[i : i+4 : i+4]
+			return color.NRGBA{s[0], s[1], s[2], s[3]}.RGBA()
+		}
+
+	case *image.RGBA64:
+		return func(x int, y int) (uint32, uint32, uint32, uint32) {
+			if !(image.Point{x, y}.In(img.Rect)) {
+				return img.At(x, y).RGBA()
+			}
+			i := img.PixOffset(x, y)
+			s := img.Pix[i : i+8 : i+8]
+			return uint32(s[0])<<8 | uint32(s[1]), uint32(s[2])<<8 | uint32(s[3]),
+				uint32(s[4])<<8 | uint32(s[5]), uint32(s[6])<<8 | uint32(s[7])
+		}
+
+	case *image.YCbCr:
+		return func(x int, y int) (uint32, uint32, uint32, uint32) {
+			if !(image.Point{x, y}.In(img.Rect)) {
+				return img.At(x, y).RGBA()
+			}
+			yi := img.YOffset(x, y)
+			ci := img.COffset(x, y)
+			return color.YCbCr{img.Y[yi], img.Cb[ci], img.Cr[ci]}.RGBA()
+		}
+	}
+
+	return func(x int, y int) (uint32, uint32, uint32, uint32) {
+		return img.At(x, y).RGBA()
+	}
+}
@@ -0,0 +1,104 @@
+// Copyright Bjørn Borud 2019 Use of this source code is governed by
+// the license found in the accompanying LICENSE file.
+
+package points
+
+import (
+	"image"
+	"math/rand"
+	"testing"
+)
+
+// opaqueImage hides the type of the image it wraps, so its pixels
+// can only be read through At.
+type opaqueImage struct {
+	image.Image
+}
+
+// pixelImages returns images of the types pixelReader has a fast path
+// for, filled with noise, along with one it has to read through At.
+func pixelImages(r image.Rectangle) map[string]image.Image {
+	rnd := rand.New(rand.NewSource(1))
+
+	rgba := image.NewRGBA(r)
+	rnd.Read(rgba.Pix)
+	// Premultiplied channels can't be larger than alpha
+	for i := 0; i < len(rgba.Pix); i += 4 {
+		a := rgba.Pix[i+3]
+		for j := 0; j < 3; j++ {
+			rgba.Pix[i+j] = uint8(int(rgba.Pix[i+j]) * int(a) / 0xff)
+		}
+	}
+
+	nrgba := image.NewNRGBA(r)
+	rnd.Read(nrgba.Pix)
+
+	rgba64 := image.NewRGBA64(r)
+	for y := r.Min.Y; y < r.Max.Y; y++ {
+		for x := r.Min.X; x < r.Max.X; x++ {
+			rgba64.Set(x, y, nrgba.At(x, y))
+		}
+	}
+
+	ycbcr := image.NewYCbCr(r, image.YCbCrSubsampleRatio420)
+	rnd.Read(ycbcr.Y)
+	rnd.Read(ycbcr.Cb)
+	rnd.Read(ycbcr.Cr)
+
+	return map[string]image.Image{
+		"rgba":    rgba,
+		"nrgba":   nrgba,
+		"rgba64":  rgba64,
+		"ycbcr":   ycbcr,
+		"generic": opaqueImage{nrgba},
+	}
+}
+
+func TestPixelReader(t *testing.T) {
+	// The bounds don't start at zero, like those of a sub image
+	r := image.Rect(3, 5, 40, 30)
+
+	for name, img := range pixelImages(r) {
+		pixel := pixelReader(img)
+
+		// One pixel outside all around as well
+		for y := r.Min.Y - 1; y <= r.Max.Y; y++ {
+			for x := r.Min.X - 1; x <= r.Max.X; x++ {
+				wr, wg, wb, wa := img.At(x, y).RGBA()
+				gr, gg, gb, ga := pixel(x, y)
+				if gr != wr || gg != wg || gb != wb || ga != wa {
+					t.Fatalf("%s: expected %x %x %x %x at %d,%d, got %x %x %x %x", name, wr, wg, wb, wa, x, y, gr, gg, gb, ga)
+				}
+			}
+		}
+	}
+}
+
+func BenchmarkComputeDots(b *testing.B) {
+	images := pixelImages(image.Rect(0, 0, 1600, 1200))
+
+	opts := DefaultOptions()
+	opts.BoxSize = 20
+	opts.Workers = 1
+
+	// Each image read from its backing slice, and the same image
+	// read through At
+	for _, name := range []string{"rgba", "nrgba", "ycbcr"} {
+		img := images[name]
+		g := newGrid(img.Bounds(), opts)
+
+		b.Run(name, func(b *testing.B) {
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				computeDots(img, g, opts)
+			}
+		})
+
+		b.Run(name+"-at", func(b *testing.B) {
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				computeDots(opaqueImage{img}, g, opts)
+			}
+		})
+	}
+}
@@ -0,0 +1,1124 @@
+// Copyright Bjørn Borud 2019 Use of this source code is governed by
+// the license found in the accompanying LICENSE file.
+
+// Package points turns a bitmap into colored dots whose diameter is
+// proportional to the luminescence of the region the dot represents
+// and whose color is the average color of the area.
+//
+// The output is SVG, or optionally PNG or PDF, written to any
+// io.Writer, so the package can be used from the command line utility
+// in cmd/points as well as from other programs.
+//
+// The dots are written in a fixed order, so the same image and
+// options always give the same output, byte for byte, however many
+// workers compute the dots.  On the regular grid the dots go by
+// column, x outer and y inner, so each column is written top to
+// bottom and the columns left to right.  Adaptive boxes and
+// stippling keep that order, with the dots of each adaptive grid box
+// in the order it was split.  Poisson dots come in the order they
+// were placed.  Rosette writes the screens one after the other, each
+// row by row along its turned grid.  Contours come in the order of
+// the first cell they cross, row by row.  Tileable output puts the
+// copies of a dot that reaches over an edge right after it.
+package points
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	"io"
+	"math"
+	"time"
+)
+
+// Options controls how an image is turned into dots.
+type Options struct {
+	// BoxSize is the length in pixels of the side of the square box
+	// each dot represents.
+	BoxSize int
+
+	// BoxWidth and BoxHeight make the boxes rectangular, which is
+	// useful when the output has pixels that aren't square.  Zero
+	// means BoxSize.  The dots are sized to fit the shorter side.
+	BoxWidth  int
+	BoxHeight int
+
+	// Density is the distance in pixels between the centers of
+	// neighboring boxes, while the boxes keep their size, so a
+	// Density below the box size makes the boxes, and the dots,
+	// overlap and one above it spreads them out.  Rectangular boxes
+	// are spaced as much down as across in proportion to their
+	// size.  Zero means the box size, so the boxes just touch.
+	Density int
+
+	// Scale is the factor with which the SVG will be scaled
+	// compared to the original image.  Unless CanvasScale is given it
+	// scales both the canvas and the dots, otherwise only the dots.
+	Scale float64
+
+	// CanvasScale is the factor with which the canvas, and where the
+	// dots are placed on it, will be scaled compared to the original
+	// image, leaving the size of the dots to Scale.  A large
+	// CanvasScale with a Scale of 1 spreads the dots out, and the
+	// other way around makes them overlap.  Zero means Scale.
+	CanvasScale float64
+
+	// Crop restricts the rendering to this part of the image.  The
+	// rectangle is relative to the top left corner of the image and
+	// the output is sized to fit it.  The zero rectangle means the
+	// whole image.
+	Crop image.Rectangle
+
+	// MaxDim limits the size of the image the dots are computed
+	// from.  If the longest side of the image is longer than this the
+	// image is scaled down before it is processed, and the scale is
+	// adjusted to make up for it so the output keeps the original
+	// size.  Zero means no limit.
+	MaxDim int
+
+	// Filter is how the image is scaled down for MaxDim.  One of
+	// FilterBilinear, FilterNearest or FilterCatmullRom.  Empty
+	// means FilterBilinear.
+	Filter string
+
+	// LumaThreshold suppresses dots whose luma is at or above this
+	// value.  Valid values are from 0.0 to 1.0.
+	LumaThreshold float64
+
+	// LumaThresholdLow suppresses dots whose luma is below this
+	// value, so together with LumaThreshold only the boxes within a
+	// band of luma get a dot, for instance to pick out the midtones.
+	// Valid values are from 0.0 up to LumaThreshold.  Zero means
+	// dark boxes are never suppressed.
+	LumaThresholdLow float64
+
+	// AutoThreshold picks the luma threshold from the image rather
+	// than using LumaThreshold.  The lumas of all the boxes are
+	// computed first, and the threshold is chosen with Otsu's method,
+	// or so Coverage of the boxes get a dot if that is given.
+	AutoThreshold bool
+
+	// Coverage is the fraction of the boxes, from 0.0 to 1.0, that
+	// AutoThreshold should give a dot.  Zero means Otsu's method is
+	// used instead.
+	Coverage float64
+
+	// Color fills each dot with the average color of its box rather
+	// than just black.
+	Color bool
+
+	// Luma is the standard used for luma calculations.  One of
+	// LumaBT601, LumaBT709, LumaBT2020 or LumaSMPTE240.  Empty means
+	// LumaBT601.
+	Luma string
+
+	// BT709 selects ITU BT.709 rather than BT.601 for luma
+	// calculations when Luma is empty or LumaBT601.
+	//
+	// Deprecated: set Luma to LumaBT709 instead.
+	BT709 bool
+
+	// LumaWeights are custom weights for the red, green and blue
+	// channels in luma calculations, which override Luma.  They are
+	// scaled to add up to 1.0.  All zero means the weights of the
+	// chosen standard are used.
+	LumaWeights [3]float64
+
+	// Channel sizes the dots by a single channel of the color of the
+	// box rather than by its luma, for screens meant to be printed
+	// in one ink.  One of ChannelRed, ChannelGreen, ChannelBlue or
+	// ChannelLuma, where the darker the channel the bigger the dot.
+	// Empty means ChannelLuma.
+	Channel string
+
+	// Metric is what the dots are sized by.  MetricLuma is the luma,
+	// where the darker the box the bigger the dot.  MetricSaturation
+	// gives the most saturated boxes the biggest dots and grays none,
+	// which suits vivid images, and MetricValue sizes them by the
+	// HSV value, the brightest channel of the box.  The thresholds,
+	// levels and Invert work on the metric just like on the luma.
+	// Empty means MetricLuma.
+	Metric string
+
+	// BlackPoint and WhitePoint stretch the luma so BlackPoint
+	// becomes black and WhitePoint becomes white before the dots are
+	// sized, which gives low contrast images more range.  Valid
+	// values are from 0.0 to 1.0.  A WhitePoint of zero means 1.0.
+	BlackPoint float64
+	WhitePoint float64
+
+	// Equalize spreads the lumas of the image evenly over the range
+	// from 0.0 to 1.0 with histogram equalization before the dots are
+	// sized, which brings out the detail in images whose tones are
+	// bunched together.  The histogram is made from the lumas of all
+	// the pixels.  The colors of the dots stay the same, apart from
+	// those of Gray and HSL, which come from the luma.
+	Equalize bool
+
+	// Edges makes the size of the dots follow the strength of the
+	// edges in their box, which gives a line drawing feel.  One of
+	// EdgesOnly or EdgesMultiply.  Empty means the edges are not
+	// used.
+	Edges string
+
+	// DotGamma raises the darkness of each box to this power before
+	// it is turned into a radius.  Values above 1.0 make the dots of
+	// dark areas stand out, while values below 1.0 make the dots of
+	// the midtones bigger.  Zero means 1.0.
+	DotGamma float64
+
+	// Opacity makes the dots of bright boxes transparent.  The
+	// opacity of each dot is the darkness of its box raised to this
+	// power, so 1.0 makes the opacity follow the darkness directly.
+	// Zero means the dots are opaque.
+	Opacity float64
+
+	// Vignette shrinks the dots toward the edges of the image.  The
+	// radius of each dot is multiplied by a factor that falls off
+	// with the distance from the center, from 1.0 there to 1.0 minus
+	// Vignette in the corners.  Valid values are from 0.0 to 1.0.
+	// Zero means no vignette.
+	Vignette float64
+
+	// LumaArea uses the luma as the surface area of the dot instead
+	// of its radius.
+	LumaArea bool
+
+	// AreaScale scales the radius of the dots when using LumaArea.
+	// With sqrt(pi), about 1.77, the dot of a black box is as wide as
+	// the box.  Zero means 1.7, which leaves a little space between
+	// the dots.
+	AreaScale float64
+
+	// Shape is the shape of the dots.  One of ShapeCircle,
+	// ShapeSquare, ShapeDiamond, ShapeTriangle, ShapeHexagon,
+	// ShapeStar or ShapeLine.  Empty means ShapeCircle.
+	Shape string
+
+	// LineWidth is the stroke width of ShapeLine and of the rings
+	// drawn for Concentric.
+	LineWidth float64
+
+	// Stroke, if not nil, outlines every dot with a stroke of this
+	// color that is StrokeWidth wide and centered on the edge of the
+	// dot.  It cannot be combined with ShapeLine or Concentric, whose
+	// dots are strokes already.
+	Stroke      color.Color
+	StrokeWidth float64
+
+	// Concentric draws each dot as up to this many concentric rings
+	// rather than filling it.  Darker boxes get more rings, so both
+	// the size of the dot and how dense its rings are follow the
+	// luma.  Only works with ShapeCircle.  Zero means filled dots.
+	Concentric int
+
+	// Soft fills each dot with a radial gradient from its color in
+	// the middle to transparent at the edge, which makes the dots
+	// glow.  The gradients are defined once for each color, with the
+	// channels quantized to 4 bits so alike colors share one, which
+	// keeps the SVG small.  Soft only works with FormatSVG and
+	// cannot be combined with ShapeLine or Concentric, whose dots
+	// aren't filled.
+	Soft bool
+
+	// CSS gives each dot the class dot, and in color mode also the
+	// class of its brightness bucket, b0 for the darkest to b7 for
+	// the brightest, rather than an inline color, so the dots can be
+	// restyled with CSS.  A style sheet at the top of the SVG gives
+	// each bucket the average color of its dots, or all the dots
+	// black in black mode.  The CMYK colors are left out.  CSS only
+	// works with FormatSVG and cannot be combined with Soft or
+	// Contour.
+	CSS bool
+
+	// Orient rotates the dots to follow the local gradient of the
+	// image, which gives a pen and ink feel.  Circles look the same
+	// whichever way they are rotated, so they are left alone.
+	Orient bool
+
+	// StarRatio is the ratio between the inner and the outer radius
+	// of ShapeStar.
+	StarRatio float64
+
+	// Tileable makes output that can be repeated like a tile without
+	// any seams.  The image is sampled as if it wraps around, so the
+	// boxes along the right and bottom edges pick up pixels from the
+	// left and top, and dots that reach over an edge are drawn on the
+	// opposite side as well.  The tiles only line up exactly when
+	// the width and height of the image are multiples of the box
+	// size.
+	Tileable bool
+
+	// Hex arranges the dots on a hexagonal grid where every other
+	// row is offset by half a box.
+	Hex bool
+
+	// Checker only draws every other box, like the black squares of
+	// a checkerboard, which are those whose column and row add up to
+	// an even number.  CheckerOdd draws the other half instead, so
+	// two renders make interleaved layers, for instance for testing
+	// the registration of a screen print.  Only works on the regular
+	// grid.
+	Checker    bool
+	CheckerOdd bool
+
+	// Workers is the number of goroutines used to compute the dots.
+	// Zero means one per CPU.
+	Workers int
+
+	// Progress, if set, is called each time a row of boxes has been
+	// computed, with the number of rows done so far and the total.
+	// Large grids are computed a band of columns at a time, and then
+	// the rows of every band count towards the total.
+	// The calls never overlap, but they may come from different
+	// goroutines.
+	Progress func(done int, total int)
+
+	// Format is the output format.  One of FormatSVG, FormatPNG,
+	// FormatASCII, FormatPDF, FormatSixel or FormatJSON.  Empty
+	// means FormatSVG.
+	Format string
+
+	// Supersample is the number of samples taken along each axis of
+	// every pixel of FormatPNG and FormatSixel to find how much of
+	// it a dot covers, which smooths the edges like drawing at that
+	// many times the size and averaging it down would.  One gives
+	// hard edges.  Zero means 4.
+	Supersample int
+
+	// Ramp is the characters used for FormatASCII, from the lightest
+	// to the darkest.  Empty means DefaultRamp.
+	Ramp string
+
+	// CharAspect is the height of the character cells of the
+	// terminal divided by their width.  For FormatASCII the boxes
+	// are made this much taller than they are wide so the picture
+	// isn't stretched.  Zero or one means square boxes.
+	CharAspect float64
+
+	// Responsive leaves out the width and height of the SVG and
+	// gives it just a viewBox, so it scales to fit the page it is
+	// embedded in.
+	Responsive bool
+
+	// Minify leaves out the newlines and indentation between the
+	// elements of the SVG, which makes it smaller to send over the
+	// web.
+	Minify bool
+
+	// DPI is the resolution the SVG is meant to be printed at.  If
+	// set, the width and height of the SVG are given in Unit, so one
+	// pixel of the output is 1/DPI inch on paper, and the drawing
+	// itself gets a viewBox in pixels.  Zero means the width and
+	// height are given in pixels.
+	DPI float64
+
+	// Unit is the unit of the width and height of the SVG when DPI is
+	// set.  One of UnitMillimeter or UnitInch.  Empty means
+	// UnitMillimeter.
+	Unit string
+
+	// Title and Description are put in the title and desc elements
+	// of the SVG, which screen readers use.  Empty means none.
+	Title       string
+	Description string
+
+	// Comment is put in an XML comment at the top of the SVG, for
+	// instance to tell where it came from.  Empty means none.
+	Comment string
+
+	// Date is the creation date written into the PDF.  Zero means
+	// the start of the Unix epoch, so that, like the other formats,
+	// the PDF doesn't change from one run to the next.
+	Date time.Time
+
+	// Background is the color the output is filled with before the
+	// dots are drawn.  If nil the background is transparent.
+	Background color.Color
+
+	// ColorMode decides how the color of a box is computed from its
+	// pixels.  One of ColorModeMean, ColorModeMedian,
+	// ColorModeDominant or ColorModeLumaWeighted.  Empty means
+	// ColorModeMean.
+	ColorMode string
+
+	// Gray fills each dot with the gray level of its box, the luma
+	// after BlackPoint and WhitePoint, rather than its color.  This
+	// gives a monochrome halftone that still has shades of gray,
+	// unlike leaving Color off, which makes every dot black.  Only
+	// has an effect when Color is set.
+	Gray bool
+
+	// HSL fills each dot with a color of Hue, in degrees, and
+	// Saturation, from 0.0 to 1.0, whose lightness is the luma of
+	// its box after BlackPoint and WhitePoint.  This tints the image
+	// in a single hue, with dark boxes getting dark dots and bright
+	// boxes light ones.  Only has an effect when Color is set.
+	HSL        bool
+	Hue        float64
+	Saturation float64
+
+	// ColorImage, if set, is where the colors of the dots come from,
+	// while their sizes still come from the image being rendered.
+	// Each dot gets the color of ColorImage at the same relative
+	// position as the middle of its box, so the two images need not
+	// be the same size.  It is sampled bilinearly.  Only has an
+	// effect when Color is set.
+	ColorImage image.Image
+
+	// Mask, if set, restricts where the dots go.  It is stretched
+	// over the image, and boxes where the mask is mostly white get
+	// dots as usual while those where it is mostly black, that is
+	// whose mean gray is below 0.5, get none.  Transparent parts of
+	// the mask count as black.  Nil means dots everywhere.
+	Mask image.Image
+
+	// Invert makes bright areas produce big dots and dark areas small
+	// ones.  The luma thresholds are inverted as well, so
+	// LumaThreshold removes dots darker than 1.0 - LumaThreshold.
+	Invert bool
+
+	// MinRadius and MaxRadius clamp the radius of the dots.  They are
+	// in output units, that is after scaling.  A MaxRadius of zero
+	// means no upper limit.
+	MinRadius float64
+	MaxRadius float64
+
+	// NoOverlap limits the radius of the dots to half a box, so each
+	// dot stays within its box even when MinRadius says otherwise.
+	NoOverlap bool
+
+	// Precision snaps the centers and radii of the dots to multiples
+	// of this many units, and drops the dots whose radius rounds to
+	// zero.  This makes the output smaller.  Zero means the radius is
+	// just truncated to a whole number and all dots are kept.
+	Precision int
+
+	// MaxDots makes rendering fail rather than make enormous output
+	// when the grid has more boxes than this, so a box size that is
+	// much too small for the image is caught before any dots are
+	// computed.  The screens of Rosette count one grid each.  Since
+	// Adaptive, Poisson and Spiral don't place their dots on the
+	// grid, how many dots they made is checked once they are placed
+	// as well.
+	// Zero means no limit.
+	MaxDots int
+
+	// MergeFlat merges horizontally adjacent dots whose colors differ
+	// by no more than this, from 0.0 to 1.0 of the range of each
+	// channel, into a single dot at their combined center that
+	// covers as much as they did together.  Flat areas such as a sky
+	// then take far fewer dots, which makes the output smaller and
+	// faster to draw.  Zero means every box gets its own dot.
+	MergeFlat float64
+
+	// Samples estimates the color of each box from this many pixels
+	// spread evenly over the box rather than from all of them.  This
+	// is a lot faster for big boxes and usually looks the same.  Zero
+	// means all the pixels are used.
+	Samples int
+
+	// CircularSample weighs the pixels of each box by how close they
+	// are to its middle when it is averaged, so the corners, which
+	// the dot doesn't cover, count for less.  The weight falls off
+	// like a Gaussian to about 0.14 in the middle of the sides of
+	// the box.  Only has an effect with the color modes that
+	// average, ColorModeMean and ColorModeLumaWeighted.
+	CircularSample bool
+
+	// AlphaCutoff drops the dots for boxes whose average alpha is
+	// below this value.  Valid values are from 0.0 to 1.0.  Boxes
+	// that are completely transparent never get a dot.
+	AlphaCutoff float64
+
+	// ColorSpace is the color space the colors are averaged in when
+	// using ColorModeMean, which affects both the fill color and
+	// the luma.  One of ColorSpaceSRGB, ColorSpaceLinear or
+	// ColorSpaceLab.  Empty means ColorSpaceSRGB, or
+	// ColorSpaceLinear if Linear is set.
+	ColorSpace string
+
+	// Linear averages colors in linear light rather than in sRGB
+	// when using ColorModeMean.  This keeps the average from getting
+	// too dark, which affects both the fill color and the luma.
+	//
+	// Deprecated: set ColorSpace to ColorSpaceLinear instead.
+	Linear bool
+
+	// Palette limits the colors of the dots to these colors.  Each
+	// dot gets the palette color nearest to the color of its box.
+	// The size of the dot still follows the color of the box.  Nil
+	// means any color.
+	Palette []color.RGBA
+
+	// PaletteLab finds the nearest palette color in CIELAB rather
+	// than in RGB, which better matches how alike colors look.
+	PaletteLab bool
+
+	// CMYK adds the CMYK color of each dot to the SVG, as a
+	// device-cmyk fill after the RGB one, for printing.  For CMYK
+	// images, such as CMYK JPEGs, it is the average of the ink
+	// values of the box, so they don't have to go through RGB.
+	// Viewers that don't understand device-cmyk, and the other
+	// formats, use the RGB color.
+	CMYK bool
+
+	// Adaptive replaces the fixed grid with one where boxes whose
+	// colors vary by more than Variance are recursively split into
+	// four, down to boxes of MinBox pixels.  BoxSize is then the
+	// largest box.
+	Adaptive bool
+
+	// Variance is how much the colors of a box may vary, from 0.0
+	// to 1.0, before Adaptive splits it.
+	Variance float64
+
+	// MinBox is the length in pixels of the side of the smallest box
+	// Adaptive will split down to.
+	MinBox int
+
+	// Stipple gives all dots the same size and uses error diffusion
+	// to decide which boxes get one, so dark areas get many dots and
+	// bright areas few.  Use a small BoxSize for this.
+	Stipple bool
+
+	// Poisson spreads the dots out with Poisson disk sampling rather
+	// than putting them on a grid, which looks more natural.  The
+	// dots are half a box apart in the darkest areas and further
+	// apart the brighter the image is.  The placement is random but
+	// comes from Seed.
+	Poisson bool
+
+	// Spiral lays the dots out along an Archimedean spiral from the
+	// middle of the image rather than on a grid, each made from a
+	// box around it as large as those of the grid.  The turns are
+	// SpiralSpacing pixels apart and the dots SpiralStep degrees
+	// apart along the spiral.  Zero spacing means the box width, and
+	// zero step means the dots are as far apart along the spiral as
+	// the turns are, so they are spread evenly.
+	Spiral        bool
+	SpiralSpacing float64
+	SpiralStep    float64
+
+	// Rosette draws the image like a print, with a grid of dots for
+	// each of the cyan, magenta, yellow and black inks.  The grids
+	// are as fine as the boxes but turned to the usual screen angles
+	// of 15, 75, 0 and 45 degrees, and the size of each dot is how
+	// much of its ink the image needs there.  The inks darken each
+	// other where they overlap.  Rosette needs Color.
+	Rosette bool
+
+	// Contour draws the outlines of the areas whose luma is below
+	// it, from 0.0 to 1.0, instead of dots, which gives a look like
+	// the contour lines of a map.  The outlines are traced with
+	// marching squares through the lumas of the boxes and are drawn
+	// as closed paths with lines as wide as LineWidth, so Contour
+	// only works with FormatSVG.  Zero means dots.
+	Contour float64
+
+	// Jitter moves each dot by a random offset, so the dots look
+	// less mechanical.  Valid values are from 0.0 to 1.0, where 1.0
+	// moves a dot by up to half a box in each direction.  Zero means
+	// no jitter.
+	Jitter float64
+
+	// Seed seeds the random numbers used for Jitter and Poisson.
+	// The same seed gives the same output every time.
+	Seed int64
+
+	// The table Equalize maps the levels of luma through, which is
+	// made from the image when it is laid out.
+	equalization *[256]float64
+}
+
+// The ways Options.Edges can use the edges of the image.
+const (
+	// EdgesOnly sizes the dots by the edges alone.
+	EdgesOnly = "only"
+
+	// EdgesMultiply sizes the dots by their darkness times the
+	// edges, so only dark edges get big dots.
+	EdgesMultiply = "multiply"
+)
+
+// The output formats Render can write.
+const (
+	FormatSVG   = "svg"
+	FormatPNG   = "png"
+	FormatASCII = "ascii"
+	FormatPDF   = "pdf"
+	FormatSixel = "sixel"
+	FormatJSON  = "json"
+)
+
+// The units Options.Unit can give the size of the SVG in.
+const (
+	UnitMillimeter = "mm"
+	UnitInch       = "in"
+)
+
+// DefaultOptions returns the options the command line utility uses
+// when no flags are given.
+func DefaultOptions() Options {
+	return Options{
+		BoxSize:       50,
+		Scale:         1,
+		LumaThreshold: 1.0,
+		WhitePoint:    1.0,
+		DotGamma:      1.0,
+		AreaScale:     defaultAreaScale,
+		Color:         true,
+		Luma:          LumaBT601,
+		Shape:         ShapeCircle,
+		StarRatio:     0.5,
+		LineWidth:     2,
+		StrokeWidth:   1,
+		Format:        FormatSVG,
+		Ramp:          DefaultRamp,
+		CharAspect:    2.0,
+		ColorMode:     ColorModeMean,
+		Variance:      0.005,
+		MinBox:        4,
+		MaxDots:       1000000,
+		Saturation:    1.0,
+	}
+}
+
+// Validate checks that the options make sense before we start
+// rendering.
+func (o Options) Validate() error {
+	if o.BoxSize < 1 {
+		return errors.New("box size must be at least 1")
+	}
+
+	if o.Density < 0 {
+		return errors.New("density cannot be negative")
+	}
+
+	if o.Density > 0 && (o.Adaptive || o.Poisson) {
+		return errors.New("density cannot be combined with adaptive boxes or poisson")
+	}
+
+	if o.BoxWidth < 0 || o.BoxHeight < 0 {
+		return errors.New("box width and height cannot be negative")
+	}
+
+	if o.Scale <= 0 {
+		return errors.New("scale must be larger than 0")
+	}
+
+	if o.CanvasScale < 0 {
+		return errors.New("canvas scale cannot be negative")
+	}
+
+	if o.AlphaCutoff < 0.0 || o.AlphaCutoff > 1.0 {
+		return errors.New("invalid alpha cutoff, must be between 0.0 and 1.0")
+	}
+
+	if o.MaxDim < 0 {
+		return errors.New("max dimension cannot be negative")
+	}
+
+	if !validFilter(o.Filter) {
+		return fmt.Errorf("unknown filter %q", o.Filter)
+	}
+
+	if o.LumaThreshold < 0.0 || o.LumaThreshold > 1.0 {
+		return errors.New("invalid luma threshold, must be between 0.0 and 1.0")
+	}
+
+	if o.LumaThresholdLow < 0.0 || o.LumaThresholdLow > o.LumaThreshold {
+		return errors.New("invalid low luma threshold, must be between 0.0 and the luma threshold")
+	}
+
+	if o.Coverage < 0.0 || o.Coverage > 1.0 {
+		return errors.New("invalid coverage, must be between 0.0 and 1.0")
+	}
+
+	if o.BlackPoint < 0.0 || o.BlackPoint > 1.0 || o.WhitePoint < 0.0 || o.WhitePoint > 1.0 {
+		return errors.New("invalid black or white point, must be between 0.0 and 1.0")
+	}
+
+	if o.WhitePoint != 0 && o.WhitePoint <= o.BlackPoint {
+		return errors.New("white point must be larger than the black point")
+	}
+
+	if o.AreaScale < 0 {
+		return errors.New("area scale cannot be negative")
+	}
+
+	if o.DotGamma < 0 {
+		return errors.New("dot gamma cannot be negative")
+	}
+
+	if o.Opacity < 0 {
+		return errors.New("opacity cannot be negative")
+	}
+
+	if o.Vignette < 0.0 || o.Vignette > 1.0 {
+		return errors.New("invalid vignette, must be between 0.0 and 1.0")
+	}
+
+	if w := o.LumaWeights; w != [3]float64{} {
+		if w[0] < 0 || w[1] < 0 || w[2] < 0 {
+			return errors.New("luma weights cannot be negative")
+		}
+	}
+
+	if !validMetric(o.Metric) {
+		return fmt.Errorf("unknown metric %q", o.Metric)
+	}
+
+	if o.Metric != "" && o.Metric != MetricLuma && ((o.Channel != "" && o.Channel != ChannelLuma) || o.LumaWeights != [3]float64{}) {
+		return errors.New("metric cannot be combined with a channel or luma weights")
+	}
+
+	if !validChannel(o.Channel) {
+		return fmt.Errorf("unknown channel %q", o.Channel)
+	}
+
+	if o.Channel != "" && o.Channel != ChannelLuma && o.LumaWeights != [3]float64{} {
+		return errors.New("channel and luma weights cannot be combined")
+	}
+
+	if o.MinRadius < 0 || o.MaxRadius < 0 {
+		return errors.New("radius limits cannot be negative")
+	}
+
+	if o.MaxRadius > 0 && o.MinRadius > o.MaxRadius {
+		return errors.New("minimum radius cannot be larger than the maximum radius")
+	}
+
+	if o.Samples < 0 {
+		return errors.New("number of samples cannot be negative")
+	}
+
+	if o.Precision < 0 {
+		return errors.New("precision cannot be negative")
+	}
+
+	if o.MaxDots < 0 {
+		return errors.New("max dots cannot be negative")
+	}
+
+	if o.Checker && (o.Adaptive || o.Stipple || o.Poisson || o.Rosette || o.Contour > 0) {
+		return errors.New("checker cannot be combined with adaptive boxes, stippling, poisson, rosette or contour")
+	}
+
+	if o.MergeFlat < 0.0 || o.MergeFlat > 1.0 {
+		return errors.New("invalid merge tolerance, must be between 0.0 and 1.0")
+	}
+
+	if o.MergeFlat > 0 {
+		if o.Adaptive || o.Stipple || o.Poisson || o.Rosette || o.Contour > 0 {
+			return errors.New("merging flat dots cannot be combined with adaptive boxes, stippling, poisson, rosette or contour")
+		}
+
+		if o.Format == FormatASCII {
+			return errors.New("merged dots cannot be written as ascii")
+		}
+	}
+
+	if o.CharAspect < 0 {
+		return errors.New("character aspect cannot be negative")
+	}
+
+	if !validShape(o.Shape) {
+		return fmt.Errorf("unknown shape %q", o.Shape)
+	}
+
+	if !validLuma(o.Luma) {
+		return fmt.Errorf("unknown luma standard %q", o.Luma)
+	}
+
+	if !validColorSpace(o.ColorSpace) {
+		return fmt.Errorf("unknown color space %q", o.ColorSpace)
+	}
+
+	if o.Linear && o.ColorSpace != "" && o.ColorSpace != ColorSpaceLinear {
+		return errors.New("linear cannot be combined with another color space")
+	}
+
+	switch o.Edges {
+	case "", EdgesOnly, EdgesMultiply:
+	default:
+		return fmt.Errorf("unknown edge mode %q", o.Edges)
+	}
+
+	if !validColorMode(o.ColorMode) {
+		return fmt.Errorf("unknown color mode %q", o.ColorMode)
+	}
+
+	if o.Adaptive {
+		if o.MinBox < 1 {
+			return errors.New("minimum box size must be at least 1")
+		}
+
+		if o.Variance < 0 {
+			return errors.New("variance cannot be negative")
+		}
+
+		if o.Format == FormatASCII {
+			return errors.New("adaptive boxes cannot be written as ascii")
+		}
+	}
+
+	if o.Jitter < 0.0 || o.Jitter > 1.0 {
+		return errors.New("invalid jitter, must be between 0.0 and 1.0")
+	}
+
+	if o.Adaptive && o.Stipple {
+		return errors.New("adaptive boxes and stippling cannot be combined")
+	}
+
+	if o.Poisson {
+		if o.Adaptive || o.Stipple || o.Hex {
+			return errors.New("poisson cannot be combined with adaptive boxes, stippling or a hexagonal grid")
+		}
+
+		if o.Format == FormatASCII {
+			return errors.New("poisson dots cannot be written as ascii")
+		}
+	}
+
+	if o.Spiral {
+		if o.Adaptive || o.Stipple || o.Poisson || o.Rosette || o.Contour > 0 {
+			return errors.New("spiral cannot be combined with adaptive boxes, stippling, poisson, rosette or contour")
+		}
+
+		if o.Hex || o.Density > 0 || o.Checker || o.MergeFlat > 0 || o.Tileable {
+			return errors.New("spiral cannot be combined with a hexagonal grid, density, checker, merging flat dots or tiling")
+		}
+
+		if o.Format == FormatASCII {
+			return errors.New("spiral dots cannot be written as ascii")
+		}
+
+		if o.SpiralSpacing < 0 || o.SpiralStep < 0 {
+			return errors.New("spiral spacing and step cannot be negative")
+		}
+	}
+
+	if o.Gray && o.ColorImage != nil {
+		return errors.New("gray and a color image cannot be combined")
+	}
+
+	if o.HSL {
+		if o.Gray || o.ColorImage != nil {
+			return errors.New("hsl cannot be combined with gray or a color image")
+		}
+
+		if o.Saturation < 0.0 || o.Saturation > 1.0 {
+			return errors.New("invalid saturation, must be between 0.0 and 1.0")
+		}
+	}
+
+	if o.Rosette {
+		if o.Adaptive || o.Stipple || o.Poisson || o.Hex {
+			return errors.New("rosette cannot be combined with adaptive boxes, stippling, poisson or a hexagonal grid")
+		}
+
+		if o.Format == FormatASCII {
+			return errors.New("rosette cannot be written as ascii")
+		}
+
+		if !o.Color {
+			return errors.New("rosette needs color")
+		}
+	}
+
+	if o.Contour < 0.0 || o.Contour > 1.0 {
+		return errors.New("invalid contour level, must be between 0.0 and 1.0")
+	}
+
+	if o.Contour > 0 {
+		if o.Adaptive || o.Stipple || o.Poisson || o.Rosette || o.Hex || o.Tileable {
+			return errors.New("contour cannot be combined with adaptive boxes, stippling, poisson, rosette, a hexagonal grid or tiling")
+		}
+
+		if o.Format != "" && o.Format != FormatSVG {
+			return errors.New("contour can only be written as svg")
+		}
+
+		if o.LineWidth <= 0 {
+			return errors.New("line width must be larger than 0")
+		}
+	}
+
+	if o.Shape == ShapeStar && (o.StarRatio <= 0.0 || o.StarRatio > 1.0) {
+		return errors.New("star ratio must be larger than 0.0 and at most 1.0")
+	}
+
+	if (o.Shape == ShapeLine || o.Concentric > 0) && o.LineWidth <= 0 {
+		return errors.New("line width must be larger than 0")
+	}
+
+	if o.Stroke != nil {
+		if o.Shape == ShapeLine || o.Concentric > 0 {
+			return errors.New("a stroke cannot be combined with lines or concentric rings")
+		}
+
+		if o.StrokeWidth <= 0 {
+			return errors.New("stroke width must be larger than 0")
+		}
+	}
+
+	if o.Soft {
+		if o.Shape == ShapeLine || o.Concentric > 0 {
+			return errors.New("soft dots cannot be combined with lines or concentric rings")
+		}
+
+		if o.Format != "" && o.Format != FormatSVG {
+			return errors.New("soft dots can only be written as svg")
+		}
+	}
+
+	if o.CSS {
+		if o.Soft || o.Contour > 0 {
+			return errors.New("css classes cannot be combined with soft dots or contours")
+		}
+
+		if o.Format != "" && o.Format != FormatSVG {
+			return errors.New("css classes can only be written as svg")
+		}
+	}
+
+	if o.Concentric < 0 {
+		return errors.New("number of concentric rings cannot be negative")
+	}
+
+	if o.Concentric > 0 && o.Shape != "" && o.Shape != ShapeCircle {
+		return errors.New("concentric rings can only be drawn for circles")
+	}
+
+	switch o.Format {
+	case "", FormatSVG, FormatPNG, FormatASCII, FormatPDF, FormatSixel, FormatJSON:
+	default:
+		return fmt.Errorf("unknown format %q", o.Format)
+	}
+
+	if o.Supersample < 0 {
+		return errors.New("supersampling cannot be negative")
+	}
+
+	if o.DPI < 0 {
+		return errors.New("dpi cannot be negative")
+	}
+
+	if o.DPI > 0 && o.Responsive {
+		return errors.New("responsive and dpi cannot be combined")
+	}
+
+	switch o.Unit {
+	case "", UnitMillimeter, UnitInch:
+	default:
+		return fmt.Errorf("unknown unit %q", o.Unit)
+	}
+
+	return nil
+}
+
+// Render writes an image to w made of dots whose diameter is
+// proportional to the luminance and whose color is the average color
+// of the area in the image they represent.  The image is written as
+// SVG unless opts.Format says otherwise.
+func Render(img image.Image, opts Options, w io.Writer) error {
+	img, r, err := prepare(img, opts)
+	if err != nil {
+		return err
+	}
+
+	if r.streamable() {
+		return r.stream(img, w)
+	}
+
+	r, err = r.compute(img)
+	if err != nil {
+		return err
+	}
+	return r.write(w)
+}
+
+// RenderBytes is like Render but returns the output rather than
+// writing it, for callers that want it in memory anyway, such as web
+// handlers that need its length.
+func RenderBytes(img image.Image, opts Options) ([]byte, error) {
+	var buf bytes.Buffer
+	if err := Render(img, opts, &buf); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// rendering is the dots computed for an image along with what is
+// needed to write them out.  boxDots are the dots before tileable
+// output added the copies that wrap around the edges, one for each
+// box, which is what Analyze counts.
+type rendering struct {
+	dots     []dot
+	boxDots  []dot
+	contours [][]contourPoint
+	grid     grid
+	width    int
+	height   int
+	opts     Options
+}
+
+// layout computes the dots for the image.
+func layout(img image.Image, opts Options) (rendering, error) {
+	img, r, err := prepare(img, opts)
+	if err != nil {
+		return rendering{}, err
+	}
+	return r.compute(img)
+}
+
+// prepare checks the options and works out the grid and the size of
+// the output.  It returns the image the dots are to be computed from
+// along with a rendering that has everything but the dots.
+func prepare(img image.Image, opts Options) (image.Image, rendering, error) {
+	if err := opts.Validate(); err != nil {
+		return nil, rendering{}, err
+	}
+
+	if opts.Crop != (image.Rectangle{}) {
+		cropped, err := crop(img, opts.Crop)
+		if err != nil {
+			return nil, rendering{}, err
+		}
+		img = cropped
+	}
+
+	// The output has the same width and height as the pixels of the
+	// original picture, times the scale, just to make coordinates
+	// match up.
+	width := int(math.Round(float64(img.Bounds().Dx()) * canvasScale(opts)))
+	height := int(math.Round(float64(img.Bounds().Dy()) * canvasScale(opts)))
+
+	// If the image is scaled down the dots have to be scaled up by
+	// as much to fill the same output.
+	if small := resize(img, opts.MaxDim, opts.Filter); small != img {
+		factor := float64(img.Bounds().Dx()) / float64(small.Bounds().Dx())
+		opts.Scale *= factor
+		opts.CanvasScale *= factor
+		img = small
+	}
+
+	g := newGrid(img.Bounds(), opts)
+
+	boxes := g.cols * g.rows
+	if opts.Rosette {
+		boxes *= len(rosetteScreens)
+	}
+	if err := checkMaxDots(boxes, opts); err != nil {
+		return nil, rendering{}, err
+	}
+
+	if opts.Tileable {
+		img = wrappedImage{img}
+	}
+
+	if opts.Equalize {
+		table := equalization(lumaHistogram(img, baseLuma(opts)))
+		opts.equalization = &table
+	}
+
+	if opts.AutoThreshold {
+		opts.LumaThreshold = autoThreshold(img, g, opts)
+	}
+
+	return img, rendering{grid: g, width: width, height: height, opts: opts}, nil
+}
+
+// compute computes the dots, or the contours, for the image returned
+// by prepare.
+func (r rendering) compute(img image.Image) (rendering, error) {
+	g, opts := r.grid, r.opts
+
+	if opts.Contour > 0 {
+		r.contours = computeContours(img, g, opts)
+		return r, nil
+	}
+
+	var dots []dot
+	switch {
+	case opts.Adaptive:
+		dots = computeAdaptive(img, g, opts)
+	case opts.Stipple:
+		dots = computeStipple(img, g, opts)
+	case opts.Poisson:
+		dots = computePoisson(img, g, opts)
+	case opts.Spiral:
+		dots = computeSpiral(img, g, opts)
+	case opts.Rosette:
+		dots = computeRosette(img, g, opts)
+	default:
+		dots = computeDots(img, g, opts)
+		if opts.MergeFlat > 0 {
+			mergeFlat(dots, g, opts)
+		}
+	}
+
+	if opts.Adaptive || opts.Poisson || opts.Spiral {
+		if err := checkMaxDots(len(dots), opts); err != nil {
+			return rendering{}, err
+		}
+	}
+
+	if opts.Jitter > 0 {
+		jitterDots(dots, g, r.width, r.height, opts)
+	}
+
+	r.dots = dots
+	r.boxDots = dots
+
+	// The ascii output is placed by the grid rather than by where the
+	// dots are, so there is nothing to wrap.
+	if opts.Tileable && opts.Format != FormatASCII {
+		r.dots = wrapDots(dots, r.width, r.height, opts)
+	}
+
+	return r, nil
+}
+
+// checkMaxDots returns an error if n dots are more than
+// opts.MaxDots allows.
+func checkMaxDots(n int, opts Options) error {
+	if opts.MaxDots > 0 && n > opts.MaxDots {
+		return fmt.Errorf("%d dots is more than the maximum of %d, use a larger box size", n, opts.MaxDots)
+	}
+	return nil
+}
+
+// canvasScale returns the factor the canvas and the positions of the
+// dots are scaled by.
+func canvasScale(opts Options) float64 {
+	if opts.CanvasScale > 0 {
+		return opts.CanvasScale
+	}
+	return opts.Scale
+}
+
+// write writes the dots to w in the format given by the options.
+func (r rendering) write(w io.Writer) error {
+	if r.opts.Contour > 0 {
+		return writeContours(r.contours, r.width, r.height, r.opts, w)
+	}
+
+	switch r.opts.Format {
+	case FormatASCII:
+		return writeASCII(r.dots, r.grid, r.opts, w)
+	case FormatPNG:
+		return writePNG(r.dots, r.width, r.height, r.opts, w)
+	case FormatPDF:
+		return writePDF(r.dots, r.width, r.height, r.opts, w)
+	case FormatSixel:
+		return writeSixel(r.dots, r.width, r.height, r.opts, w)
+	case FormatJSON:
+		return writeJSON(r.dots, r.opts, w)
+	default:
+		return writeSVG(r.dots, r.width, r.height, r.opts, w)
+	}
+}
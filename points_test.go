@@ -0,0 +1,220 @@
+// Copyright Bjørn Borud 2019 Use of this source code is governed by
+// the license found in the accompanying LICENSE file.
+
+package points
+
+import (
+	"bytes"
+	"errors"
+	"flag"
+	"image"
+	"image/color"
+	"image/draw"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+var update = flag.Bool("update", false, "rewrite the golden files in testdata")
+
+// failingWriter fails every write.
+type failingWriter struct{}
+
+func (failingWriter) Write(p []byte) (int, error) {
+	return 0, errors.New("disk full")
+}
+
+func TestRender(t *testing.T) {
+	img := image.NewRGBA(image.Rect(0, 0, 40, 20))
+	draw.Draw(img, image.Rect(0, 0, 20, 20), image.NewUniform(color.Black), image.Point{}, draw.Src)
+	draw.Draw(img, image.Rect(20, 0, 40, 20), image.NewUniform(color.White), image.Point{}, draw.Src)
+
+	opts := DefaultOptions()
+	opts.BoxSize = 20
+
+	var buf bytes.Buffer
+	if err := Render(img, opts, &buf); err != nil {
+		t.Fatalf("Render: %v", err)
+	}
+
+	out := buf.String()
+	if !strings.Contains(out, `<svg width="40" height="20"`) {
+		t.Errorf("expected a 40x20 svg, got %s", out)
+	}
+
+	// The white box is at the luma threshold and gets no dot
+	if n := strings.Count(out, "<circle"); n != 1 {
+		t.Errorf("expected 1 circle, got %d in %s", n, out)
+	}
+
+	if !strings.Contains(out, `<circle cx="10" cy="10" r="10"`) {
+		t.Errorf("expected a full size dot for the black box, got %s", out)
+	}
+}
+
+func TestRenderErrors(t *testing.T) {
+	img := image.NewRGBA(image.Rect(0, 0, 40, 20))
+
+	tests := []struct {
+		name string
+		opts func(o *Options)
+	}{
+		{"box size", func(o *Options) { o.BoxSize = 0 }},
+		{"scale", func(o *Options) { o.Scale = 0 }},
+		{"threshold", func(o *Options) { o.LumaThreshold = 2 }},
+		{"shape", func(o *Options) { o.Shape = "blob" }},
+		{"format", func(o *Options) { o.Format = "gif" }},
+	}
+
+	for _, test := range tests {
+		opts := DefaultOptions()
+		test.opts(&opts)
+
+		var buf bytes.Buffer
+		if err := Render(img, opts, &buf); err == nil {
+			t.Errorf("%s: expected an error", test.name)
+		}
+
+		// Nothing should be written before the options are checked
+		if buf.Len() > 0 {
+			t.Errorf("%s: expected no output, got %q", test.name, buf.String())
+		}
+	}
+}
+
+func TestRenderWriteError(t *testing.T) {
+	img := image.NewRGBA(image.Rect(0, 0, 40, 20))
+
+	for _, format := range []string{FormatSVG, FormatPNG, FormatASCII, FormatPDF, FormatSixel, FormatJSON} {
+		opts := DefaultOptions()
+		opts.BoxSize = 10
+		opts.Format = format
+
+		if err := Render(img, opts, failingWriter{}); err == nil {
+			t.Errorf("%s: expected the write error to be returned", format)
+		}
+	}
+}
+
+func TestRenderBytes(t *testing.T) {
+	// Black, white, gray and dark red pixels, with a box for each
+	img := image.NewRGBA(image.Rect(0, 0, 2, 2))
+	img.Set(0, 0, color.Black)
+	img.Set(1, 0, color.White)
+	img.Set(0, 1, color.RGBA{0x80, 0x80, 0x80, 0xff})
+	img.Set(1, 1, color.RGBA{0x80, 0x00, 0x00, 0xff})
+
+	opts := DefaultOptions()
+	opts.BoxSize = 1
+	opts.Scale = 10
+
+	out, err := RenderBytes(img, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var buf bytes.Buffer
+	if err := Render(img, opts, &buf); err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(out, buf.Bytes()) {
+		t.Errorf("expected the same output as Render, got %s and %s", out, buf.Bytes())
+	}
+
+	s := string(out)
+	if !strings.HasPrefix(s, "<?xml") || !strings.Contains(s, `<svg width="20" height="20"`) {
+		t.Errorf("expected a 20x20 svg, got %s", s)
+	}
+	if n := strings.Count(s, "<circle"); n != 3 {
+		t.Errorf("expected 3 circles, got %d in %s", n, s)
+	}
+
+	opts.BoxSize = 0
+	if out, err := RenderBytes(img, opts); err == nil || out != nil {
+		t.Errorf("expected an error and no output, got %v and %q", err, out)
+	}
+}
+
+// checkGolden compares out with the named golden file in testdata, or
+// rewrites the file with -update.
+func checkGolden(t *testing.T, name string, out []byte) {
+	fn := filepath.Join("testdata", name)
+
+	if *update {
+		if err := ioutil.WriteFile(fn, out, 0644); err != nil {
+			t.Fatal(err)
+		}
+		return
+	}
+
+	want, err := ioutil.ReadFile(fn)
+	if err != nil {
+		t.Fatalf("%v, run the tests with -update to create it", err)
+	}
+
+	if !bytes.Equal(out, want) {
+		t.Errorf("output differs from %s, run the tests with -update if that is intended\ngot:\n%s\nexpected:\n%s", fn, out, want)
+	}
+}
+
+func TestGolden(t *testing.T) {
+	// A checkerboard of black and dark red squares, with a box for
+	// each square.  The dots come out a column at a time.
+	img := image.NewRGBA(image.Rect(0, 0, 80, 60))
+	draw.Draw(img, img.Bounds(), image.NewUniform(color.Black), image.Point{}, draw.Src)
+	for x := 0; x < 4; x++ {
+		for y := 0; y < 3; y++ {
+			if (x+y)%2 == 1 {
+				draw.Draw(img, image.Rect(x*20, y*20, x*20+20, y*20+20), image.NewUniform(color.RGBA{0xc0, 0x20, 0x20, 0xff}), image.Point{}, draw.Src)
+			}
+		}
+	}
+
+	opts := DefaultOptions()
+	opts.BoxSize = 20
+
+	out, err := RenderBytes(img, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkGolden(t, "checkerboard.golden.svg", out)
+}
+
+func TestMaxDots(t *testing.T) {
+	img := image.NewGray(image.Rect(0, 0, 100, 100))
+
+	tests := []struct {
+		name    string
+		boxSize int
+		maxDots int
+		fail    bool
+	}{
+		{"too fine", 1, 1000, true},
+		{"at the limit", 10, 100, false},
+		{"one too many", 10, 99, true},
+		{"no limit", 1, 0, false},
+	}
+
+	for _, test := range tests {
+		opts := DefaultOptions()
+		opts.BoxSize = test.boxSize
+		opts.MaxDots = test.maxDots
+
+		var buf bytes.Buffer
+		err := Render(img, opts, &buf)
+		if !test.fail {
+			if err != nil {
+				t.Errorf("%s: expected no error, got %v", test.name, err)
+			}
+			continue
+		}
+
+		if err == nil || !strings.Contains(err.Error(), "use a larger box size") {
+			t.Errorf("%s: expected the error to suggest a larger box size, got %v", test.name, err)
+		}
+		if buf.Len() > 0 {
+			t.Errorf("%s: expected no output", test.name)
+		}
+	}
+}
@@ -0,0 +1,161 @@
+// Copyright Bjørn Borud 2019 Use of this source code is governed by
+// the license found in the accompanying LICENSE file.
+
+package points
+
+import (
+	"image"
+	"math"
+	"math/rand"
+)
+
+// poissonTries is how many candidates are tried around a point before
+// giving up on finding room for more dots next to it.
+const poissonTries = 30
+
+// poissonSpread is how much further apart the dots of the brightest
+// areas are than those of the darkest.
+const poissonSpread = 4.0
+
+// computePoisson computes dots whose centers are spread out with
+// Poisson disk sampling, using Bridson's algorithm, rather than lined
+// up on a grid.  No two dots are closer than the spacing around the
+// later one, which is half a box in the darkest areas and grows to
+// poissonSpread times as much in the brightest.  Each dot is then
+// made from the pixels around its center like any other dot.  The
+// random numbers come from opts.Seed and the dots are returned in the
+// order they were placed.
+func computePoisson(img image.Image, g grid, opts Options) []dot {
+	spacing := math.Max(1, float64(minInt(g.boxWidth, g.boxHeight))/2)
+	darkness := newDarknessMap(img, g, int(spacing), opts)
+
+	// spacingAt returns the smallest distance allowed between a dot
+	// at x, y and the others.
+	spacingAt := func(x float64, y float64) float64 {
+		return spacing / math.Max(darkness.at(x, y), 1/poissonSpread)
+	}
+
+	// Every dot is at least spacing away from the others, so with
+	// cells this small each holds at most one dot.
+	cellSize := spacing / math.Sqrt2
+	cols := int(math.Ceil(float64(g.width) / cellSize))
+	rows := int(math.Ceil(float64(g.height) / cellSize))
+
+	cells := make([]int, cols*rows)
+	for i := range cells {
+		cells[i] = -1
+	}
+
+	var xs, ys []float64
+	var active []int
+
+	add := func(x float64, y float64) {
+		cells[int(y/cellSize)*cols+int(x/cellSize)] = len(xs)
+		active = append(active, len(xs))
+		xs = append(xs, x)
+		ys = append(ys, y)
+	}
+
+	// fits returns true if there is no dot within r of x, y.
+	fits := func(x float64, y float64, r float64) bool {
+		cx, cy := int(x/cellSize), int(y/cellSize)
+		n := int(math.Ceil(r / cellSize))
+
+		for j := maxInt(cy-n, 0); j <= minInt(cy+n, rows-1); j++ {
+			for i := maxInt(cx-n, 0); i <= minInt(cx+n, cols-1); i++ {
+				k := cells[j*cols+i]
+				if k >= 0 && math.Hypot(xs[k]-x, ys[k]-y) < r {
+					return false
+				}
+			}
+		}
+		return true
+	}
+
+	rnd := rand.New(rand.NewSource(opts.Seed))
+	add(rnd.Float64()*float64(g.width), rnd.Float64()*float64(g.height))
+
+	for len(active) > 0 {
+		i := rnd.Intn(len(active))
+		k := active[i]
+		r := spacingAt(xs[k], ys[k])
+
+		found := false
+		for try := 0; try < poissonTries; try++ {
+			// Candidates are picked between one and two spacings
+			// away from the point.
+			angle := rnd.Float64() * 2 * math.Pi
+			dist := r * (1 + rnd.Float64())
+			x := xs[k] + dist*math.Cos(angle)
+			y := ys[k] + dist*math.Sin(angle)
+
+			if x < 0 || y < 0 || x >= float64(g.width) || y >= float64(g.height) {
+				continue
+			}
+
+			if fits(x, y, spacingAt(x, y)) {
+				add(x, y)
+				found = true
+				break
+			}
+		}
+
+		if !found {
+			active[i] = active[len(active)-1]
+			active = active[:len(active)-1]
+		}
+	}
+
+	// The darkest dots just touch at the smallest spacing
+	half := maxInt(1, int(spacing/2))
+	bounds := image.Rect(0, 0, g.width, g.height)
+	luma := chooseLuma(opts)
+
+	dots := make([]dot, len(xs))
+	for i := range xs {
+		x, y := int(xs[i]), int(ys[i])
+		box := image.Rect(x-half, y-half, x+half, y+half).Intersect(bounds)
+		dots[i] = makeDot(img, box, half, opts, luma)
+	}
+	return dots
+}
+
+// darknessMap holds the darkness, from 0.0 to 1.0, of the image in
+// square cells.  Boxes the luma threshold removes, or that are
+// transparent, count as having no darkness.
+type darknessMap struct {
+	cellSize int
+	cols     int
+	rows     int
+	darkness []float64
+}
+
+// newDarknessMap computes the darkness of the image in cells of
+// cellSize pixels.  The cells are divided between opts.Workers like
+// the boxes of computeDots.
+func newDarknessMap(img image.Image, g grid, cellSize int, opts Options) darknessMap {
+	m := darknessMap{
+		cellSize: cellSize,
+		cols:     (g.width + cellSize - 1) / cellSize,
+		rows:     (g.height + cellSize - 1) / cellSize,
+	}
+	m.darkness = make([]float64, m.cols*m.rows)
+	bounds := image.Rect(0, 0, g.width, g.height)
+	luma := chooseLuma(opts)
+
+	forEachRow(m.rows, opts, func(y int) {
+		for x := 0; x < m.cols; x++ {
+			box := image.Rect(x*cellSize, y*cellSize, (x+1)*cellSize, (y+1)*cellSize).Intersect(bounds)
+			m.darkness[y*m.cols+x] = makeStippleCell(img, box, opts, luma).darkness
+		}
+	})
+
+	return m
+}
+
+// at returns the darkness of the cell x, y falls in.
+func (m darknessMap) at(x float64, y float64) float64 {
+	cx := clampInt(int(x)/m.cellSize, 0, m.cols-1)
+	cy := clampInt(int(y)/m.cellSize, 0, m.rows-1)
+	return m.darkness[cy*m.cols+cx]
+}
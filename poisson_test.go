@@ -0,0 +1,84 @@
+// Copyright Bjørn Borud 2019 Use of this source code is governed by
+// the license found in the accompanying LICENSE file.
+
+package points
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"image/draw"
+	"math"
+	"testing"
+)
+
+func TestPoissonDistance(t *testing.T) {
+	// Black on the left and white on the right, where the dots
+	// should be poissonSpread times further apart
+	img := image.NewGray(image.Rect(0, 0, 400, 200))
+	draw.Draw(img, image.Rect(200, 0, 400, 200), image.NewUniform(color.White), image.Point{}, draw.Src)
+
+	opts := DefaultOptions()
+	opts.BoxSize = 20
+	opts.Poisson = true
+	opts.Seed = 7
+
+	r, err := layout(img, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The dots along the edges are made from boxes cut short by the
+	// edge, which moves their centers, and the centers are rounded
+	// down to whole pixels.
+	const spacing = 10.0
+	const slack = math.Sqrt2
+	var inner []dot
+	for _, d := range r.dots {
+		if d.cx >= 5 && d.cy >= 5 && d.cx < 395 && d.cy < 195 && (d.cx < 195 || d.cx >= 205) {
+			inner = append(inner, d)
+		}
+	}
+
+	if len(inner) < 100 {
+		t.Fatalf("expected a good number of dots, got %d", len(inner))
+	}
+
+	left := 0
+	for i, a := range inner {
+		if a.cx < 200 {
+			left++
+		}
+
+		for _, b := range inner[i+1:] {
+			dist := math.Hypot(float64(a.cx-b.cx), float64(a.cy-b.cy))
+
+			min := spacing
+			if a.cx >= 200 && b.cx >= 200 {
+				min = spacing * poissonSpread
+			}
+
+			if dist < min-slack {
+				t.Errorf("expected the dots at %d,%d and %d,%d to be at least %g apart, got %.2f", a.cx, a.cy, b.cx, b.cy, min, dist)
+			}
+		}
+	}
+
+	// The dark half is far more crowded
+	if right := len(inner) - left; left < 4*right {
+		t.Errorf("expected many more dots on the dark half, got %d and %d", left, right)
+	}
+
+	// The same seed places the dots the same way
+	a, err := RenderBytes(img, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, err := RenderBytes(img, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(a, b) {
+		t.Errorf("expected the same seed to give identical output")
+	}
+}
@@ -0,0 +1,236 @@
+// Copyright Bjørn Borud 2019 Use of this source code is governed by
+// the license found in the accompanying LICENSE file.
+
+package points
+
+import (
+	"image"
+	"image/color"
+	"image/draw"
+	"image/png"
+	"io"
+	"math"
+)
+
+// rasterSamples is the number of samples taken along each axis of a
+// pixel to decide how much of the pixel a dot covers, unless
+// Options.Supersample gives another.
+const rasterSamples = 4
+
+// writePNG draws the dots into a bitmap and writes it to w as PNG.
+func writePNG(dots []dot, width int, height int, opts Options, w io.Writer) error {
+	return drawDots(&pngCanvas{w: w, opts: opts, encode: png.Encode}, dots, width, height, opts)
+}
+
+// pngCanvas draws anti-aliased shapes into a bitmap that is encoded
+// with encode, as PNG or sixel, when the drawing ends.
+type pngCanvas struct {
+	img    *image.RGBA
+	w      io.Writer
+	opts   Options
+	encode func(w io.Writer, img image.Image) error
+
+	// The current rotation and its center
+	angle float64
+	rx    float64
+	ry    float64
+
+	// Set once the first layer has started
+	multiply bool
+}
+
+func (p *pngCanvas) start(width int, height int, background color.Color) {
+	p.img = image.NewRGBA(image.Rect(0, 0, width, height))
+
+	if background != nil {
+		draw.Draw(p.img, p.img.Bounds(), image.NewUniform(background), image.Point{}, draw.Src)
+	}
+}
+
+func (p *pngCanvas) circle(cx float64, cy float64, r float64, c color.Color) {
+	p.shape(cx, cy, r, c, func(x float64, y float64, grow float64) bool {
+		return r+grow > 0 && x*x+y*y <= (r+grow)*(r+grow)
+	})
+}
+
+func (p *pngCanvas) rect(x float64, y float64, w float64, h float64, c color.Color) {
+	hw, hh := w/2, h/2
+	p.shape(x+hw, y+hh, math.Max(hw, hh), c, func(x float64, y float64, grow float64) bool {
+		return x >= -hw-grow && x <= hw+grow && y >= -hh-grow && y <= hh+grow
+	})
+}
+
+func (p *pngCanvas) polygon(cx float64, cy float64, xs []float64, ys []float64, c color.Color) {
+	var extent float64
+	for i := range xs {
+		extent = math.Max(extent, math.Max(math.Abs(xs[i]), math.Abs(ys[i])))
+	}
+
+	p.shape(cx, cy, extent, c, func(x float64, y float64, grow float64) bool {
+		// Growing the polygon is the same as shrinking the point
+		// toward its center.
+		if extent+grow <= 0 {
+			return false
+		}
+		f := extent / (extent + grow)
+		return insidePolygon(x*f, y*f, xs, ys)
+	})
+}
+
+func (p *pngCanvas) line(x1 float64, y1 float64, x2 float64, y2 float64, c color.Color) {
+	dx, dy := x2-x1, y2-y1
+	length := math.Hypot(dx, dy)
+	halfLength, halfWidth := length/2, p.opts.LineWidth/2
+
+	// The line is a rectangle along the direction from the first
+	// point to the second.
+	p.fill((x1+x2)/2, (y1+y2)/2, math.Hypot(halfLength, halfWidth), rgbaOf(c), func(x float64, y float64) bool {
+		along := (x*dx + y*dy) / length
+		across := (y*dx - x*dy) / length
+		return along >= -halfLength && along <= halfLength && across >= -halfWidth && across <= halfWidth
+	})
+}
+
+func (p *pngCanvas) ring(cx float64, cy float64, r float64, c color.Color) {
+	halfWidth := p.opts.LineWidth / 2
+	inner, outer := math.Max(r-halfWidth, 0), r+halfWidth
+
+	p.fill(cx, cy, outer, rgbaOf(c), func(x float64, y float64) bool {
+		d := x*x + y*y
+		return d >= inner*inner && d <= outer*outer
+	})
+}
+
+func (p *pngCanvas) rotate(angle float64, cx float64, cy float64) {
+	p.angle, p.rx, p.ry = angle, cx, cy
+}
+
+func (p *pngCanvas) unrotate() {
+	p.angle = 0
+}
+
+func (p *pngCanvas) layer(name string) {
+	p.multiply = true
+}
+
+func (p *pngCanvas) end() error {
+	return p.encode(p.w, p.img)
+}
+
+// shape fills a shape in c, outlining it first if the options give a
+// stroke.  inside is like that of fill, except that grow moves the
+// edges of the shape outward by that much, or inward if it is
+// negative.  The stroke is painted as the shape grown by half the
+// stroke width, and the shape shrunk by as much is painted on top of
+// it, so the stroke is centered on the edge like it is in the SVG.
+func (p *pngCanvas) shape(cx float64, cy float64, extent float64, c color.Color, inside func(x float64, y float64, grow float64) bool) {
+	grow := 0.0
+	if p.opts.Stroke != nil {
+		grow = p.opts.StrokeWidth / 2
+		p.fill(cx, cy, extent+grow, rgbaOf(p.opts.Stroke), func(x float64, y float64) bool {
+			return inside(x, y, grow)
+		})
+	}
+
+	p.fill(cx, cy, extent, rgbaOf(c), func(x float64, y float64) bool {
+		return inside(x, y, -grow)
+	})
+}
+
+// fill paints c onto every pixel covered by a shape centered on cx,
+// cy that reaches no further than extent from it along either axis.
+// inside reports whether a point, relative to the center, lies inside
+// the shape.  Each pixel is sampled rasterSamples times along each
+// axis, or as many as opts.Supersample says, which is what gives the
+// dots smooth edges.  This is the same as drawing the shape that many
+// times larger and averaging the pixels down.
+func (p *pngCanvas) fill(cx float64, cy float64, extent float64, c color.RGBA, inside func(x float64, y float64) bool) {
+	samples := p.opts.Supersample
+	if samples == 0 {
+		samples = rasterSamples
+	}
+
+	// Samples are rotated back around the center of the rotation
+	// rather than rotating the shape.  Without a rotation the center
+	// of the shape will do.
+	rx, ry := cx, cy
+	if p.angle != 0 {
+		rx, ry = p.rx, p.ry
+
+		// A rotated square reaches further out than its radius
+		extent = math.Hypot(cx-rx, cy-ry) + extent*math.Sqrt2
+	}
+	sin, cos := math.Sincos(p.angle)
+
+	area := image.Rect(int(rx-extent)-1, int(ry-extent)-1, int(rx+extent)+2, int(ry+extent)+2).Intersect(p.img.Bounds())
+
+	for py := area.Min.Y; py < area.Max.Y; py++ {
+		for px := area.Min.X; px < area.Max.X; px++ {
+			hits := 0
+			for i := 0; i < samples; i++ {
+				for j := 0; j < samples; j++ {
+					x := float64(px) + (float64(i)+0.5)/float64(samples) - rx
+					y := float64(py) + (float64(j)+0.5)/float64(samples) - ry
+
+					if inside(x*cos+y*sin+(rx-cx), y*cos-x*sin+(ry-cy)) {
+						hits++
+					}
+				}
+			}
+
+			if hits == 0 {
+				continue
+			}
+
+			coverage := float64(hits) / float64(samples*samples)
+			if p.multiply {
+				multiply(p.img, px, py, c, coverage)
+			} else {
+				blend(p.img, px, py, c, coverage)
+			}
+		}
+	}
+}
+
+// insidePolygon reports whether x, y is inside the polygon using the
+// even-odd rule.
+func insidePolygon(x float64, y float64, xs []float64, ys []float64) bool {
+	inside := false
+
+	for i, j := 0, len(xs)-1; i < len(xs); j, i = i, i+1 {
+		if (ys[i] > y) != (ys[j] > y) && x < (xs[j]-xs[i])*(y-ys[i])/(ys[j]-ys[i])+xs[i] {
+			inside = !inside
+		}
+	}
+
+	return inside
+}
+
+// blend paints c over the pixel at px, py with the given coverage.
+func blend(img *image.RGBA, px int, py int, c color.RGBA, coverage float64) {
+	i := img.PixOffset(px, py)
+	pix := img.Pix[i : i+4 : i+4]
+
+	a := coverage * float64(c.A) / 0xff
+	pix[0] = uint8(float64(c.R)*a + float64(pix[0])*(1-a))
+	pix[1] = uint8(float64(c.G)*a + float64(pix[1])*(1-a))
+	pix[2] = uint8(float64(c.B)*a + float64(pix[2])*(1-a))
+	pix[3] = uint8(float64(c.A)*coverage + float64(pix[3])*(1-a))
+}
+
+// multiply paints c onto the pixel at px, py with the given coverage
+// like blend, except that c is first multiplied by the color
+// underneath, so it darkens it the way ink does.  Where there is
+// nothing underneath c is painted as it is.
+func multiply(img *image.RGBA, px int, py int, c color.RGBA, coverage float64) {
+	i := img.PixOffset(px, py)
+	pix := img.Pix[i : i+4 : i+4]
+
+	a := coverage * float64(c.A) / 0xff
+	under := float64(pix[3]) / 0xff
+	for j, v := range [3]uint8{c.R, c.G, c.B} {
+		mixed := float64(v) * (1 - under + under*float64(pix[j])/0xff)
+		pix[j] = uint8(mixed*a + float64(pix[j])*(1-a))
+	}
+	pix[3] = uint8(float64(c.A)*coverage + float64(pix[3])*(1-a))
+}
@@ -0,0 +1,112 @@
+// Copyright Bjørn Borud 2019 Use of this source code is governed by
+// the license found in the accompanying LICENSE file.
+
+package points
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"image/png"
+	"testing"
+)
+
+func TestWritePNG(t *testing.T) {
+	tests := []struct {
+		name       string
+		background color.Color
+		corner     color.RGBA
+	}{
+		{"transparent", nil, color.RGBA{}},
+		{"background", color.RGBA{0xff, 0xff, 0xff, 0xff}, color.RGBA{0xff, 0xff, 0xff, 0xff}},
+	}
+
+	// A black box gives a dot that touches the sides of the box but
+	// leaves the corners alone
+	img := image.NewGray(image.Rect(0, 0, 40, 20))
+
+	for _, test := range tests {
+		opts := DefaultOptions()
+		opts.BoxSize = 20
+		opts.Format = FormatPNG
+		opts.Background = test.background
+
+		out, err := RenderBytes(img, opts)
+		if err != nil {
+			t.Fatalf("%s: %v", test.name, err)
+		}
+
+		decoded, err := png.Decode(bytes.NewReader(out))
+		if err != nil {
+			t.Fatalf("%s: expected a png: %v", test.name, err)
+		}
+
+		if got := decoded.Bounds(); got != image.Rect(0, 0, 40, 20) {
+			t.Errorf("%s: expected 40x20, got %v", test.name, got)
+		}
+
+		for _, p := range []image.Point{{10, 10}, {30, 10}} {
+			if got := color.RGBAModel.Convert(decoded.At(p.X, p.Y)); got != (color.RGBA{0, 0, 0, 0xff}) {
+				t.Errorf("%s: expected black in the middle of the dot at %v, got %v", test.name, p, got)
+			}
+		}
+
+		if got := color.RGBAModel.Convert(decoded.At(0, 0)); got != test.corner {
+			t.Errorf("%s: expected %v in the corner, got %v", test.name, test.corner, got)
+		}
+	}
+}
+
+func TestSupersample(t *testing.T) {
+	// One large dot, read along the diagonal from the corner to the
+	// center where the edge of the dot crosses pixels at all angles
+	img := image.NewGray(image.Rect(0, 0, 40, 40))
+
+	edge := func(samples int) []uint8 {
+		opts := DefaultOptions()
+		opts.BoxSize = 40
+		opts.Format = FormatPNG
+		opts.Supersample = samples
+
+		out, err := RenderBytes(img, opts)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		decoded, err := png.Decode(bytes.NewReader(out))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		var alphas []uint8
+		for i := 0; i < 20; i++ {
+			alphas = append(alphas, color.NRGBAModel.Convert(decoded.At(i, i)).(color.NRGBA).A)
+		}
+		return alphas
+	}
+
+	// Count the pixels that are partly covered
+	partial := func(alphas []uint8) int {
+		n := 0
+		for _, a := range alphas {
+			if a != 0 && a != 0xff {
+				n++
+			}
+		}
+		return n
+	}
+
+	hard, smooth := edge(1), edge(4)
+
+	if partial(hard) != 0 {
+		t.Errorf("-ss 1: expected only covered and uncovered pixels, got %v", hard)
+	}
+	if partial(smooth) == 0 {
+		t.Errorf("-ss 4: expected partly covered pixels along the edge, got %v", smooth)
+	}
+
+	// Away from the edge they agree
+	if hard[0] != 0 || smooth[0] != 0 || hard[19] != 0xff || smooth[19] != 0xff {
+		t.Errorf("expected the corner uncovered and the center covered, got %v and %v", hard, smooth)
+	}
+}
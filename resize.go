@@ -0,0 +1,110 @@
+// Copyright Bjørn Borud 2019 Use of this source code is governed by
+// the license found in the accompanying LICENSE file.
+
+package points
+
+import (
+	"image"
+	"image/color"
+	"math"
+
+	"golang.org/x/image/draw"
+)
+
+// The filters Options.Filter can scale the image down with.
+const (
+	// FilterBilinear interpolates between the four nearest pixels.
+	FilterBilinear = "bilinear"
+
+	// FilterNearest picks the nearest pixel, which keeps hard edges
+	// hard.
+	FilterNearest = "nearest"
+
+	// FilterCatmullRom uses the Catmull-Rom kernel, which looks at
+	// more of the image than bilinear and is smoother, but slower.
+	FilterCatmullRom = "catmullrom"
+)
+
+func validFilter(filter string) bool {
+	switch filter {
+	case "", FilterBilinear, FilterNearest, FilterCatmullRom:
+		return true
+	}
+	return false
+}
+
+// resize scales img down, using the given filter, so that its
+// longest side is at most maxDim pixels.  Images that are already
+// small enough are returned as they are.
+func resize(img image.Image, maxDim int, filter string) image.Image {
+	b := img.Bounds()
+	longest := maxInt(b.Dx(), b.Dy())
+	if maxDim <= 0 || longest <= maxDim {
+		return img
+	}
+
+	f := float64(maxDim) / float64(longest)
+	w := maxInt(1, int(math.Round(float64(b.Dx())*f)))
+	h := maxInt(1, int(math.Round(float64(b.Dy())*f)))
+
+	dst := image.NewRGBA64(image.Rect(0, 0, w, h))
+
+	var interpolator draw.Interpolator
+	switch filter {
+	case FilterNearest:
+		interpolator = draw.NearestNeighbor
+	case FilterCatmullRom:
+		interpolator = draw.CatmullRom
+	}
+
+	if interpolator != nil {
+		interpolator.Scale(dst, dst.Bounds(), img, b, draw.Src, nil)
+		return dst
+	}
+
+	// Bilinear is done by hand, the way it always has been, so the
+	// default output doesn't change.
+	xRatio := float64(b.Dx()) / float64(w)
+	yRatio := float64(b.Dy()) / float64(h)
+
+	for y := 0; y < h; y++ {
+		// Position of the center of the destination pixel in the
+		// source image
+		sy := (float64(y)+0.5)*yRatio - 0.5
+		y0 := int(math.Floor(sy))
+		fy := sy - float64(y0)
+
+		for x := 0; x < w; x++ {
+			sx := (float64(x)+0.5)*xRatio - 0.5
+			x0 := int(math.Floor(sx))
+			fx := sx - float64(x0)
+
+			tl := pixel(img, b.Min.X+x0, b.Min.Y+y0)
+			tr := pixel(img, b.Min.X+x0+1, b.Min.Y+y0)
+			bl := pixel(img, b.Min.X+x0, b.Min.Y+y0+1)
+			br := pixel(img, b.Min.X+x0+1, b.Min.Y+y0+1)
+
+			var c [4]float64
+			for i := range c {
+				top := tl[i]*(1-fx) + tr[i]*fx
+				bottom := bl[i]*(1-fx) + br[i]*fx
+				c[i] = top*(1-fy) + bottom*fy
+			}
+
+			dst.SetRGBA64(x, y, color.RGBA64{uint16(c[0] + 0.5), uint16(c[1] + 0.5), uint16(c[2] + 0.5), uint16(c[3] + 0.5)})
+		}
+	}
+
+	return dst
+}
+
+// pixel returns the 16 bit premultiplied channels of the pixel at x, y,
+// clamping the coordinates to the image bounds.
+func pixel(img image.Image, x int, y int) [4]float64 {
+	b := img.Bounds()
+	x = clampInt(x, b.Min.X, b.Max.X-1)
+	y = clampInt(y, b.Min.Y, b.Max.Y-1)
+
+	r, g, bl, a := img.At(x, y).RGBA()
+	return [4]float64{float64(r), float64(g), float64(bl), float64(a)}
+}
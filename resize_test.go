@@ -0,0 +1,107 @@
+// Copyright Bjørn Borud 2019 Use of this source code is governed by
+// the license found in the accompanying LICENSE file.
+
+package points
+
+import (
+	"image"
+	"reflect"
+	"testing"
+)
+
+func TestResize(t *testing.T) {
+	tests := []struct {
+		name   string
+		size   image.Rectangle
+		maxDim int
+		want   image.Rectangle
+	}{
+		{"no limit", image.Rect(0, 0, 1000, 500), 0, image.Rect(0, 0, 1000, 500)},
+		{"small enough", image.Rect(0, 0, 400, 200), 500, image.Rect(0, 0, 400, 200)},
+		{"wide", image.Rect(0, 0, 1000, 500), 500, image.Rect(0, 0, 500, 250)},
+		{"tall", image.Rect(0, 0, 300, 1200), 600, image.Rect(0, 0, 150, 600)},
+	}
+
+	for _, test := range tests {
+		img := image.NewGray(test.size)
+		if got := resize(img, test.maxDim, "").Bounds(); got != test.want {
+			t.Errorf("%s: expected %v, got %v", test.name, test.want, got)
+		}
+	}
+}
+
+func TestMaxDim(t *testing.T) {
+	img := image.NewGray(image.Rect(0, 0, 1000, 500))
+
+	opts := DefaultOptions()
+	opts.BoxSize = 50
+	opts.MaxDim = 500
+
+	r, err := layout(img, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The dots come from the 500 pixel wide image but fill the
+	// output at the original size
+	if r.grid.width != 500 || r.grid.height != 250 || r.grid.cols != 10 || r.grid.rows != 5 {
+		t.Errorf("expected a 10x5 grid over 500x250 pixels, got %dx%d over %dx%d", r.grid.cols, r.grid.rows, r.grid.width, r.grid.height)
+	}
+
+	if r.width != 1000 || r.height != 500 {
+		t.Errorf("expected a 1000x500 output, got %dx%d", r.width, r.height)
+	}
+
+	first, last := r.dots[0], r.dots[len(r.dots)-1]
+	if first.cx != 50 || first.cy != 50 || first.radius != 50 || last.cx != 950 || last.cy != 450 {
+		t.Errorf("expected the dots scaled up to the original size, got %+v and %+v", first, last)
+	}
+}
+
+func TestFilters(t *testing.T) {
+	// Black on the left and white on the right, with the hard edge
+	// falling inside a pixel of the scaled down image
+	img := image.NewGray(image.Rect(0, 0, 61, 10))
+	for y := 0; y < 10; y++ {
+		for x := 30; x < 61; x++ {
+			img.Pix[img.PixOffset(x, y)] = 0xff
+		}
+	}
+
+	grays := func(filter string) []uint32 {
+		small := resize(img, 20, filter)
+		b := small.Bounds()
+
+		var row []uint32
+		for x := b.Min.X; x < b.Max.X; x++ {
+			r, _, _, _ := small.At(x, b.Min.Y).RGBA()
+			row = append(row, r)
+		}
+		return row
+	}
+
+	nearest := grays(FilterNearest)
+	catmullRom := grays(FilterCatmullRom)
+
+	// Nearest only picks existing pixels, so the edge stays hard
+	for x, v := range nearest {
+		if v != 0 && v != 0xffff {
+			t.Errorf("nearest: expected black or white at %d, got %#x", x, v)
+		}
+	}
+
+	// Catmull-Rom blends the pixels on both sides of the edge
+	blended := false
+	for _, v := range catmullRom {
+		if v != 0 && v != 0xffff {
+			blended = true
+		}
+	}
+	if !blended {
+		t.Errorf("catmullrom: expected a gray at the edge, got %v", catmullRom)
+	}
+
+	if reflect.DeepEqual(nearest, catmullRom) {
+		t.Errorf("expected the filters to differ, both gave %v", nearest)
+	}
+}
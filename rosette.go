@@ -0,0 +1,146 @@
+// Copyright Bjørn Borud 2019 Use of this source code is governed by
+// the license found in the accompanying LICENSE file.
+
+package points
+
+import (
+	"image"
+	"image/color"
+	"math"
+)
+
+// rosetteScreen is one of the four halftone screens of Rosette.  ink
+// picks the screen's channel out of a CMYK color.
+type rosetteScreen struct {
+	name  string
+	angle float64
+	color color.RGBA
+	ink   func(c color.CMYK) uint8
+}
+
+// rosetteScreens are the screens of Rosette in the order they are
+// drawn, at the angles, in degrees counterclockwise, traditionally
+// used in print.  The angles keep the screens from making moiré
+// patterns with each other, and yellow, which is the hardest to see,
+// gets the angle that shows the most.
+var rosetteScreens = []rosetteScreen{
+	{"cyan", 15, color.RGBA{0x00, 0xff, 0xff, 0xff}, func(c color.CMYK) uint8 { return c.C }},
+	{"magenta", 75, color.RGBA{0xff, 0x00, 0xff, 0xff}, func(c color.CMYK) uint8 { return c.M }},
+	{"yellow", 0, color.RGBA{0xff, 0xff, 0x00, 0xff}, func(c color.CMYK) uint8 { return c.Y }},
+	{"black", 45, color.RGBA{0x00, 0x00, 0x00, 0xff}, func(c color.CMYK) uint8 { return c.K }},
+}
+
+// computeRosette computes a grid of dots for each of the
+// rosetteScreens.  The grids are as fine as the grid of boxes but
+// turned to the angle of their screen, and the size of each dot is
+// how much of the screen's ink the image needs around it.  The dots of
+// the first screen come first and so on, and their layer is the index
+// of the screen.
+func computeRosette(img image.Image, g grid, opts Options) []dot {
+	// The dots are in the colors of the inks
+	opts.Palette = nil
+	opts.ColorImage = nil
+
+	var dots []dot
+	for i, screen := range rosetteScreens {
+		for _, d := range computeScreen(img, g, screen, opts) {
+			d.layer = i
+			dots = append(dots, d)
+		}
+	}
+	return dots
+}
+
+// computeScreen computes the dots of a single screen.  The rows of
+// the turned grid are divided up between opts.Workers goroutines like
+// the boxes of computeDots.  Only the boxes whose center is inside
+// the image get a dot, and the dots are returned row by row, that is
+// i inner and j outer.
+func computeScreen(img image.Image, g grid, screen rosetteScreen, opts Options) []dot {
+	bw, bh := float64(g.colStep), g.rowStep
+	width, height := float64(g.width), float64(g.height)
+
+	// The image y axis points down, so turning the grid clockwise
+	// in image coordinates turns it counterclockwise on screen.
+	sin, cos := math.Sincos(-screen.angle * math.Pi / 180)
+
+	// Find the boxes of the turned grid, u along its columns and v
+	// along its rows, that cover the corners of the image.
+	minU, minV := math.Inf(1), math.Inf(1)
+	maxU, maxV := math.Inf(-1), math.Inf(-1)
+	for _, corner := range [][2]float64{{0, 0}, {width, 0}, {0, height}, {width, height}} {
+		u := (corner[0]*cos + corner[1]*sin) / bw
+		v := (corner[1]*cos - corner[0]*sin) / bh
+		minU, maxU = math.Min(minU, u), math.Max(maxU, u)
+		minV, maxV = math.Min(minV, v), math.Max(maxV, v)
+	}
+	minU, minV = math.Floor(minU), math.Floor(minV)
+	cols := int(math.Ceil(maxU) - minU)
+	rows := int(math.Ceil(maxV) - minV)
+
+	dots := make([]dot, cols*rows)
+	inside := make([]bool, cols*rows)
+	half := minInt(g.boxWidth, g.boxHeight) / 2
+
+	forEachRow(rows, opts, func(j int) {
+		for i := 0; i < cols; i++ {
+			u := (minU + float64(i) + 0.5) * bw
+			v := (minV + float64(j) + 0.5) * bh
+			x := u*cos - v*sin
+			y := u*sin + v*cos
+
+			if x < 0 || y < 0 || x >= width || y >= height {
+				continue
+			}
+			dots[j*cols+i] = makeScreenDot(img, g, int(x), int(y), half, screen, opts)
+			inside[j*cols+i] = true
+		}
+	})
+
+	kept := dots[:0]
+	for i, d := range dots {
+		if inside[i] {
+			kept = append(kept, d)
+		}
+	}
+	return kept
+}
+
+// makeScreenDot makes the dot of a screen centered on x, y from a box
+// around it that is as large as those of the grid.  The ink is
+// treated like the darkness of makeDot, so the levels, the threshold,
+// the dot gamma and the radius options work the same way.
+func makeScreenDot(img image.Image, g grid, x int, y int, boxHalf int, screen rosetteScreen, opts Options) dot {
+	half := float64(boxHalf) * opts.Scale
+
+	box := image.Rect(x-g.boxWidth/2, y-g.boxHeight/2, x-g.boxWidth/2+g.boxWidth, y-g.boxHeight/2+g.boxHeight)
+	src := box.Intersect(image.Rect(0, 0, g.width, g.height)).Add(img.Bounds().Min)
+	c, alpha := boxColor(img, src, opts)
+
+	if alpha == 0 || alpha < opts.AlphaCutoff {
+		return dot{}
+	}
+
+	// How little ink there is plays the part of the luma
+	light := levels(1.0-float64(screen.ink(dotCMYK(img, src, c, opts)))/0xff, opts)
+	size := 1.0 - light
+	if opts.Invert {
+		size = light
+	}
+
+	if skipLuma(light, opts) {
+		return dot{}
+	}
+
+	darkness := size
+
+	if opts.DotGamma > 0 && opts.DotGamma != 1.0 {
+		size = math.Pow(size, opts.DotGamma)
+	}
+
+	d := placeDot(img, box, clampRadius(dotRadius(size, half, opts), half, opts), size, screen.color, opts)
+	if opts.Opacity > 0 {
+		d.color.A = uint8(math.Round(0xff * math.Pow(darkness, opts.Opacity)))
+	}
+	return d
+}
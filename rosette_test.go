@@ -0,0 +1,70 @@
+// Copyright Bjørn Borud 2019 Use of this source code is governed by
+// the license found in the accompanying LICENSE file.
+
+package points
+
+import (
+	"image"
+	"image/color"
+	"image/draw"
+	"strings"
+	"testing"
+)
+
+func TestRosette(t *testing.T) {
+	// No cyan, half magenta, three quarters yellow and half black
+	img := image.NewRGBA(image.Rect(0, 0, 100, 100))
+	draw.Draw(img, img.Bounds(), image.NewUniform(color.RGBA{0x80, 0x40, 0x20, 0xff}), image.Point{}, draw.Src)
+
+	opts := DefaultOptions()
+	opts.BoxSize = 10
+	opts.Rosette = true
+
+	out, err := RenderBytes(img, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// One group per screen, in order, even if it has no dots
+	s := string(out)
+	if n := strings.Count(s, "<g id="); n != 4 {
+		t.Errorf("expected 4 groups, got %d", n)
+	}
+
+	last := -1
+	for _, name := range []string{"cyan", "magenta", "yellow", "black"} {
+		i := strings.Index(s, `<g id="`+name+`" style="mix-blend-mode:multiply"`)
+		if i < last {
+			t.Errorf("expected a %s group after the one before it, got %s", name, s)
+		}
+		last = i
+	}
+
+	r, err := layout(img, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The dots of each screen are in the color of its ink and sized
+	// by how much of it there is
+	counts := make([]int, len(rosetteScreens))
+	for _, d := range r.dots {
+		if !d.visible {
+			continue
+		}
+		counts[d.layer]++
+
+		if want := rosetteScreens[d.layer].color; d.color != want {
+			t.Errorf("expected %v dots on the %s screen, got %v", want, rosetteScreens[d.layer].name, d.color)
+		}
+	}
+
+	if counts[0] != 0 {
+		t.Errorf("expected no cyan dots, got %d", counts[0])
+	}
+	for i, n := range counts[1:] {
+		if n == 0 {
+			t.Errorf("expected %s dots, got none", rosetteScreens[i+1].name)
+		}
+	}
+}
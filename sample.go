@@ -0,0 +1,361 @@
+// Copyright Bjørn Borud 2019 Use of this source code is governed by
+// the license found in the accompanying LICENSE file.
+
+package points
+
+import (
+	"image"
+	"image/color"
+	"math"
+	"sort"
+)
+
+// The ways the color of a box can be computed from its pixels.
+const (
+	// ColorModeMean uses the average color of the box.
+	ColorModeMean = "mean"
+
+	// ColorModeMedian uses the median of each channel.  This keeps
+	// high contrast regions from being washed out into muddy
+	// grays, but it has to hold on to every pixel value of the box
+	// and sort them, so it needs memory proportional to the box area
+	// and is noticeably slower for large boxes.
+	ColorModeMedian = "median"
+
+	// ColorModeDominant sorts the pixels into a coarse color
+	// histogram and uses the average color of the fullest bucket.
+	// This works well for logos and flat color art where averaging
+	// would smear distinct colors together.
+	ColorModeDominant = "dominant"
+
+	// ColorModeLumaWeighted averages the pixels weighted by their
+	// darkness, or by their luma when Invert is set, so the color
+	// leans toward the pixels that make the dot big.  Where a box
+	// straddles a bright and a dark region the dot gets the color of
+	// the dark one rather than a muddy mix.  Since the dark pixels
+	// count for more the dots come out a little larger too.
+	ColorModeLumaWeighted = "lumaweight"
+)
+
+func validColorMode(mode string) bool {
+	switch mode {
+	case "", ColorModeMean, ColorModeMedian, ColorModeDominant, ColorModeLumaWeighted:
+		return true
+	}
+	return false
+}
+
+// The color spaces ColorModeMean can average colors in.
+const (
+	// ColorSpaceSRGB averages the sRGB values as they are, which
+	// makes the average too dark and desaturated.
+	ColorSpaceSRGB = "srgb"
+
+	// ColorSpaceLinear averages in linear light.
+	ColorSpaceLinear = "linear"
+
+	// ColorSpaceLab averages in CIELAB, where distances follow
+	// how different colors look, so the average is closer to how
+	// the colors of the box mix to the eye.
+	ColorSpaceLab = "lab"
+)
+
+func validColorSpace(space string) bool {
+	switch space {
+	case "", ColorSpaceSRGB, ColorSpaceLinear, ColorSpaceLab:
+		return true
+	}
+	return false
+}
+
+// boxColor computes the color of the pixels of img within box using
+// the color mode given in the options.  The box is in image
+// coordinates.  Transparent pixels count for less, or not at all, so
+// the color is that of the visible pixels.  The second return value
+// is the average alpha of the box from 0.0 to 1.0.
+func boxColor(img image.Image, box image.Rectangle, opts Options) (color.RGBA, float64) {
+	if opts.Samples > 0 && opts.Samples < box.Dx()*box.Dy() {
+		s := newSampledBox(img, box, opts.Samples)
+		img, box = s, s.Bounds()
+	}
+
+	switch opts.ColorMode {
+	case ColorModeMedian:
+		return medianColor(img, box)
+	case ColorModeDominant:
+		return dominantColor(img, box)
+	}
+
+	// The averages are weighted by alpha, so the falloff goes into
+	// the alpha, and the alpha that comes out is the weighted mean of
+	// that of the pixels rather than how much the weights add up to.
+	if opts.CircularSample {
+		c := newCircularBox(img, box)
+		col, alpha := boxMean(c, box, opts)
+		return col, alpha / c.meanWeight()
+	}
+	return boxMean(img, box, opts)
+}
+
+// boxMean computes the color of the box with one of the color modes
+// that average the pixels.
+func boxMean(img image.Image, box image.Rectangle, opts Options) (color.RGBA, float64) {
+	switch opts.ColorMode {
+	case ColorModeLumaWeighted:
+		return lumaWeightedColor(img, box, chooseLuma(opts), opts.Invert)
+	default:
+		switch {
+		case opts.ColorSpace == ColorSpaceLab:
+			return labMeanColor(img, box)
+		case opts.ColorSpace == ColorSpaceLinear || opts.Linear:
+			return linearMeanColor(img, box)
+		}
+		return meanColor(img, box)
+	}
+}
+
+// meanColor returns the alpha weighted average color of the box.
+func meanColor(img image.Image, box image.Rectangle) (color.RGBA, float64) {
+	// The channels are 16 bit regardless of the depth of the source
+	// image, so the sums need 64 bits to not overflow for boxes
+	// larger than 256x256.
+	var rSum, gSum, bSum, aSum uint64
+
+	at := pixelReader(img)
+	for cx := box.Min.X; cx < box.Max.X; cx++ {
+		for cy := box.Min.Y; cy < box.Max.Y; cy++ {
+			r, g, b, a := at(cx, cy)
+			rSum += uint64(r)
+			gSum += uint64(g)
+			bSum += uint64(b)
+			aSum += uint64(a)
+		}
+	}
+
+	if aSum == 0 {
+		return color.RGBA{A: 0xff}, 0
+	}
+
+	// The channels are premultiplied by alpha, so dividing by the
+	// summed alpha rather than the number of pixels gives the alpha
+	// weighted average.  For opaque images this is the same thing.
+	rSum = rSum * 0xffff / aSum
+	gSum = gSum * 0xffff / aSum
+	bSum = bSum * 0xffff / aSum
+
+	// RGBA returns 16 bit values even for 8 bit images, where an 8
+	// bit value v becomes v * 0x101.  The high byte is the 8 bit
+	// value, both for those and for real 16 bit images, which is how
+	// the image/color package converts as well.
+	rSum >>= 8
+	gSum >>= 8
+	bSum >>= 8
+
+	pixels := uint64(box.Dx() * box.Dy())
+	return color.RGBA{uint8(rSum), uint8(gSum), uint8(bSum), 0xff}, float64(aSum) / float64(pixels*0xffff)
+}
+
+// medianColor returns the per channel median of the box, ignoring
+// pixels that are completely transparent.
+func medianColor(img image.Image, box image.Rectangle) (color.RGBA, float64) {
+	pixels := box.Dx() * box.Dy()
+	rs := make([]uint32, 0, pixels)
+	gs := make([]uint32, 0, pixels)
+	bs := make([]uint32, 0, pixels)
+
+	var aSum uint64
+
+	at := pixelReader(img)
+	for cx := box.Min.X; cx < box.Max.X; cx++ {
+		for cy := box.Min.Y; cy < box.Max.Y; cy++ {
+			r, g, b, a := at(cx, cy)
+			if a == 0 {
+				continue
+			}
+			r, g, b = unpremultiply(r, g, b, a)
+			rs = append(rs, r)
+			gs = append(gs, g)
+			bs = append(bs, b)
+			aSum += uint64(a)
+		}
+	}
+
+	if len(rs) == 0 {
+		return color.RGBA{A: 0xff}, 0
+	}
+
+	return color.RGBA{median(rs), median(gs), median(bs), 0xff}, float64(aSum) / float64(pixels*0xffff)
+}
+
+// median sorts the values and returns the middle one scaled down to
+// 8 bits.
+func median(values []uint32) uint8 {
+	sort.Slice(values, func(i, j int) bool { return values[i] < values[j] })
+	return uint8(values[len(values)/2] >> 8)
+}
+
+// dominantColor returns the average color of the most common group of
+// similar colors in the box, ignoring pixels that are completely
+// transparent.
+func dominantColor(img image.Image, box image.Rectangle) (color.RGBA, float64) {
+	h := histogram{}
+
+	var aSum uint64
+
+	at := pixelReader(img)
+	for cx := box.Min.X; cx < box.Max.X; cx++ {
+		for cy := box.Min.Y; cy < box.Max.Y; cy++ {
+			r, g, b, a := at(cx, cy)
+			if a == 0 {
+				continue
+			}
+			h.add(unpremultiply(r, g, b, a))
+			aSum += uint64(a)
+		}
+	}
+
+	pixels := uint64(box.Dx() * box.Dy())
+	return h.dominant(), float64(aSum) / float64(pixels*0xffff)
+}
+
+// lumaWeightedColor returns the average color of the box where each
+// pixel is weighted by its darkness, or by its luma if invert is set,
+// as well as by its alpha.  A box without any weight, such as one
+// that is all white, gets its plain average color.
+func lumaWeightedColor(img image.Image, box image.Rectangle, luma lumaFunc, invert bool) (color.RGBA, float64) {
+	var rSum, gSum, bSum, wSum, aSum float64
+
+	at := pixelReader(img)
+	for cx := box.Min.X; cx < box.Max.X; cx++ {
+		for cy := box.Min.Y; cy < box.Max.Y; cy++ {
+			r, g, b, a := at(cx, cy)
+			ur, ug, ub := unpremultiply(r, g, b, a)
+
+			w := luma(ur>>8, ug>>8, ub>>8)
+			if !invert {
+				w = 1 - w
+			}
+
+			// The channels are premultiplied, so weighing them
+			// like this weighs by the alpha as well.
+			rSum += w * float64(r)
+			gSum += w * float64(g)
+			bSum += w * float64(b)
+			wSum += w * float64(a)
+			aSum += float64(a)
+		}
+	}
+
+	if wSum == 0 {
+		return meanColor(img, box)
+	}
+
+	pixels := float64(box.Dx() * box.Dy())
+	return color.RGBA{
+		R: uint8(math.Round(rSum / wSum * 0xff)),
+		G: uint8(math.Round(gSum / wSum * 0xff)),
+		B: uint8(math.Round(bSum / wSum * 0xff)),
+		A: 0xff,
+	}, aSum / (pixels * 0xffff)
+}
+
+// unpremultiply undoes the alpha premultiplication of the 16 bit
+// channels returned by color.Color.RGBA.
+func unpremultiply(r uint32, g uint32, b uint32, a uint32) (uint32, uint32, uint32) {
+	if a == 0xffff || a == 0 {
+		return r, g, b
+	}
+	return r * 0xffff / a, g * 0xffff / a, b * 0xffff / a
+}
+
+// circularBox weighs the pixels of a box of an image by how close they
+// are to its middle, so the parts of the box a round dot covers count
+// for more than the corners.  The weight falls off like a Gaussian
+// with a standard deviation of a quarter of the shorter side of the
+// box, so it is about 0.14 in the middle of the sides and 0.02 in the
+// corners.  The weight goes into the alpha, along with the channels
+// since they are premultiplied.
+type circularBox struct {
+	image.Image
+	box image.Rectangle
+
+	// The middle of the box and half its shorter side
+	cx, cy float64
+	half   float64
+}
+
+func newCircularBox(img image.Image, box image.Rectangle) circularBox {
+	return circularBox{
+		Image: img,
+		box:   box,
+		cx:    float64(box.Min.X+box.Max.X) / 2,
+		cy:    float64(box.Min.Y+box.Max.Y) / 2,
+		half:  math.Max(float64(minInt(box.Dx(), box.Dy()))/2, 0.5),
+	}
+}
+
+func (c circularBox) At(x int, y int) color.Color {
+	r, g, b, a := c.Image.At(x, y).RGBA()
+	w := c.weight(x, y)
+	return color.RGBA64{
+		R: uint16(float64(r) * w),
+		G: uint16(float64(g) * w),
+		B: uint16(float64(b) * w),
+		A: uint16(float64(a) * w),
+	}
+}
+
+// weight returns the weight of the pixel at x, y.  The distance is
+// measured to the middle of the pixel, in halves of the shorter side.
+func (c circularBox) weight(x int, y int) float64 {
+	dx := (float64(x) + 0.5 - c.cx) / c.half
+	dy := (float64(y) + 0.5 - c.cy) / c.half
+	return math.Exp(-2 * (dx*dx + dy*dy))
+}
+
+// meanWeight returns the average weight of the pixels of the box.
+func (c circularBox) meanWeight() float64 {
+	var sum float64
+	for y := c.box.Min.Y; y < c.box.Max.Y; y++ {
+		for x := c.box.Min.X; x < c.box.Max.X; x++ {
+			sum += c.weight(x, y)
+		}
+	}
+	return sum / float64(c.box.Dx()*c.box.Dy())
+}
+
+// sampledBox is a small image made from pixels picked from a box of
+// a larger image on an evenly spaced grid.  Computing the color of a
+// sampledBox is much faster than computing it for the whole box.
+type sampledBox struct {
+	img  image.Image
+	box  image.Rectangle
+	cols int
+	rows int
+}
+
+// newSampledBox picks about n pixels from box.  The grid of samples
+// has the same aspect ratio as the box.
+func newSampledBox(img image.Image, box image.Rectangle, n int) sampledBox {
+	aspect := float64(box.Dx()) / float64(box.Dy())
+	cols := clampInt(int(math.Round(math.Sqrt(float64(n)*aspect))), 1, box.Dx())
+	rows := clampInt((n+cols-1)/cols, 1, box.Dy())
+
+	return sampledBox{img: img, box: box, cols: cols, rows: rows}
+}
+
+func (s sampledBox) ColorModel() color.Model {
+	return s.img.ColorModel()
+}
+
+func (s sampledBox) Bounds() image.Rectangle {
+	return image.Rect(0, 0, s.cols, s.rows)
+}
+
+// At returns the pixel in the middle of the part of the box sample x,
+// y represents.
+func (s sampledBox) At(x int, y int) color.Color {
+	px := s.box.Min.X + (2*x+1)*s.box.Dx()/(2*s.cols)
+	py := s.box.Min.Y + (2*y+1)*s.box.Dy()/(2*s.rows)
+	return s.img.At(px, py)
+}
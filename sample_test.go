@@ -0,0 +1,205 @@
+// Copyright Bjørn Borud 2019 Use of this source code is governed by
+// the license found in the accompanying LICENSE file.
+
+package points
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	"io/ioutil"
+	"math/rand"
+	"strings"
+	"testing"
+)
+
+func TestMedianColor(t *testing.T) {
+	// One outlier pulls the mean a long way but not the median
+	img := image.NewRGBA(image.Rect(0, 0, 3, 1))
+	img.Set(0, 0, color.RGBA{0, 100, 10, 0xff})
+	img.Set(1, 0, color.RGBA{10, 110, 20, 0xff})
+	img.Set(2, 0, color.RGBA{200, 255, 30, 0xff})
+
+	tests := []struct {
+		mode string
+		want color.RGBA
+	}{
+		{ColorModeMean, color.RGBA{70, 155, 20, 0xff}},
+		{ColorModeMedian, color.RGBA{10, 110, 20, 0xff}},
+	}
+
+	for _, test := range tests {
+		opts := DefaultOptions()
+		opts.ColorMode = test.mode
+
+		got, alpha := boxColor(img, img.Bounds(), opts)
+		if got != test.want || alpha != 1 {
+			t.Errorf("%s: expected %v and alpha 1, got %v and %g", test.mode, test.want, got, alpha)
+		}
+	}
+}
+
+func TestMedianColorTransparent(t *testing.T) {
+	// Transparent pixels are left out of the median
+	img := image.NewRGBA(image.Rect(0, 0, 4, 1))
+	img.Set(0, 0, color.RGBA{50, 50, 50, 0xff})
+	img.Set(1, 0, color.RGBA{60, 60, 60, 0xff})
+	img.Set(2, 0, color.RGBA{70, 70, 70, 0xff})
+
+	got, alpha := medianColor(img, img.Bounds())
+	if got != (color.RGBA{60, 60, 60, 0xff}) || alpha != 0.75 {
+		t.Errorf("expected gray 60 and alpha 0.75, got %v and %g", got, alpha)
+	}
+}
+
+func TestSixteenBitColor(t *testing.T) {
+	tests := []struct {
+		name string
+		img  draw.Image
+		c    color.Color
+		want string
+	}{
+		{"rgba64", image.NewRGBA64(image.Rect(0, 0, 20, 20)), color.RGBA64{0x12ff, 0xab00, 0xff80, 0xffff}, "#12abff"},
+		{"rgba64 low", image.NewRGBA64(image.Rect(0, 0, 20, 20)), color.RGBA64{0x00ff, 0x0100, 0x7fff, 0xffff}, "#00017f"},
+		{"rgba", image.NewRGBA(image.Rect(0, 0, 20, 20)), color.RGBA{0x12, 0xab, 0xff, 0xff}, "#12abff"},
+		{"gray16", image.NewGray16(image.Rect(0, 0, 20, 20)), color.Gray16{0x80ff}, "#808080"},
+	}
+
+	for _, test := range tests {
+		draw.Draw(test.img, test.img.Bounds(), image.NewUniform(test.c), image.Point{}, draw.Src)
+
+		opts := DefaultOptions()
+		opts.BoxSize = 20
+		opts.Color = true
+		opts.Format = FormatJSON
+
+		out, err := RenderBytes(test.img, opts)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if want := `"color":"` + test.want + `"`; !strings.Contains(string(out), want) {
+			t.Errorf("%s: expected %s, got %s", test.name, want, out)
+		}
+	}
+}
+
+func TestSampledColor(t *testing.T) {
+	// A flat box, and one with fine noise around the same color
+	flat := image.NewRGBA(image.Rect(0, 0, 40, 40))
+	draw.Draw(flat, flat.Bounds(), image.NewUniform(color.RGBA{0x30, 0x80, 0xc0, 0xff}), image.Point{}, draw.Src)
+
+	noisy := image.NewRGBA(image.Rect(0, 0, 40, 40))
+	rnd := rand.New(rand.NewSource(1))
+	for x := 0; x < 40; x++ {
+		for y := 0; y < 40; y++ {
+			d := uint8(rnd.Intn(16))
+			noisy.SetRGBA(x, y, color.RGBA{0x28 + d, 0x78 + d, 0xb8 + d, 0xff})
+		}
+	}
+
+	tests := []struct {
+		name      string
+		img       image.Image
+		tolerance int
+	}{
+		{"flat", flat, 0},
+		{"noisy", noisy, 4},
+	}
+
+	for _, test := range tests {
+		opts := DefaultOptions()
+		full, _ := boxColor(test.img, test.img.Bounds(), opts)
+
+		for _, samples := range []int{4, 16, 100} {
+			opts.Samples = samples
+			got, _ := boxColor(test.img, test.img.Bounds(), opts)
+
+			for i, v := range []int{int(got.R) - int(full.R), int(got.G) - int(full.G), int(got.B) - int(full.B)} {
+				if v < -test.tolerance || v > test.tolerance {
+					t.Errorf("%s, %d samples: expected channel %d within %d of %v, got %v", test.name, samples, i, test.tolerance, full, got)
+				}
+			}
+		}
+	}
+}
+
+func BenchmarkSampledColor(b *testing.B) {
+	img := image.NewRGBA(image.Rect(0, 0, 1600, 1200))
+	rnd := rand.New(rand.NewSource(1))
+	rnd.Read(img.Pix)
+
+	for _, samples := range []int{0, 16} {
+		opts := DefaultOptions()
+		opts.BoxSize = 50
+		opts.Samples = samples
+
+		b.Run(fmt.Sprintf("samples=%d", samples), func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				if err := Render(img, opts, ioutil.Discard); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
+
+func TestLumaWeightedColor(t *testing.T) {
+	// Half dark red and half light blue
+	dark := color.RGBA{0x40, 0x00, 0x00, 0xff}
+	light := color.RGBA{0xe0, 0xe0, 0xff, 0xff}
+
+	img := image.NewRGBA(image.Rect(0, 0, 20, 20))
+	draw.Draw(img, image.Rect(0, 0, 10, 20), image.NewUniform(dark), image.Point{}, draw.Src)
+	draw.Draw(img, image.Rect(10, 0, 20, 20), image.NewUniform(light), image.Point{}, draw.Src)
+
+	opts := DefaultOptions()
+	mean, _ := boxColor(img, img.Bounds(), opts)
+
+	opts.ColorMode = ColorModeLumaWeighted
+	weighted, _ := boxColor(img, img.Bounds(), opts)
+
+	opts.Invert = true
+	inverted, _ := boxColor(img, img.Bounds(), opts)
+
+	// The dark pixels make the dot, so the color leans towards them,
+	// and towards the light ones when inverted
+	if rgbDistance(weighted, dark) >= rgbDistance(mean, dark) {
+		t.Errorf("expected %v to be closer to %v than the mean %v", weighted, dark, mean)
+	}
+	if rgbDistance(inverted, light) >= rgbDistance(mean, light) {
+		t.Errorf("expected %v to be closer to %v than the mean %v", inverted, light, mean)
+	}
+
+	// A flat box is the same either way
+	flat := image.NewRGBA(image.Rect(0, 0, 20, 20))
+	draw.Draw(flat, flat.Bounds(), image.NewUniform(dark), image.Point{}, draw.Src)
+	if got, _ := boxColor(flat, flat.Bounds(), opts); got != dark {
+		t.Errorf("expected %v for a flat box, got %v", dark, got)
+	}
+}
+
+func TestCircularSample(t *testing.T) {
+	// A dark center in a bright ring, which reaches out to the
+	// corners of the box
+	img := image.NewRGBA(image.Rect(0, 0, 20, 20))
+	draw.Draw(img, img.Bounds(), image.NewUniform(color.White), image.Point{}, draw.Src)
+	draw.Draw(img, image.Rect(6, 6, 14, 14), image.NewUniform(color.Black), image.Point{}, draw.Src)
+
+	opts := DefaultOptions()
+	flat, _ := boxColor(img, img.Bounds(), opts)
+
+	opts.CircularSample = true
+	circular, _ := boxColor(img, img.Bounds(), opts)
+
+	if circular.R >= flat.R {
+		t.Errorf("expected circular sampling to be darker than %v, got %v", flat, circular)
+	}
+
+	// A box of one color is that color either way
+	gray := image.NewUniform(color.RGBA{0x80, 0x80, 0x80, 0xff})
+	if got, _ := boxColor(gray, img.Bounds(), opts); got != (color.RGBA{0x80, 0x80, 0x80, 0xff}) {
+		t.Errorf("expected a uniform box to keep its color, got %v", got)
+	}
+}
@@ -0,0 +1,92 @@
+// Copyright Bjørn Borud 2019 Use of this source code is governed by
+// the license found in the accompanying LICENSE file.
+
+package points
+
+import (
+	"math"
+)
+
+// The shapes that can be used for the dots.
+const (
+	ShapeCircle   = "circle"
+	ShapeSquare   = "square"
+	ShapeDiamond  = "diamond"
+	ShapeTriangle = "triangle"
+	ShapeHexagon  = "hexagon"
+	ShapeStar     = "star"
+
+	// ShapeLine draws a line segment rather than a filled shape.
+	// The lines always follow the local gradient, which gives a
+	// hatched look.
+	ShapeLine = "line"
+)
+
+// starPoints is the number of points on ShapeStar.
+const starPoints = 5
+
+// validShape returns true if we know how to draw the shape.  The
+// empty string means circle.
+func validShape(shape string) bool {
+	switch shape {
+	case "", ShapeCircle, ShapeSquare, ShapeDiamond, ShapeTriangle, ShapeHexagon, ShapeStar, ShapeLine:
+		return true
+	}
+	return false
+}
+
+// rotatable returns true for shapes that change when rotated.
+func rotatable(shape string) bool {
+	return shape != "" && shape != ShapeCircle
+}
+
+// shapeVertices returns the vertices, relative to the center, of the
+// shapes that are polygons.  For other shapes it returns nil.
+func shapeVertices(opts Options, r float64) ([]float64, []float64) {
+	switch opts.Shape {
+	case ShapeDiamond:
+		return polygonVertices(r, 4, 0)
+	case ShapeTriangle:
+		return polygonVertices(r, 3, 0)
+	case ShapeHexagon:
+		return polygonVertices(r, 6, 0)
+	case ShapeStar:
+		return starVertices(r, r*opts.StarRatio, starPoints, 0)
+	}
+	return nil, nil
+}
+
+// polygonVertices returns the vertices of a regular polygon with the
+// given number of sides, relative to its center.  The vertices are on
+// a circle with the given radius and the first vertex points straight
+// up when rotation, in radians, is zero.
+func polygonVertices(radius float64, sides int, rotation float64) ([]float64, []float64) {
+	xs := make([]float64, sides)
+	ys := make([]float64, sides)
+
+	for i := 0; i < sides; i++ {
+		angle := rotation + 2*math.Pi*float64(i)/float64(sides)
+		xs[i] = radius * math.Sin(angle)
+		ys[i] = -radius * math.Cos(angle)
+	}
+
+	return xs, ys
+}
+
+// starVertices returns the vertices of a star relative to its
+// center.  The vertices alternate between the outer and the inner
+// radius.
+func starVertices(outer float64, inner float64, points int, rotation float64) ([]float64, []float64) {
+	xs, ys := polygonVertices(1.0, 2*points, rotation)
+
+	for i := range xs {
+		r := outer
+		if i%2 == 1 {
+			r = inner
+		}
+		xs[i] *= r
+		ys[i] *= r
+	}
+
+	return xs, ys
+}
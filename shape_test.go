@@ -0,0 +1,94 @@
+// Copyright Bjørn Borud 2019 Use of this source code is governed by
+// the license found in the accompanying LICENSE file.
+
+package points
+
+import (
+	"image"
+	"math"
+	"strings"
+	"testing"
+)
+
+func TestShapes(t *testing.T) {
+	tests := []struct {
+		shape string
+		want  string
+	}{
+		{"", `<circle cx="10" cy="10" r="10"`},
+		{ShapeCircle, `<circle cx="10" cy="10" r="10"`},
+		{ShapeSquare, `<rect x="0" y="0" width="20" height="20"`},
+		{ShapeDiamond, `<polygon points="10,0 20,10 10,20 0,10"`},
+	}
+
+	// A black box gives a dot as wide as the box in every shape
+	img := image.NewGray(image.Rect(0, 0, 20, 20))
+
+	for _, test := range tests {
+		opts := DefaultOptions()
+		opts.BoxSize = 20
+		opts.Shape = test.shape
+
+		out, err := RenderBytes(img, opts)
+		if err != nil {
+			t.Fatalf("%q: %v", test.shape, err)
+		}
+
+		if !strings.Contains(string(out), test.want) {
+			t.Errorf("%q: expected %s in %s", test.shape, test.want, out)
+		}
+	}
+}
+
+func TestPolygonVertices(t *testing.T) {
+	h := math.Sqrt(3) / 2
+
+	tests := []struct {
+		name     string
+		sides    int
+		rotation float64
+		xs, ys   []float64
+	}{
+		{"triangle", 3, 0, []float64{0, h, -h}, []float64{-1, 0.5, 0.5}},
+		{"diamond", 4, 0, []float64{0, 1, 0, -1}, []float64{-1, 0, 1, 0}},
+		{"square", 4, math.Pi / 4, []float64{math.Sqrt2 / 2, math.Sqrt2 / 2, -math.Sqrt2 / 2, -math.Sqrt2 / 2}, []float64{-math.Sqrt2 / 2, math.Sqrt2 / 2, math.Sqrt2 / 2, -math.Sqrt2 / 2}},
+		{"hexagon", 6, 0, []float64{0, h, h, 0, -h, -h}, []float64{-1, -0.5, 0.5, 1, 0.5, -0.5}},
+	}
+
+	for _, test := range tests {
+		xs, ys := polygonVertices(1, test.sides, test.rotation)
+		if len(xs) != test.sides || len(ys) != test.sides {
+			t.Errorf("%s: expected %d vertices, got %d and %d", test.name, test.sides, len(xs), len(ys))
+			continue
+		}
+
+		for i := range xs {
+			if math.Abs(xs[i]-test.xs[i]) > 1e-9 || math.Abs(ys[i]-test.ys[i]) > 1e-9 {
+				t.Errorf("%s: expected vertex %d at %.3f,%.3f, got %.3f,%.3f", test.name, i, test.xs[i], test.ys[i], xs[i], ys[i])
+			}
+		}
+	}
+}
+
+func TestStarVertices(t *testing.T) {
+	xs, ys := starVertices(10, 4, starPoints, 0)
+	if len(xs) != 2*starPoints || len(ys) != 2*starPoints {
+		t.Fatalf("expected %d vertices, got %d and %d", 2*starPoints, len(xs), len(ys))
+	}
+
+	// The first point is straight up and the vertices alternate
+	// between the outer and inner radius
+	for i := range xs {
+		want := 10.0
+		if i%2 == 1 {
+			want = 4
+		}
+		if r := math.Hypot(xs[i], ys[i]); math.Abs(r-want) > 1e-9 {
+			t.Errorf("expected vertex %d at radius %g, got %g", i, want, r)
+		}
+	}
+
+	if math.Abs(xs[0]) > 1e-9 || math.Abs(ys[0]+10) > 1e-9 {
+		t.Errorf("expected the first point at 0,-10, got %g,%g", xs[0], ys[0])
+	}
+}
@@ -0,0 +1,148 @@
+// Copyright Bjørn Borud 2019 Use of this source code is governed by
+// the license found in the accompanying LICENSE file.
+
+package points
+
+import (
+	"bufio"
+	"fmt"
+	"image"
+	"io"
+	"sort"
+)
+
+// sixelLevels is how many levels each channel is quantized to.  The
+// 6x6x6 color cube this gives fits within the 256 color registers
+// that terminals with sixel support generally have.
+const sixelLevels = 6
+
+// writeSixel draws the dots into a bitmap like writePNG and writes it
+// to w as sixel escape codes, which terminals such as xterm, mlterm
+// and foot show as an image.
+func writeSixel(dots []dot, width int, height int, opts Options, w io.Writer) error {
+	return drawDots(&pngCanvas{w: w, opts: opts, encode: encodeSixel}, dots, width, height, opts)
+}
+
+// encodeSixel writes img as sixels.  The colors are quantized to a
+// color cube of sixelLevels levels per channel and only the colors
+// that are used are defined.  Pixels that are mostly transparent are
+// left out, so the terminal background shows through.
+func encodeSixel(w io.Writer, img image.Image) error {
+	b := img.Bounds()
+	rgba, ok := img.(*image.RGBA)
+	if !ok {
+		return fmt.Errorf("sixel needs an RGBA image, got %T", img)
+	}
+
+	// colorIndex returns the index in the color cube of x, y, or -1
+	// if the pixel is left out.  The pixels are premultiplied, so
+	// they are unpremultiplied first to keep the edges of dots that
+	// are partly covered from getting darker colors.
+	colorIndex := func(x int, y int) int {
+		i := rgba.PixOffset(x, y)
+		pix := rgba.Pix[i : i+4 : i+4]
+		if pix[3] < 0x80 {
+			return -1
+		}
+
+		r, g, b := unpremultiply(uint32(pix[0])*0x101, uint32(pix[1])*0x101, uint32(pix[2])*0x101, uint32(pix[3])*0x101)
+
+		index := 0
+		for _, v := range [3]uint32{r, g, b} {
+			index = index*sixelLevels + (int(v)*(sixelLevels-1)+0x7fff)/0xffff
+		}
+		return index
+	}
+
+	colors := sixelLevels * sixelLevels * sixelLevels
+	indexes := make([]int, b.Dx()*b.Dy())
+	used := make([]bool, colors)
+	for y := 0; y < b.Dy(); y++ {
+		for x := 0; x < b.Dx(); x++ {
+			c := colorIndex(b.Min.X+x, b.Min.Y+y)
+			indexes[y*b.Dx()+x] = c
+			if c >= 0 {
+				used[c] = true
+			}
+		}
+	}
+
+	bw := bufio.NewWriter(w)
+
+	// The second parameter keeps pixels that aren't drawn transparent
+	// and the raster attributes give the size of the image.
+	fmt.Fprintf(bw, "\x1bP0;1q\"1;1;%d;%d", b.Dx(), b.Dy())
+
+	for c, ok := range used {
+		if !ok {
+			continue
+		}
+
+		// Registers take each channel in percent
+		r := c / (sixelLevels * sixelLevels)
+		g := c / sixelLevels % sixelLevels
+		bl := c % sixelLevels
+		fmt.Fprintf(bw, "#%d;2;%d;%d;%d", c, r*100/(sixelLevels-1), g*100/(sixelLevels-1), bl*100/(sixelLevels-1))
+	}
+
+	// Each band of six rows is written once for every color in it,
+	// going back to the start of the band in between.
+	bits := make([][]byte, colors)
+	for top := 0; top < b.Dy(); top += 6 {
+		var inBand []int
+		for y := top; y < minInt(top+6, b.Dy()); y++ {
+			for x := 0; x < b.Dx(); x++ {
+				c := indexes[y*b.Dx()+x]
+				if c < 0 {
+					continue
+				}
+
+				if bits[c] == nil {
+					bits[c] = make([]byte, b.Dx())
+					inBand = append(inBand, c)
+				}
+				bits[c][x] |= 1 << uint(y-top)
+			}
+		}
+
+		sort.Ints(inBand)
+		for i, c := range inBand {
+			if i > 0 {
+				bw.WriteByte('$')
+			}
+			fmt.Fprintf(bw, "#%d", c)
+			writeSixelRow(bw, bits[c])
+			bits[c] = nil
+		}
+		bw.WriteByte('-')
+	}
+
+	bw.WriteString("\x1b\\")
+	return bw.Flush()
+}
+
+// writeSixelRow writes one color of a band, run length encoding
+// repeated sixels.  Trailing columns without the color are left out.
+func writeSixelRow(w *bufio.Writer, row []byte) {
+	end := len(row)
+	for end > 0 && row[end-1] == 0 {
+		end--
+	}
+
+	for x := 0; x < end; {
+		run := 1
+		for x+run < end && row[x+run] == row[x] {
+			run++
+		}
+
+		ch := byte(0x3f + row[x])
+		if run > 3 {
+			fmt.Fprintf(w, "!%d%c", run, ch)
+		} else {
+			for i := 0; i < run; i++ {
+				w.WriteByte(ch)
+			}
+		}
+		x += run
+	}
+}
@@ -0,0 +1,75 @@
+// Copyright Bjørn Borud 2019 Use of this source code is governed by
+// the license found in the accompanying LICENSE file.
+
+package points
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"strings"
+	"testing"
+)
+
+func TestSixel(t *testing.T) {
+	img := image.NewGray(image.Rect(0, 0, 12, 6))
+
+	opts := DefaultOptions()
+	opts.BoxSize = 6
+	opts.Format = FormatSixel
+
+	out, err := RenderBytes(img, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The introducer and raster attributes with the size, and the
+	// string terminator at the end
+	if want := "\x1bP0;1q\"1;1;12;6"; !bytes.HasPrefix(out, []byte(want)) {
+		t.Errorf("expected the output to start with %q, got %q", want, out)
+	}
+	if !bytes.HasSuffix(out, []byte("\x1b\\")) {
+		t.Errorf("expected the output to end with a string terminator, got %q", out)
+	}
+}
+
+func TestEncodeSixel(t *testing.T) {
+	// One red and one transparent pixel on top, a blue pixel below
+	img := image.NewRGBA(image.Rect(0, 0, 2, 2))
+	img.SetRGBA(0, 0, color.RGBA{0xff, 0x00, 0x00, 0xff})
+	img.SetRGBA(1, 1, color.RGBA{0x00, 0x00, 0xff, 0xff})
+
+	var buf bytes.Buffer
+	if err := encodeSixel(&buf, img); err != nil {
+		t.Fatal(err)
+	}
+
+	// Blue is 0,0,5 in the color cube and red 5,0,0.  Each color of
+	// the band gets a row, with one bit per pixel from the top.
+	want := strings.Join([]string{
+		"\x1bP0;1q\"1;1;2;2",
+		"#5;2;0;0;100#180;2;100;0;0",
+		"#5?A$#180@",
+		"-\x1b\\",
+	}, "")
+	if got := buf.String(); got != want {
+		t.Errorf("expected %q, got %q", want, got)
+	}
+
+	// A half transparent red pixel is stored premultiplied, but is
+	// still red rather than dark red
+	img = image.NewRGBA(image.Rect(0, 0, 1, 1))
+	img.Set(0, 0, color.NRGBA{0xff, 0x00, 0x00, 0x99})
+
+	buf.Reset()
+	if err := encodeSixel(&buf, img); err != nil {
+		t.Fatal(err)
+	}
+	if got := buf.String(); !strings.Contains(got, "#180;2;100;0;0") {
+		t.Errorf("expected the pixel to be full red, got %q", got)
+	}
+
+	if err := encodeSixel(&buf, image.NewGray(image.Rect(0, 0, 1, 1))); err == nil {
+		t.Errorf("expected an error for an image that isn't RGBA")
+	}
+}
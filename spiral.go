@@ -0,0 +1,91 @@
+// Copyright Bjørn Borud 2019 Use of this source code is governed by
+// the license found in the accompanying LICENSE file.
+
+package points
+
+import (
+	"image"
+	"math"
+)
+
+// spiralPoints returns the centers of the dots of Spiral, from the
+// middle of the grid outward, along the Archimedean spiral r = b * θ
+// whose turns are opts.SpiralSpacing apart.  The dots are
+// opts.SpiralStep degrees apart, or, if that is zero, one spacing
+// apart along the spiral.  Near the middle a fixed step puts points
+// on top of each other, so those closer than half a spacing to the
+// one before are skipped.  The spiral stops once it is past the
+// corners, and only the points within the image are returned.
+func spiralPoints(g grid, opts Options) [][2]float64 {
+	spacing := opts.SpiralSpacing
+	if spacing == 0 {
+		spacing = float64(g.boxWidth)
+	}
+	b := spacing / (2 * math.Pi)
+
+	width, height := float64(g.width), float64(g.height)
+	cx, cy := width/2, height/2
+	end := math.Hypot(cx, cy) + spacing
+
+	var points [][2]float64
+	lastX, lastY := math.Inf(1), math.Inf(1)
+	for theta := 0.0; b*theta <= end; {
+		r := b * theta
+		sin, cos := math.Sincos(theta)
+		x, y := cx+r*cos, cy+r*sin
+		if x >= 0 && y >= 0 && x < width && y < height && math.Hypot(x-lastX, y-lastY) >= spacing/2 {
+			points = append(points, [2]float64{x, y})
+			lastX, lastY = x, y
+		}
+
+		// Going a distance d along the spiral turns it by about d
+		// over the distance from the middle, where the spiral is
+		// also moving outward by b per radian.
+		if opts.SpiralStep > 0 {
+			theta += opts.SpiralStep * math.Pi / 180
+		} else {
+			theta += spacing / math.Hypot(r, b)
+		}
+	}
+	return points
+}
+
+// computeSpiral computes a dot for each of the spiralPoints, made from
+// the pixels of a box as large as those of the grid around it.  The
+// dots are put right on their points, even where the box is cut short
+// by the edge of the image.  The points are divided up between
+// opts.Workers goroutines and the dots are returned in the order of
+// the spiral.
+func computeSpiral(img image.Image, g grid, opts Options) []dot {
+	points := spiralPoints(g, opts)
+	dots := make([]dot, len(points))
+
+	bounds := image.Rect(0, 0, g.width, g.height)
+	half := minInt(g.boxWidth, g.boxHeight) / 2
+	luma := chooseLuma(opts)
+
+	// Each row of forEachRow is a run of points, so the progress is
+	// still reported in steps.
+	const run = 64
+	scale := canvasScale(opts)
+	forEachRow((len(points)+run-1)/run, opts, func(j int) {
+		for i := j * run; i < minInt((j+1)*run, len(points)); i++ {
+			x, y := int(points[i][0]), int(points[i][1])
+			box := image.Rect(x-g.boxWidth/2, y-g.boxHeight/2, x-g.boxWidth/2+g.boxWidth, y-g.boxHeight/2+g.boxHeight)
+
+			d := makeDot(img, box.Intersect(bounds), half, opts, luma)
+			if !d.visible {
+				continue
+			}
+
+			d.cx = int(math.Round(points[i][0] * scale))
+			d.cy = int(math.Round(points[i][1] * scale))
+			if opts.Precision > 0 {
+				d.cx = snap(float64(d.cx), opts.Precision)
+				d.cy = snap(float64(d.cy), opts.Precision)
+			}
+			dots[i] = d
+		}
+	})
+	return dots
+}
@@ -0,0 +1,92 @@
+// Copyright Bjørn Borud 2019 Use of this source code is governed by
+// the license found in the accompanying LICENSE file.
+
+package points
+
+import (
+	"image"
+	"math"
+	"testing"
+)
+
+func TestSpiralPoints(t *testing.T) {
+	tests := []struct {
+		name    string
+		spacing float64
+		step    float64
+	}{
+		{"box spacing", 0, 0},
+		{"spacing", 15, 0},
+		{"step", 10, 20},
+	}
+
+	for _, test := range tests {
+		opts := DefaultOptions()
+		opts.BoxSize = 10
+		opts.Spiral = true
+		opts.SpiralSpacing = test.spacing
+		opts.SpiralStep = test.step
+
+		g := newGrid(image.Rect(0, 0, 200, 120), opts)
+		points := spiralPoints(g, opts)
+		if len(points) < 50 {
+			t.Fatalf("%s: expected the spiral to fill the image, got %d points", test.name, len(points))
+		}
+
+		spacing := test.spacing
+		if spacing == 0 {
+			spacing = 10
+		}
+		b := spacing / (2 * math.Pi)
+
+		last := -1.0
+		for _, p := range points {
+			if p[0] < 0 || p[1] < 0 || p[0] >= 200 || p[1] >= 120 {
+				t.Errorf("%s: expected the points within the image, got %v", test.name, p)
+			}
+
+			// r = b * θ, where θ is the angle of the point plus a
+			// whole number of turns
+			dx, dy := p[0]-100, p[1]-60
+			r := math.Hypot(dx, dy)
+			angle := math.Atan2(dy, dx)
+			if angle < 0 {
+				angle += 2 * math.Pi
+			}
+			turns := (r/b - angle) / (2 * math.Pi)
+			if r > 1e-6 && math.Abs(turns-math.Round(turns)) > 1e-6 {
+				t.Errorf("%s: expected %v to be on the spiral, it is %v turns in", test.name, p, turns)
+			}
+
+			// The spiral only goes outward
+			if r < last {
+				t.Errorf("%s: expected %v to be further out than %v", test.name, r, last)
+			}
+			last = r
+		}
+	}
+}
+
+func TestSpiralStats(t *testing.T) {
+	img := image.NewGray(image.Rect(0, 0, 200, 120))
+
+	opts := DefaultOptions()
+	opts.BoxSize = 10
+	opts.Spiral = true
+	opts.SpiralSpacing = 7
+
+	st, err := Analyze(img, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Every point of a black image gets a dot.  The points are
+	// closer together than the boxes, so there are more of them.
+	n := len(spiralPoints(newGrid(img.Bounds(), opts), opts))
+	if n <= st.Cols*st.Rows {
+		t.Fatalf("expected more than %d points, got %d", st.Cols*st.Rows, n)
+	}
+	if st.Boxes != n || st.Dots != n || st.Skipped != 0 {
+		t.Errorf("expected %d boxes and dots, got %d boxes, %d dots and %d skipped", n, st.Boxes, st.Dots, st.Skipped)
+	}
+}
@@ -0,0 +1,106 @@
+// Copyright Bjørn Borud 2019 Use of this source code is governed by
+// the license found in the accompanying LICENSE file.
+
+package points
+
+import (
+	"image"
+)
+
+// Stats describes what Render would produce for an image, so the
+// options can be tuned without writing the output.
+type Stats struct {
+	// Cols and Rows are the dimensions of the grid of boxes.
+	Cols int
+	Rows int
+
+	// Boxes is the number of boxes.  With Adaptive this counts the
+	// boxes after they have been split, and with Poisson, Spiral and
+	// Rosette the points that were sampled.
+	Boxes int
+
+	// Dots is the number of dots that would be drawn and Skipped the
+	// number of boxes that get no dot of their own, whatever the
+	// reason, such as the luma threshold, transparency or precision.
+	Dots    int
+	Skipped int
+
+	// MinRadius, MaxRadius and MeanRadius describe the radii of the
+	// dots, in output units.
+	MinRadius  int
+	MaxRadius  int
+	MeanRadius float64
+
+	// Size is the number of bytes the output would take up in the
+	// format given by the options.
+	Size int64
+}
+
+// Analyze computes the dots for an image like Render does, but rather
+// than writing them out it returns statistics about them.
+func Analyze(img image.Image, opts Options) (Stats, error) {
+	r, err := layout(img, opts)
+	if err != nil {
+		return Stats{}, err
+	}
+
+	st := Stats{
+		Cols: r.grid.cols,
+		Rows: r.grid.rows,
+	}
+
+	if opts.Adaptive || opts.Poisson || opts.Spiral || opts.Rosette {
+		st.Boxes = len(r.boxDots)
+	} else {
+		// On a hexagonal grid some of the boxes of the odd rows fall
+		// outside the image.
+		for x := 0; x < r.grid.cols; x++ {
+			for y := 0; y < r.grid.rows; y++ {
+				if _, ok := r.grid.box(x, y); ok {
+					st.Boxes++
+				}
+			}
+		}
+	}
+
+	// The copies of tileable output that wrap around the edges
+	// aren't dots of their own.
+	sum := 0
+	for _, d := range r.boxDots {
+		if !d.visible {
+			continue
+		}
+
+		if st.Dots == 0 || d.radius < st.MinRadius {
+			st.MinRadius = d.radius
+		}
+		st.MaxRadius = maxInt(st.MaxRadius, d.radius)
+		sum += d.radius
+		st.Dots++
+	}
+
+	st.Skipped = st.Boxes - st.Dots
+	if st.Dots > 0 {
+		st.MeanRadius = float64(sum) / float64(st.Dots)
+	}
+
+	// The easiest way to know the size is to write the output
+	// somewhere that just counts the bytes.
+	var cw countingWriter
+	if err := r.write(&cw); err != nil {
+		return Stats{}, err
+	}
+	st.Size = cw.n
+
+	return st, nil
+}
+
+// countingWriter counts the bytes written to it and throws them away.
+type countingWriter struct {
+	n int64
+}
+
+func (w *countingWriter) Write(p []byte) (int, error) {
+	w.n += int64(len(p))
+	return len(p), nil
+}
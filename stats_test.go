@@ -0,0 +1,40 @@
+// Copyright Bjørn Borud 2019 Use of this source code is governed by
+// the license found in the accompanying LICENSE file.
+
+package points
+
+import (
+	"image"
+	"image/color"
+	"image/draw"
+	"testing"
+)
+
+func TestAnalyze(t *testing.T) {
+	// Black, mid gray and white boxes side by side
+	img := image.NewGray(image.Rect(0, 0, 60, 20))
+	draw.Draw(img, image.Rect(20, 0, 40, 20), image.NewUniform(color.Gray{0x80}), image.Point{}, draw.Src)
+	draw.Draw(img, image.Rect(40, 0, 60, 20), image.NewUniform(color.White), image.Point{}, draw.Src)
+
+	for _, format := range []string{FormatSVG, FormatJSON} {
+		opts := DefaultOptions()
+		opts.BoxSize = 20
+		opts.Format = format
+
+		st, err := Analyze(img, opts)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		want := Stats{Cols: 3, Rows: 1, Boxes: 3, Dots: 2, Skipped: 1, MinRadius: 4, MaxRadius: 10, MeanRadius: 7}
+		out, err := RenderBytes(img, opts)
+		if err != nil {
+			t.Fatal(err)
+		}
+		want.Size = int64(len(out))
+
+		if st != want {
+			t.Errorf("%s: expected %+v, got %+v", format, want, st)
+		}
+	}
+}
@@ -0,0 +1,98 @@
+// Copyright Bjørn Borud 2019 Use of this source code is governed by
+// the license found in the accompanying LICENSE file.
+
+package points
+
+import (
+	"image"
+	"image/color"
+)
+
+// stippleCell is the color and darkness of one box of the grid.
+type stippleCell struct {
+	color    color.RGBA
+	darkness float64
+}
+
+// computeStipple computes the dots for stippling.  Rather than giving
+// every box a dot whose size depends on its luma, every dot has the
+// same size and the luma decides whether a box gets one at all.  The
+// darkness of each box is run through Floyd–Steinberg error diffusion
+// so the density of the dots follows the darkness of the image.  The
+// dots are returned in column order like for computeDots.
+func computeStipple(img image.Image, g grid, opts Options) []dot {
+	cells := make([]stippleCell, g.cols*g.rows)
+	luma := chooseLuma(opts)
+
+	forEachRow(g.rows, opts, func(y int) {
+		for x := 0; x < g.cols; x++ {
+			box, ok := g.box(x, y)
+			if !ok {
+				continue
+			}
+			cells[x*g.rows+y] = makeStippleCell(img, box, opts, luma)
+		}
+	})
+
+	// The error diffusion has to visit the boxes in order, so this
+	// part can't be divided between workers.  We only need to hold
+	// on to the error for the current row and the next.
+	cur := make([]float64, g.cols+2)
+	next := make([]float64, g.cols+2)
+
+	half := float64(minInt(g.boxWidth, g.boxHeight)/2) * opts.Scale
+	radius := clampRadius(half, half, opts)
+	dots := make([]dot, len(cells))
+
+	for y := 0; y < g.rows; y++ {
+		for x := 0; x < g.cols; x++ {
+			cell := cells[x*g.rows+y]
+
+			// The error arrays are offset by one so the boxes along
+			// the edges don't need special treatment.
+			v := cell.darkness + cur[x+1]
+			e := v
+			if v >= 0.5 {
+				box, _ := g.box(x, y)
+				dots[x*g.rows+y] = placeDot(img, box, radius, 1.0, cell.color, opts)
+				e = v - 1.0
+			}
+
+			cur[x+2] += e * 7 / 16
+			next[x] += e * 3 / 16
+			next[x+1] += e * 5 / 16
+			next[x+2] += e * 1 / 16
+		}
+
+		cur, next = next, cur
+		for i := range next {
+			next[i] = 0
+		}
+	}
+
+	return dots
+}
+
+// makeStippleCell computes the color and darkness of a box.  Boxes
+// that are transparent or removed by the luma threshold have no
+// darkness.
+func makeStippleCell(img image.Image, box image.Rectangle, opts Options, lumaFunc lumaFunc) stippleCell {
+	c, alpha := boxColor(img, box.Add(img.Bounds().Min), opts)
+	if alpha == 0 || alpha < opts.AlphaCutoff {
+		return stippleCell{}
+	}
+
+	luma := levels(lumaFunc(uint32(c.R), uint32(c.G), uint32(c.B)), opts)
+	c = lumaColor(c, luma, opts)
+
+	darkness := 1.0 - luma
+	if opts.Invert {
+		darkness = luma
+	}
+
+	if skipLuma(luma, opts) {
+		return stippleCell{}
+	}
+
+	return stippleCell{color: c, darkness: darkness}
+}
@@ -0,0 +1,65 @@
+// Copyright Bjørn Borud 2019 Use of this source code is governed by
+// the license found in the accompanying LICENSE file.
+
+package points
+
+import (
+	"image"
+	"image/color"
+	"testing"
+)
+
+func TestStipple(t *testing.T) {
+	// A gradient from black on the left to white on the right
+	img := image.NewGray(image.Rect(0, 0, 160, 160))
+	for x := 0; x < 160; x++ {
+		for y := 0; y < 160; y++ {
+			img.SetGray(x, y, color.Gray{uint8(x * 255 / 159)})
+		}
+	}
+
+	opts := DefaultOptions()
+	opts.BoxSize = 10
+	opts.Stipple = true
+
+	r, err := layout(img, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Count the dots in each quarter of the columns
+	quarters := make([]int, 4)
+	radius := -1
+	for x := 0; x < r.grid.cols; x++ {
+		for y := 0; y < r.grid.rows; y++ {
+			d := r.dots[x*r.grid.rows+y]
+			if !d.visible {
+				continue
+			}
+
+			if radius == -1 {
+				radius = d.radius
+			}
+			if d.radius != radius {
+				t.Errorf("expected all dots to have radius %d, got %d at %d,%d", radius, d.radius, x, y)
+			}
+			quarters[x*4/r.grid.cols]++
+		}
+	}
+
+	if radius != 5 {
+		t.Errorf("expected dots filling the boxes, got radius %d", radius)
+	}
+
+	for i := 1; i < len(quarters); i++ {
+		if quarters[i] >= quarters[i-1] {
+			t.Errorf("expected fewer dots towards white, got %v", quarters)
+			break
+		}
+	}
+
+	// The darkest quarter should be close to filled
+	if max := 4 * r.grid.rows; quarters[0] < max*3/4 {
+		t.Errorf("expected at least %d dots in the darkest quarter, got %d", max*3/4, quarters[0])
+	}
+}
@@ -0,0 +1,82 @@
+// Copyright Bjørn Borud 2019 Use of this source code is governed by
+// the license found in the accompanying LICENSE file.
+
+package points
+
+import (
+	"image"
+	"image/png"
+	"io"
+)
+
+// streamColumns is the number of columns of boxes that are computed
+// and drawn at a time when the dots are streamed.
+const streamColumns = 64
+
+// streamable returns true if the dots can be drawn a band of columns
+// at a time, so only the dots of one band have to be held in memory.
+// That takes the plain grid and a format that draws the dots one by
+// one.  The other layouts, and the options that look at all the dots
+// before any is drawn, need the whole grid.
+func (r rendering) streamable() bool {
+	o := r.opts
+
+	switch o.Format {
+	case "", FormatSVG, FormatPNG, FormatPDF, FormatSixel:
+	default:
+		return false
+	}
+
+	return o.Contour == 0 && !o.Adaptive && !o.Stipple && !o.Poisson && !o.Spiral && !o.Rosette &&
+		o.MergeFlat == 0 && o.Jitter == 0 && !o.Tileable && !o.Soft && !o.CSS
+}
+
+// stream computes the dots a band of columns at a time and draws each
+// band before computing the next, reusing the same dots for every
+// band.  Since the dots are drawn in column order the output is the
+// same as when the whole grid is computed first.  Progress counts the
+// rows of every band, so the total is the number of rows times the
+// number of bands.
+func (r rendering) stream(img image.Image, w io.Writer) error {
+	g, opts := r.grid, r.opts
+
+	var c canvas
+	switch opts.Format {
+	case FormatPNG:
+		c = &pngCanvas{w: w, opts: opts, encode: png.Encode}
+	case FormatPDF:
+		c = &pdfCanvas{w: w, opts: opts}
+	case FormatSixel:
+		c = &pngCanvas{w: w, opts: opts, encode: encodeSixel}
+	default:
+		c = newSVGCanvas(w, opts)
+	}
+
+	bands := (g.cols + streamColumns - 1) / streamColumns
+	band := 0
+	if progress := opts.Progress; progress != nil {
+		opts.Progress = func(done int, total int) {
+			progress(band*g.rows+done, bands*g.rows)
+		}
+	}
+
+	c.start(r.width, r.height, opts.Background)
+
+	buf := make([]dot, minInt(streamColumns, g.cols)*g.rows)
+	for left := 0; left < g.cols; left += streamColumns {
+		right := minInt(left+streamColumns, g.cols)
+
+		dots := buf[:(right-left)*g.rows]
+		for i := range dots {
+			dots[i] = dot{}
+		}
+
+		computeColumns(img, g, left, right, opts, func(x int, y int, d dot) {
+			dots[(x-left)*g.rows+y] = d
+		})
+		drawLayer(c, dots, 0, opts)
+		band++
+	}
+
+	return c.end()
+}
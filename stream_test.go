@@ -0,0 +1,123 @@
+// Copyright Bjørn Borud 2019 Use of this source code is governed by
+// the license found in the accompanying LICENSE file.
+
+package points
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"io/ioutil"
+	"testing"
+	"time"
+)
+
+// gradientImage returns an image whose colors change along both axes.
+func gradientImage(width int, height int) *image.RGBA {
+	img := image.NewRGBA(image.Rect(0, 0, width, height))
+	for y := 0; y < height; y++ {
+		for x := 0; x < width; x++ {
+			img.Set(x, y, color.RGBA{uint8(x), uint8(y), uint8(x ^ y), 0xff})
+		}
+	}
+	return img
+}
+
+func TestStream(t *testing.T) {
+	// 150 columns make three bands, the last one partly filled
+	img := gradientImage(300, 40)
+
+	tests := []struct {
+		name string
+		opts func(o *Options)
+	}{
+		{"svg", func(o *Options) {}},
+		{"png", func(o *Options) { o.Format = FormatPNG }},
+		{"pdf", func(o *Options) { o.Format = FormatPDF }},
+		{"sixel", func(o *Options) { o.Format = FormatSixel }},
+		{"hex", func(o *Options) { o.Hex = true }},
+		{"checker", func(o *Options) { o.Checker = true }},
+		{"orient", func(o *Options) { o.Orient = true; o.Shape = "square" }},
+		{"workers", func(o *Options) { o.Workers = 4 }},
+	}
+
+	for _, test := range tests {
+		opts := DefaultOptions()
+		opts.BoxSize = 2
+		opts.Date = time.Date(2019, 1, 1, 0, 0, 0, 0, time.UTC)
+		test.opts(&opts)
+
+		r, err := layout(img, opts)
+		if err != nil {
+			t.Fatalf("%s: %v", test.name, err)
+		}
+		if !r.streamable() {
+			t.Fatalf("%s: expected the dots to be streamed", test.name)
+		}
+
+		var want bytes.Buffer
+		if err := r.write(&want); err != nil {
+			t.Fatalf("%s: %v", test.name, err)
+		}
+
+		got, err := RenderBytes(img, opts)
+		if err != nil {
+			t.Fatalf("%s: %v", test.name, err)
+		}
+
+		if !bytes.Equal(got, want.Bytes()) {
+			t.Errorf("%s: streamed output differs from the output of the whole grid", test.name)
+		}
+	}
+}
+
+func TestStreamProgress(t *testing.T) {
+	img := gradientImage(300, 40)
+
+	var calls, last, total int
+	opts := DefaultOptions()
+	opts.BoxSize = 2
+	opts.Workers = 4
+	opts.Progress = func(done int, n int) {
+		calls++
+		last, total = done, n
+	}
+
+	if _, err := RenderBytes(img, opts); err != nil {
+		t.Fatal(err)
+	}
+
+	// 20 rows in each of 3 bands
+	if calls != 60 || last != 60 || total != 60 {
+		t.Errorf("expected 60 calls ending at 60 of 60, got %d ending at %d of %d", calls, last, total)
+	}
+}
+
+func BenchmarkStream(b *testing.B) {
+	img := gradientImage(1600, 1200)
+
+	opts := DefaultOptions()
+	opts.BoxSize = 4
+
+	b.Run("grid", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			r, err := layout(img, opts)
+			if err != nil {
+				b.Fatal(err)
+			}
+			if err := r.write(ioutil.Discard); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+
+	b.Run("stream", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			if err := Render(img, opts, ioutil.Discard); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+}
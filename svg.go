@@ -0,0 +1,443 @@
+// Copyright Bjørn Borud 2019 Use of this source code is governed by
+// the license found in the accompanying LICENSE file.
+
+package points
+
+import (
+	"fmt"
+	"image/color"
+	"io"
+	"math"
+	"strconv"
+	"strings"
+
+	svg "github.com/ajstarks/svgo"
+)
+
+// errWriter remembers the first error returned by the underlying
+// writer.  svgo does not report write errors, so this is how we find
+// out that the output went wrong.
+type errWriter struct {
+	w   io.Writer
+	err error
+}
+
+func (e *errWriter) Write(p []byte) (int, error) {
+	if e.err != nil {
+		return 0, e.err
+	}
+
+	n, err := e.w.Write(p)
+	if err != nil {
+		e.err = err
+	}
+	return n, err
+}
+
+// minifyWriter leaves out the newlines, and the indentation after
+// them, that svgo puts between elements and between the attributes of
+// the svg element.  Those within a tag become a single space, and
+// those in text are kept as they are.
+type minifyWriter struct {
+	w     io.Writer
+	inTag bool
+	last  byte
+
+	// pending is the newline and indentation held back until we
+	// know what comes after it.
+	pending []byte
+	buf     []byte
+}
+
+func (m *minifyWriter) Write(p []byte) (int, error) {
+	m.buf = m.buf[:0]
+
+	for _, c := range p {
+		if c == '\n' || (len(m.pending) > 0 && c == ' ') {
+			m.pending = append(m.pending, c)
+			continue
+		}
+
+		if len(m.pending) > 0 {
+			switch {
+			case m.last == '>' && c == '<':
+			case m.inTag:
+				m.buf = append(m.buf, ' ')
+			default:
+				m.buf = append(m.buf, m.pending...)
+			}
+			m.pending = m.pending[:0]
+		}
+
+		switch c {
+		case '<':
+			m.inTag = true
+		case '>':
+			m.inTag = false
+		}
+		m.buf = append(m.buf, c)
+		m.last = c
+	}
+
+	if _, err := m.w.Write(m.buf); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
+
+// newSVGCanvas returns an SVG canvas that writes to w, through a
+// minifyWriter if opts.Minify is set.
+func newSVGCanvas(w io.Writer, opts Options) *svgCanvas {
+	if opts.Minify {
+		w = &minifyWriter{w: w}
+	}
+
+	ew := &errWriter{w: w}
+	return &svgCanvas{svg: svg.New(ew), w: ew, opts: opts}
+}
+
+// writeSVG draws the dots onto an SVG canvas that is written to w.
+// The dots are drawn in the order they are given.
+func writeSVG(dots []dot, width int, height int, opts Options, w io.Writer) error {
+	c := newSVGCanvas(w, opts)
+	if opts.Soft {
+		c.gradients = softColors(dots, opts)
+	}
+	if opts.CSS && opts.Color {
+		c.buckets = cssColors(dots)
+	}
+	return drawDots(c, dots, width, height, opts)
+}
+
+// softBits is the number of bits each channel of the color of a soft
+// dot is quantized to, so dots of about the same color share their
+// gradient.
+const softBits = 4
+
+// softColor returns the quantized color of the gradient of a soft dot
+// of color c.
+func softColor(c color.Color) color.RGBA {
+	const shift = 8 - softBits
+	const levels = 1<<softBits - 1
+
+	rgba := rgbaOf(c)
+	q := func(v uint8) uint8 {
+		return uint8(int(v>>shift) * 0xff / levels)
+	}
+	return color.RGBA{q(rgba.R), q(rgba.G), q(rgba.B), 0xff}
+}
+
+// softID returns the id of the gradient of a soft dot of color c.
+func softID(c color.Color) string {
+	return "soft-" + strings.TrimPrefix(hexColor(softColor(c)), "#")
+}
+
+// softColors returns the gradient colors the visible dots need, in
+// the order they are first used.
+func softColors(dots []dot, opts Options) []color.RGBA {
+	seen := make(map[color.RGBA]bool)
+
+	var colors []color.RGBA
+	for _, d := range dots {
+		if !d.visible {
+			continue
+		}
+
+		c := color.RGBA{A: 0xff}
+		if opts.Color {
+			c = d.color
+		}
+
+		if q := softColor(c); !seen[q] {
+			seen[q] = true
+			colors = append(colors, q)
+		}
+	}
+	return colors
+}
+
+// cssBuckets is the number of brightness buckets the dots are put in
+// by CSS.
+const cssBuckets = 8
+
+// cssBucket returns the brightness bucket of a dot of color c, from 0
+// for the darkest to cssBuckets-1 for the brightest.
+func cssBucket(c color.Color) int {
+	rgba := rgbaOf(c)
+	luma := lumaBT709(uint32(rgba.R), uint32(rgba.G), uint32(rgba.B))
+	return clampInt(int(luma*cssBuckets), 0, cssBuckets-1)
+}
+
+// cssColors returns the average color of the visible dots in each
+// brightness bucket.  Buckets without dots are left transparent.
+func cssColors(dots []dot) []color.RGBA {
+	var sum [cssBuckets][3]int
+	var count [cssBuckets]int
+
+	for _, d := range dots {
+		if !d.visible {
+			continue
+		}
+
+		i := cssBucket(d.color)
+		sum[i][0] += int(d.color.R)
+		sum[i][1] += int(d.color.G)
+		sum[i][2] += int(d.color.B)
+		count[i]++
+	}
+
+	colors := make([]color.RGBA, cssBuckets)
+	for i, n := range count {
+		if n == 0 {
+			continue
+		}
+		colors[i] = color.RGBA{uint8(sum[i][0] / n), uint8(sum[i][1] / n), uint8(sum[i][2] / n), 0xff}
+	}
+	return colors
+}
+
+// cssRules returns the rules of the style sheet of CSS, which give
+// each bucket in use its color, or all the dots black in black mode.
+func cssRules(buckets []color.RGBA, opts Options) []string {
+	property := "fill"
+	if opts.Shape == ShapeLine || opts.Concentric > 0 {
+		property = "stroke"
+	}
+
+	if !opts.Color {
+		return []string{fmt.Sprintf(".dot{%s:black}", property)}
+	}
+
+	var rules []string
+	for i, c := range buckets {
+		if c.A == 0 {
+			continue
+		}
+		rules = append(rules, fmt.Sprintf(".b%d{%s:%s}", i, property, hexColor(c)))
+	}
+	return rules
+}
+
+// svgCanvas draws onto an SVG.
+type svgCanvas struct {
+	svg  *svg.SVG
+	w    *errWriter
+	opts Options
+
+	// Set while a layer group is open
+	layered bool
+
+	// The colors of the gradients of soft dots
+	gradients []color.RGBA
+
+	// The colors of the brightness buckets of CSS
+	buckets []color.RGBA
+}
+
+func (s *svgCanvas) start(width int, height int, background color.Color) {
+	switch {
+	case s.opts.Responsive:
+		// Without a width and height the SVG scales to fit whatever
+		// it is put in.
+		s.svg.Startraw(fmt.Sprintf(`viewBox="0 0 %d %d"`, width, height))
+
+	case s.opts.DPI > 0:
+		// The width and height give the size on paper while the
+		// viewBox keeps everything else in pixels.
+		unit := s.opts.Unit
+		if unit == "" {
+			unit = UnitMillimeter
+		}
+		s.svg.Startraw(
+			fmt.Sprintf(`width="%s%s"`, physicalSize(width, s.opts.DPI, unit), unit),
+			fmt.Sprintf(`height="%s%s"`, physicalSize(height, s.opts.DPI, unit), unit),
+			fmt.Sprintf(`viewBox="0 0 %d %d"`, width, height))
+
+	default:
+		s.svg.Start(width, height)
+	}
+
+	if s.opts.Title != "" {
+		s.svg.Title(s.opts.Title)
+	}
+
+	if s.opts.Description != "" {
+		s.svg.Desc(s.opts.Description)
+	}
+
+	if s.opts.Comment != "" {
+		// A comment can't contain two dashes in a row
+		fmt.Fprintf(s.w, "<!-- %s -->\n", strings.Replace(s.opts.Comment, "--", "- -", -1))
+	}
+
+	if background != nil {
+		s.svg.Rect(0, 0, width, height, "fill:"+hexColor(background))
+	}
+
+	// Soft dots fade from their color in the middle to transparent
+	// at the edge.
+	if len(s.gradients) > 0 {
+		s.svg.Def()
+		for _, c := range s.gradients {
+			hex := hexColor(c)
+			s.svg.RadialGradient(softID(c), 50, 50, 50, 50, 50, []svg.Offcolor{{Offset: 0, Color: hex, Opacity: 1}, {Offset: 100, Color: hex, Opacity: 0}})
+		}
+		s.svg.DefEnd()
+	}
+
+	if s.opts.CSS {
+		s.svg.Style("text/css", cssRules(s.buckets, s.opts)...)
+	}
+
+	// Put everything the dots have in common on a group around them
+	// rather than repeating it on every element.
+	s.svg.Group(groupStyle(s.opts))
+}
+
+func (s *svgCanvas) circle(cx float64, cy float64, r float64, c color.Color) {
+	s.svg.Circle(round(cx), round(cy), round(r), s.style("fill", c)...)
+}
+
+func (s *svgCanvas) rect(x float64, y float64, w float64, h float64, c color.Color) {
+	s.svg.Rect(round(x), round(y), round(w), round(h), s.style("fill", c)...)
+}
+
+func (s *svgCanvas) polygon(cx float64, cy float64, xs []float64, ys []float64, c color.Color) {
+	px, py := toPoints(round(cx), round(cy), xs, ys)
+	s.svg.Polygon(px, py, s.style("fill", c)...)
+}
+
+func (s *svgCanvas) line(x1 float64, y1 float64, x2 float64, y2 float64, c color.Color) {
+	s.svg.Line(round(x1), round(y1), round(x2), round(y2), s.style("stroke", c)...)
+}
+
+func (s *svgCanvas) ring(cx float64, cy float64, r float64, c color.Color) {
+	s.svg.Circle(round(cx), round(cy), round(r), s.style("stroke", c)...)
+}
+
+func (s *svgCanvas) rotate(angle float64, cx float64, cy float64) {
+	s.svg.Gtransform(fmt.Sprintf("rotate(%.1f %d %d)", angle*180/math.Pi, round(cx), round(cy)))
+}
+
+func (s *svgCanvas) unrotate() {
+	s.svg.Gend()
+}
+
+func (s *svgCanvas) layer(name string) {
+	if s.layered {
+		s.svg.Gend()
+	}
+
+	// Each layer is a group of its own that is multiplied with what
+	// is under it, so the inks darken each other.
+	s.svg.Group(fmt.Sprintf(`id="%s"`, name), `style="mix-blend-mode:multiply"`)
+	s.layered = true
+}
+
+func (s *svgCanvas) end() error {
+	if s.layered {
+		s.svg.Gend()
+	}
+	s.svg.Gend()
+	s.svg.End()
+
+	if s.w.err != nil {
+		return fmt.Errorf("error writing svg: %v", s.w.err)
+	}
+	return nil
+}
+
+// style returns the style setting property, and its opacity, to c.
+// If c has a CMYK version it is given too.  Soft dots are filled with
+// the gradient of their color instead.
+// In black mode the color is set on the group, so unless the dot is
+// transparent this returns no style at all since svgo writes an empty
+// style attribute if given an empty string.  With CSS the dot gets
+// its classes instead, and only its opacity is left in the style.
+func (s *svgCanvas) style(property string, c color.Color) []string {
+	if s.opts.CSS {
+		class := `class="dot"`
+		if s.opts.Color {
+			class = fmt.Sprintf(`class="dot b%d"`, cssBucket(c))
+		}
+
+		attrs := []string{class}
+		if a := rgbaOf(c).A; a < 0xff {
+			attrs = append(attrs, fmt.Sprintf("%s-opacity:%.3g", property, float64(a)/0xff))
+		}
+		return attrs
+	}
+
+	var style []string
+	switch {
+	case s.opts.Soft && property == "fill":
+		style = append(style, fmt.Sprintf("fill:url(#%s)", softID(c)))
+	case s.opts.Color:
+		style = append(style, property+":"+hexColor(c))
+	}
+
+	// Viewers that don't know device-cmyk skip it and keep the RGB
+	// color that comes before it.
+	if cc, ok := c.(cmykColor); ok && !s.opts.Soft {
+		style = append(style, property+":"+deviceCMYK(cc.cmyk))
+	}
+
+	if a := rgbaOf(c).A; a < 0xff {
+		style = append(style, fmt.Sprintf("%s-opacity:%.3g", property, float64(a)/0xff))
+	}
+
+	if style == nil {
+		return nil
+	}
+	return []string{strings.Join(style, ";")}
+}
+
+// physicalSize returns how long pixels are at dpi in unit, rounded
+// off to a thousandth.
+func physicalSize(pixels int, dpi float64, unit string) string {
+	size := float64(pixels) / dpi
+	if unit == UnitMillimeter {
+		size *= 25.4
+	}
+	return strconv.FormatFloat(math.Round(size*1000)/1000, 'f', -1, 64)
+}
+
+// toPoints moves the vertices to cx, cy and rounds them off so they
+// can be passed to svgo.
+func toPoints(cx int, cy int, xs []float64, ys []float64) ([]int, []int) {
+	px := make([]int, len(xs))
+	py := make([]int, len(ys))
+
+	for i := range xs {
+		px[i] = cx + round(xs[i])
+		py[i] = cy + round(ys[i])
+	}
+
+	return px, py
+}
+
+// round rounds v off to the nearest integer.
+func round(v float64) int {
+	return int(math.Round(v))
+}
+
+// groupStyle returns the style shared by all the dots.
+func groupStyle(opts Options) string {
+	if opts.Shape == ShapeLine || opts.Concentric > 0 || opts.Contour > 0 {
+		style := fmt.Sprintf("fill:none;stroke-width:%g", opts.LineWidth)
+		if !opts.Color && !opts.CSS {
+			style += ";stroke:black"
+		}
+		return style
+	}
+
+	stroke := "stroke:none"
+	if opts.Stroke != nil {
+		stroke = fmt.Sprintf("stroke:%s;stroke-width:%g", hexColor(opts.Stroke), opts.StrokeWidth)
+	}
+
+	if !opts.Color && !opts.CSS {
+		return "fill:black;" + stroke
+	}
+	return stroke
+}
@@ -0,0 +1,498 @@
+// Copyright Bjørn Borud 2019 Use of this source code is governed by
+// the license found in the accompanying LICENSE file.
+
+package points
+
+import (
+	"bytes"
+	"encoding/xml"
+	"image"
+	"image/color"
+	"image/draw"
+	"io"
+	"regexp"
+	"strings"
+	"testing"
+)
+
+func TestBackground(t *testing.T) {
+	tests := []struct {
+		name       string
+		scale      float64
+		background color.Color
+		want       string
+	}{
+		{"none", 1, nil, ""},
+		{"unscaled", 1, color.RGBA{0xff, 0xee, 0xdd, 0xff}, `<rect x="0" y="0" width="40" height="20" style="fill:#ffeedd" />`},
+		{"scaled", 2, color.RGBA{0xff, 0xee, 0xdd, 0xff}, `<rect x="0" y="0" width="80" height="40" style="fill:#ffeedd" />`},
+	}
+
+	img := image.NewGray(image.Rect(0, 0, 40, 20))
+
+	for _, test := range tests {
+		opts := DefaultOptions()
+		opts.BoxSize = 20
+		opts.Scale = test.scale
+		opts.Background = test.background
+
+		out, err := RenderBytes(img, opts)
+		if err != nil {
+			t.Fatalf("%s: %v", test.name, err)
+		}
+
+		s := string(out)
+		if test.want == "" {
+			if strings.Contains(s, "<rect") {
+				t.Errorf("%s: expected no background, got %s", test.name, s)
+			}
+			continue
+		}
+
+		// The background comes first so the dots are drawn on top
+		// of it
+		if i := strings.Index(s, test.want); i < 0 || i > strings.Index(s, "<g") {
+			t.Errorf("%s: expected %s before the dots, got %s", test.name, test.want, s)
+		}
+	}
+}
+
+func TestOrient(t *testing.T) {
+	tests := []struct {
+		shape string
+		want  string
+	}{
+		{ShapeSquare, `<g transform="rotate(90.0 10 10)">`},
+		{ShapeTriangle, `<g transform="rotate(90.0 10 10)">`},
+		{ShapeCircle, ""},
+	}
+
+	// Gray at the top and white at the bottom, so the gradient points
+	// down
+	img := image.NewGray(image.Rect(0, 0, 20, 20))
+	for x := 0; x < 20; x++ {
+		for y := 0; y < 20; y++ {
+			img.SetGray(x, y, color.Gray{uint8(100 + 155*(y/10))})
+		}
+	}
+
+	for _, test := range tests {
+		opts := DefaultOptions()
+		opts.BoxSize = 20
+		opts.Shape = test.shape
+		opts.Orient = true
+
+		out, err := RenderBytes(img, opts)
+		if err != nil {
+			t.Fatalf("%s: %v", test.shape, err)
+		}
+
+		s := string(out)
+		switch {
+		case test.want == "" && strings.Contains(s, "rotate("):
+			t.Errorf("%s: expected no rotation, got %s", test.shape, s)
+		case !strings.Contains(s, test.want):
+			t.Errorf("%s: expected %s, got %s", test.shape, test.want, s)
+		}
+	}
+}
+
+func TestLines(t *testing.T) {
+	tests := []struct {
+		name  string
+		gray  uint8
+		width float64
+		want  []string
+	}{
+		{"black", 0, 2, []string{`<g style="fill:none;stroke-width:2" >`, `<line x1="0" y1="10" x2="20" y2="10" style="stroke:#000000" />`}},
+		{"gray", 0x80, 3, []string{`<g style="fill:none;stroke-width:3" >`, `<line x1="6" y1="10" x2="14" y2="10" style="stroke:#808080" />`}},
+	}
+
+	for _, test := range tests {
+		img := image.NewGray(image.Rect(0, 0, 20, 20))
+		draw.Draw(img, img.Bounds(), image.NewUniform(color.Gray{test.gray}), image.Point{}, draw.Src)
+
+		opts := DefaultOptions()
+		opts.BoxSize = 20
+		opts.Shape = ShapeLine
+		opts.LineWidth = test.width
+
+		out, err := RenderBytes(img, opts)
+		if err != nil {
+			t.Fatalf("%s: %v", test.name, err)
+		}
+
+		for _, want := range test.want {
+			if !strings.Contains(string(out), want) {
+				t.Errorf("%s: expected %s, got %s", test.name, want, out)
+			}
+		}
+	}
+}
+
+func TestBlackGroupStyle(t *testing.T) {
+	img := image.NewGray(image.Rect(0, 0, 80, 80))
+
+	opts := DefaultOptions()
+	opts.BoxSize = 20
+	opts.Color = false
+
+	out, err := RenderBytes(img, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The style is set once on the group around the dots rather than
+	// on each of them
+	s := string(out)
+	if n := strings.Count(s, "<circle"); n != 16 {
+		t.Errorf("expected 16 circles, got %d", n)
+	}
+	if n := strings.Count(s, "fill:black"); n != 1 {
+		t.Errorf("expected the fill once, got it %d times in %s", n, s)
+	}
+	if n := strings.Count(s, "style="); n != 1 {
+		t.Errorf("expected a single style attribute, got %d in %s", n, s)
+	}
+}
+
+// svgTag returns the opening svg element of out.
+func svgTag(t *testing.T, out []byte) string {
+	s := string(out)
+	i := strings.Index(s, "<svg")
+	if i < 0 {
+		t.Fatalf("expected an svg element, got %s", s)
+	}
+	return s[i : i+strings.Index(s[i:], ">")+1]
+}
+
+func TestResponsive(t *testing.T) {
+	img := image.NewGray(image.Rect(0, 0, 40, 20))
+
+	opts := DefaultOptions()
+	opts.BoxSize = 20
+	opts.Responsive = true
+
+	out, err := RenderBytes(img, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tag := svgTag(t, out)
+	if !strings.Contains(tag, `viewBox="0 0 40 20"`) {
+		t.Errorf("expected a viewBox, got %s", tag)
+	}
+	if strings.Contains(tag, "width=") || strings.Contains(tag, "height=") {
+		t.Errorf("expected no fixed width or height, got %s", tag)
+	}
+
+	// Without the option the size is fixed
+	opts.Responsive = false
+	if out, err = RenderBytes(img, opts); err != nil {
+		t.Fatal(err)
+	}
+	if tag := svgTag(t, out); !strings.Contains(tag, `width="40" height="20"`) {
+		t.Errorf("expected a fixed width and height, got %s", tag)
+	}
+}
+
+func TestTitle(t *testing.T) {
+	img := image.NewGray(image.Rect(0, 0, 40, 20))
+
+	opts := DefaultOptions()
+	opts.BoxSize = 20
+	opts.Title = "cats & dogs.png"
+	opts.Description = "cats & dogs.png drawn as dots"
+	opts.Comment = "source: cats--dogs.png"
+
+	out, err := RenderBytes(img, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	s := string(out)
+	for _, want := range []string{
+		"<title>cats &amp; dogs.png</title>",
+		"<desc>cats &amp; dogs.png drawn as dots</desc>",
+		"<!-- source: cats- -dogs.png -->",
+	} {
+		if !strings.Contains(s, want) {
+			t.Errorf("expected %s, got %s", want, s)
+		}
+	}
+
+	// The title comes right after the svg element
+	if i, j := strings.Index(s, "<svg"), strings.Index(s, "<title>"); j < i {
+		t.Errorf("expected the title after the svg element, got %s", s)
+	}
+}
+
+func TestOpacity(t *testing.T) {
+	// Black and mid gray boxes
+	img := image.NewGray(image.Rect(0, 0, 40, 20))
+	draw.Draw(img, image.Rect(20, 0, 40, 20), image.NewUniform(color.Gray{0x80}), image.Point{}, draw.Src)
+
+	tests := []struct {
+		opacity float64
+		want    string
+	}{
+		{0, `<circle cx="30" cy="10" r="4" style="fill:#808080" />`},
+		{1, `<circle cx="30" cy="10" r="4" style="fill:#808080;fill-opacity:0.498" />`},
+		{2, `<circle cx="30" cy="10" r="4" style="fill:#808080;fill-opacity:0.247" />`},
+	}
+
+	for _, test := range tests {
+		opts := DefaultOptions()
+		opts.BoxSize = 20
+		opts.Opacity = test.opacity
+
+		out, err := RenderBytes(img, opts)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		s := string(out)
+		if !strings.Contains(s, test.want) {
+			t.Errorf("opacity %g: expected %s, got %s", test.opacity, test.want, s)
+		}
+
+		// The black box stays opaque
+		if want := `<circle cx="10" cy="10" r="10" style="fill:#000000" />`; !strings.Contains(s, want) {
+			t.Errorf("opacity %g: expected %s, got %s", test.opacity, want, s)
+		}
+	}
+}
+
+func TestDPI(t *testing.T) {
+	img := image.NewGray(image.Rect(0, 0, 300, 150))
+
+	tests := []struct {
+		unit string
+		want []string
+	}{
+		{UnitInch, []string{`width="1in"`, `height="0.5in"`}},
+		{UnitMillimeter, []string{`width="25.4mm"`, `height="12.7mm"`}},
+		{"", []string{`width="25.4mm"`, `height="12.7mm"`}},
+	}
+
+	for _, test := range tests {
+		opts := DefaultOptions()
+		opts.DPI = 300
+		opts.Unit = test.unit
+
+		out, err := RenderBytes(img, opts)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		// The coordinates stay in pixels
+		tag := svgTag(t, out)
+		for _, want := range append(test.want, `viewBox="0 0 300 150"`) {
+			if !strings.Contains(tag, want) {
+				t.Errorf("%q: expected %s, got %s", test.unit, want, tag)
+			}
+		}
+	}
+}
+
+func TestStroke(t *testing.T) {
+	img := image.NewGray(image.Rect(0, 0, 40, 20))
+
+	tests := []struct {
+		name   string
+		stroke color.Color
+		width  float64
+		color  bool
+		want   string
+	}{
+		{"none", nil, 0, true, `<g style="stroke:none" >`},
+		{"color", color.RGBA{0xff, 0x00, 0x80, 0xff}, 1.5, true, `<g style="stroke:#ff0080;stroke-width:1.5" >`},
+		{"black dots", color.RGBA{0x00, 0x80, 0x00, 0xff}, 2, false, `<g style="fill:black;stroke:#008000;stroke-width:2" >`},
+	}
+
+	for _, test := range tests {
+		opts := DefaultOptions()
+		opts.BoxSize = 20
+		opts.Stroke = test.stroke
+		opts.StrokeWidth = test.width
+		opts.Color = test.color
+
+		out, err := RenderBytes(img, opts)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if !strings.Contains(string(out), test.want) {
+			t.Errorf("%s: expected %s, got %s", test.name, test.want, out)
+		}
+	}
+}
+
+func TestMinify(t *testing.T) {
+	img := gradientImage(100, 60)
+
+	opts := DefaultOptions()
+	opts.BoxSize = 10
+	opts.Title = "a gradient"
+
+	full, err := RenderBytes(img, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	opts.Minify = true
+	out, err := RenderBytes(img, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if bytes.Contains(out, []byte("\n")) {
+		t.Errorf("expected no newlines, got %s", out)
+	}
+	if !bytes.Contains(out, []byte("/><circle")) {
+		t.Errorf("expected the circles to follow each other directly, got %s", out)
+	}
+
+	// The minified output has to be the same XML, just shorter
+	circles := 0
+	dec := xml.NewDecoder(bytes.NewReader(out))
+	for {
+		tok, err := dec.Token()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatalf("expected valid XML: %v", err)
+		}
+		if el, ok := tok.(xml.StartElement); ok && el.Name.Local == "circle" {
+			circles++
+		}
+	}
+
+	if want := bytes.Count(full, []byte("<circle")); circles != want || circles == 0 {
+		t.Errorf("expected %d circles, got %d", want, circles)
+	}
+	if len(out) >= len(full) {
+		t.Errorf("expected the minified output to be shorter than %d bytes, got %d", len(full), len(out))
+	}
+}
+
+func TestMinifyWriter(t *testing.T) {
+	in := "<svg\n   width=\"10\">\n<title>two\nlines</title>\n  <circle/>\n<circle/>\n</svg>\n"
+	want := "<svg width=\"10\"><title>two\nlines</title><circle/><circle/></svg>"
+
+	// The newlines can come at the end of one write and the element
+	// at the start of the next, so write a byte at a time as well
+	for _, size := range []int{len(in), 1} {
+		var buf bytes.Buffer
+		m := &minifyWriter{w: &buf}
+		for i := 0; i < len(in); i += size {
+			if _, err := m.Write([]byte(in[i:minInt(i+size, len(in))])); err != nil {
+				t.Fatal(err)
+			}
+		}
+
+		if buf.String() != want {
+			t.Errorf("%d byte writes: expected %q, got %q", size, want, buf.String())
+		}
+	}
+}
+
+func TestSoft(t *testing.T) {
+	// Black, dark red and a red so close to it that they share a
+	// gradient
+	img := image.NewRGBA(image.Rect(0, 0, 60, 20))
+	draw.Draw(img, image.Rect(0, 0, 20, 20), image.NewUniform(color.Black), image.Point{}, draw.Src)
+	draw.Draw(img, image.Rect(20, 0, 40, 20), image.NewUniform(color.RGBA{0xc0, 0x20, 0x20, 0xff}), image.Point{}, draw.Src)
+	draw.Draw(img, image.Rect(40, 0, 60, 20), image.NewUniform(color.RGBA{0xc1, 0x21, 0x20, 0xff}), image.Point{}, draw.Src)
+
+	opts := DefaultOptions()
+	opts.BoxSize = 20
+	opts.Soft = true
+
+	out, err := RenderBytes(img, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := string(out)
+
+	defs, circle := strings.Index(s, "<defs>"), strings.Index(s, "<circle")
+	if defs < 0 || circle < 0 || defs > circle {
+		t.Fatalf("expected a defs block before the circles, got %s", s)
+	}
+
+	ids := map[string]bool{}
+	for _, m := range regexp.MustCompile(`<radialGradient id="([^"]+)"`).FindAllStringSubmatch(s, -1) {
+		ids[m[1]] = true
+	}
+	if len(ids) != 2 {
+		t.Errorf("expected 2 gradients, got %v", ids)
+	}
+
+	fills := regexp.MustCompile(`<circle[^>]*fill:url\(#([^)]+)\)`).FindAllStringSubmatch(s, -1)
+	if len(fills) != 3 {
+		t.Fatalf("expected 3 circles filled with a gradient, got %d in %s", len(fills), s)
+	}
+	for _, m := range fills {
+		if !ids[m[1]] {
+			t.Errorf("expected the circle to use one of the gradients %v, got %s", ids, m[1])
+		}
+	}
+	if fills[1][1] != fills[2][1] {
+		t.Errorf("expected the two reds to share a gradient, got %s and %s", fills[1][1], fills[2][1])
+	}
+}
+
+func TestCSS(t *testing.T) {
+	// Black, dark red and a light gray, which are in different
+	// brightness buckets
+	img := image.NewRGBA(image.Rect(0, 0, 60, 20))
+	draw.Draw(img, image.Rect(0, 0, 20, 20), image.NewUniform(color.Black), image.Point{}, draw.Src)
+	draw.Draw(img, image.Rect(20, 0, 40, 20), image.NewUniform(color.RGBA{0xc0, 0x20, 0x20, 0xff}), image.Point{}, draw.Src)
+	draw.Draw(img, image.Rect(40, 0, 60, 20), image.NewUniform(color.RGBA{0x90, 0x90, 0x90, 0xff}), image.Point{}, draw.Src)
+
+	tests := []struct {
+		name    string
+		color   bool
+		classes []string
+		rules   []string
+	}{
+		{"color", true, []string{"dot b0", "dot b2", "dot b4"}, []string{".b0{fill:#000000}", ".b2{fill:#c02020}", ".b4{fill:#909090}"}},
+		{"black", false, []string{"dot", "dot", "dot"}, []string{".dot{fill:black}"}},
+	}
+
+	for _, test := range tests {
+		opts := DefaultOptions()
+		opts.BoxSize = 20
+		opts.CSS = true
+		opts.Color = test.color
+
+		out, err := RenderBytes(img, opts)
+		if err != nil {
+			t.Fatal(err)
+		}
+		s := string(out)
+
+		style, end := strings.Index(s, "<style"), strings.Index(s, "</style>")
+		if style < 0 || end < style || end > strings.Index(s, "<circle") {
+			t.Fatalf("%s: expected a style block before the circles, got %s", test.name, s)
+		}
+		for _, rule := range test.rules {
+			if !strings.Contains(s[style:end], rule) {
+				t.Errorf("%s: expected the rule %s in the style block, got %s", test.name, rule, s[style:end])
+			}
+		}
+
+		var classes []string
+		for _, m := range regexp.MustCompile(`<circle[^>]*>`).FindAllString(s, -1) {
+			if strings.Contains(m, "fill:") {
+				t.Errorf("%s: expected no inline fill, got %s", test.name, m)
+			}
+			if c := regexp.MustCompile(`class="([^"]*)"`).FindStringSubmatch(m); c != nil {
+				classes = append(classes, c[1])
+			}
+		}
+		if strings.Join(classes, ",") != strings.Join(test.classes, ",") {
+			t.Errorf("%s: expected the classes %q, got %q", test.name, test.classes, classes)
+		}
+	}
+}
@@ -0,0 +1,125 @@
+// Copyright Bjørn Borud 2019 Use of this source code is governed by
+// the license found in the accompanying LICENSE file.
+
+package points
+
+import (
+	"image"
+	"sort"
+)
+
+// autoThreshold picks the luma threshold for the image.  It makes a
+// first pass over the boxes of the grid that aren't masked out to
+// find their lumas, and then either uses Otsu's method to split them
+// into the boxes that get dots and those that don't, or, if
+// opts.Coverage is set, picks the threshold that gives that fraction
+// of the boxes a dot.
+func autoThreshold(img image.Image, g grid, opts Options) float64 {
+	luma := chooseLuma(opts)
+	values := make([]float64, g.cols*g.rows)
+	valid := make([]bool, len(values))
+
+	forEachRow(g.rows, opts, func(y int) {
+		for x := 0; x < g.cols; x++ {
+			box, ok := g.box(x, y)
+			if !ok || masked(img, box, opts) {
+				continue
+			}
+
+			c, alpha := boxColor(img, box.Add(img.Bounds().Min), opts)
+			if alpha == 0 || alpha < opts.AlphaCutoff {
+				continue
+			}
+
+			// The threshold works on 1.0 - luma when inverted, so
+			// we give it what it works on and it is the same problem
+			// either way: the boxes below the threshold get dots.
+			v := levels(luma(uint32(c.R), uint32(c.G), uint32(c.B)), opts)
+			if opts.Invert {
+				v = 1.0 - v
+			}
+
+			values[x*g.rows+y] = v
+			valid[x*g.rows+y] = true
+		}
+	})
+
+	var lumas []float64
+	for i, v := range values {
+		if valid[i] {
+			lumas = append(lumas, v)
+		}
+	}
+
+	if len(lumas) == 0 {
+		return opts.LumaThreshold
+	}
+
+	if opts.Coverage > 0 {
+		return coverageThreshold(lumas, opts.Coverage)
+	}
+
+	var hist [256]int
+	for _, v := range lumas {
+		hist[clampInt(int(v*255+0.5), 0, 255)]++
+	}
+	return otsuThreshold(hist)
+}
+
+// coverageThreshold returns the threshold that puts the given
+// fraction of the values below it.
+func coverageThreshold(values []float64, coverage float64) float64 {
+	sort.Float64s(values)
+
+	n := int(coverage*float64(len(values)) + 0.5)
+	if n >= len(values) {
+		return 1.0
+	}
+	if n == 0 {
+		return 0.0
+	}
+
+	// Halfway between the last value below and the first above, so
+	// rounding doesn't flip the boxes on either side.
+	return (values[n-1] + values[n]) / 2
+}
+
+// otsuThreshold uses Otsu's method to find the threshold that best
+// splits the histogram in two, which is the one where the variance
+// between the two classes is the largest.  The histogram has 256
+// buckets from 0.0 to 1.0 and the values below the threshold that is
+// returned are in the lower class.
+func otsuThreshold(hist [256]int) float64 {
+	var total, sum float64
+	for i, n := range hist {
+		total += float64(n)
+		sum += float64(i * n)
+	}
+
+	var best float64
+	var bestVariance float64
+	var lowCount, lowSum float64
+
+	for i, n := range hist {
+		lowCount += float64(n)
+		lowSum += float64(i * n)
+
+		highCount := total - lowCount
+		if lowCount == 0 || highCount == 0 {
+			continue
+		}
+
+		lowMean := lowSum / lowCount
+		highMean := (sum - lowSum) / highCount
+		variance := lowCount * highCount * (lowMean - highMean) * (lowMean - highMean)
+
+		if variance > bestVariance {
+			bestVariance = variance
+			best = float64(i)
+		}
+	}
+
+	// Everything up to and including bucket best is in the lower
+	// class, so the threshold goes just above it.
+	return (best + 0.5) / 255
+}
@@ -0,0 +1,48 @@
+// Copyright Bjørn Borud 2019 Use of this source code is governed by
+// the license found in the accompanying LICENSE file.
+
+package points
+
+import (
+	"math"
+	"testing"
+)
+
+func TestOtsuThreshold(t *testing.T) {
+	// Two humps, around 50 and around 200
+	var hist [256]int
+	for i, n := range []int{1, 2, 3, 4, 5, 6, 5, 4, 3, 2, 1} {
+		hist[45+i] = n
+		hist[195+i] = 3 * n
+	}
+
+	got := otsuThreshold(hist)
+	if got <= 55.0/255 || got >= 195.0/255 {
+		t.Errorf("expected a threshold between the humps, got %.3f", got)
+	}
+
+	// Everything of the lower hump is below the threshold
+	if want := 55.5 / 255; math.Abs(got-want) > 1e-9 {
+		t.Errorf("expected %.3f, got %.3f", want, got)
+	}
+}
+
+func TestCoverageThreshold(t *testing.T) {
+	values := []float64{0.9, 0.1, 0.5, 0.3, 0.7}
+
+	tests := []struct {
+		coverage float64
+		want     float64
+	}{
+		{0.01, 0.0},
+		{0.4, 0.4},
+		{0.6, 0.6},
+		{1.0, 1.0},
+	}
+
+	for _, test := range tests {
+		if got := coverageThreshold(values, test.coverage); math.Abs(got-test.want) > 1e-9 {
+			t.Errorf("coverage %g: expected %g, got %g", test.coverage, test.want, got)
+		}
+	}
+}
@@ -0,0 +1,90 @@
+// Copyright Bjørn Borud 2019 Use of this source code is governed by
+// the license found in the accompanying LICENSE file.
+
+package points
+
+import (
+	"image"
+	"image/color"
+	"math"
+)
+
+// wrappedImage repeats an image in every direction, so pixels beyond
+// one edge are those along the opposite edge.  This is what lets the
+// boxes along the right and bottom edges run past the image when
+// making tileable output.
+type wrappedImage struct {
+	image.Image
+}
+
+func (w wrappedImage) At(x int, y int) color.Color {
+	b := w.Bounds()
+	return w.Image.At(b.Min.X+wrapInt(x-b.Min.X, b.Dx()), b.Min.Y+wrapInt(y-b.Min.Y, b.Dy()))
+}
+
+// wrapDots moves dots whose center is outside the canvas back onto it
+// from the opposite side, and adds a copy on the opposite side of
+// every dot that reaches over an edge, so the output can be tiled
+// without seams.
+func wrapDots(dots []dot, width int, height int, opts Options) []dot {
+	wrapped := make([]dot, 0, len(dots))
+
+	for _, d := range dots {
+		if !d.visible {
+			wrapped = append(wrapped, d)
+			continue
+		}
+
+		d.cx = wrapInt(d.cx, width)
+		d.cy = wrapInt(d.cy, height)
+
+		// Rotated shapes, lines, rings and strokes may reach a
+		// little further than the radius.
+		r := float64(d.radius)
+		if d.angle != 0 {
+			r *= math.Sqrt2
+		}
+		if opts.Shape == ShapeLine || opts.Concentric > 0 {
+			r += opts.LineWidth / 2
+		}
+		if opts.Stroke != nil {
+			r += opts.StrokeWidth / 2
+		}
+		reach := int(math.Ceil(r))
+
+		for _, dx := range wrapShifts(d.cx, reach, width) {
+			for _, dy := range wrapShifts(d.cy, reach, height) {
+				c := d
+				c.cx += dx
+				c.cy += dy
+				wrapped = append(wrapped, c)
+			}
+		}
+	}
+
+	return wrapped
+}
+
+// wrapShifts returns the offsets at which a dot centered on v, that
+// reaches reach in either direction, has to be drawn along an axis
+// that is size long.  Zero is always included.
+func wrapShifts(v int, reach int, size int) []int {
+	shifts := []int{0}
+	if v-reach < 0 {
+		shifts = append(shifts, size)
+	}
+	if v+reach > size {
+		shifts = append(shifts, -size)
+	}
+	return shifts
+}
+
+// wrapInt returns v modulo n, which unlike the % operator is never
+// negative.
+func wrapInt(v int, n int) int {
+	m := v % n
+	if m < 0 {
+		m += n
+	}
+	return m
+}
@@ -0,0 +1,79 @@
+// Copyright Bjørn Borud 2019 Use of this source code is governed by
+// the license found in the accompanying LICENSE file.
+
+package points
+
+import (
+	"image"
+	"image/color"
+	"image/draw"
+	"testing"
+)
+
+func TestTileableSampling(t *testing.T) {
+	// 50 pixels doesn't divide by 20, so the last column of boxes
+	// runs 10 pixels past the right edge, onto the black strip along
+	// the left edge when wrapped.
+	img := image.NewGray(image.Rect(0, 0, 50, 20))
+	draw.Draw(img, img.Bounds(), image.NewUniform(color.White), image.Point{}, draw.Src)
+	draw.Draw(img, image.Rect(0, 0, 10, 20), image.NewUniform(color.Black), image.Point{}, draw.Src)
+
+	tests := []struct {
+		tileable bool
+		want     bool
+	}{
+		{false, false},
+		{true, true},
+	}
+
+	for _, test := range tests {
+		opts := DefaultOptions()
+		opts.BoxSize = 20
+		opts.Tileable = test.tileable
+
+		r, err := layout(img, opts)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		last := r.boxDots[2]
+		if last.visible != test.want {
+			t.Errorf("tileable %v: expected a dot in the last box %v, got %+v", test.tileable, test.want, last)
+		}
+
+		// Half of the wrapped box is black
+		if test.tileable && last.radius != 5 {
+			t.Errorf("expected a radius of 5 for the wrapped box, got %d", last.radius)
+		}
+	}
+}
+
+func TestTileableStats(t *testing.T) {
+	// Black all over, so with the stroke every dot reaches past the
+	// edges of its box and those along the edges of the image get
+	// copies
+	img := image.NewGray(image.Rect(0, 0, 60, 40))
+
+	opts := DefaultOptions()
+	opts.BoxSize = 20
+	opts.Tileable = true
+	opts.Stroke = color.Black
+	opts.StrokeWidth = 2
+
+	r, err := layout(img, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(r.dots) <= len(r.boxDots) {
+		t.Fatalf("expected copies of the dots along the edges, got %d dots for %d boxes", len(r.dots), len(r.boxDots))
+	}
+
+	st, err := Analyze(img, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if st.Boxes != 6 || st.Dots != 6 || st.Skipped != 0 {
+		t.Errorf("expected 6 boxes, 6 dots and none skipped, got %+v", st)
+	}
+}